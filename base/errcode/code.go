@@ -8,22 +8,28 @@
 
 package errcode
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 type Code struct {
 	messageData []any
 	Code        int
 	Message     string
 	Data        any
+	// HTTPStatus 响应的HTTP状态码，默认200
+	HTTPStatus int
 }
 
 // NewCode 新建一个错误码对象
-// 需要提供一个错误码和对应错误消息
+// 需要提供一个错误码和对应错误消息，HTTP状态码默认200，使用SetHTTPStatus修改
 func NewCode(code int, msg string) *Code {
 	return &Code{
-		Code:    code,
-		Message: msg,
-		Data:    struct{}{},
+		Code:       code,
+		Message:    msg,
+		Data:       struct{}{},
+		HTTPStatus: http.StatusOK,
 	}
 }
 
@@ -34,6 +40,12 @@ func (ec *Code) Error() string {
 	return ec.Message
 }
 
+// Clone 复制错误码，预定义的错误码是全局共享的，设置请求相关的数据前先复制
+func (ec *Code) Clone() *Code {
+	c := *ec
+	return &c
+}
+
 // SetData 设置响应数据
 func (ec *Code) SetData(data any) *Code {
 	ec.Data = data
@@ -46,6 +58,12 @@ func (ec *Code) SetMessage(msg string) *Code {
 	return ec
 }
 
+// SetHTTPStatus 设置响应的HTTP状态码
+func (ec *Code) SetHTTPStatus(status int) *Code {
+	ec.HTTPStatus = status
+	return ec
+}
+
 // SetMessageData 设置消息格式化动态数据
 func (ec *Code) SetMessageData(data ...any) *Code {
 	ec.messageData = data
@@ -53,5 +71,12 @@ func (ec *Code) SetMessageData(data ...any) *Code {
 }
 
 var (
-	ReqErr = NewCode(400, "request error")
+	ReqErr          = NewCode(400, "request error").SetHTTPStatus(http.StatusBadRequest)
+	Unauthorized    = NewCode(401, "unauthorized").SetHTTPStatus(http.StatusUnauthorized)
+	Forbidden       = NewCode(403, "forbidden").SetHTTPStatus(http.StatusForbidden)
+	NotFound        = NewCode(404, "not found").SetHTTPStatus(http.StatusNotFound)
+	Conflict        = NewCode(409, "conflict").SetHTTPStatus(http.StatusConflict)
+	Unprocessable   = NewCode(422, "unprocessable entity").SetHTTPStatus(http.StatusUnprocessableEntity)
+	TooManyRequests = NewCode(429, "too many requests").SetHTTPStatus(http.StatusTooManyRequests)
+	ServerErr       = NewCode(500, "server error").SetHTTPStatus(http.StatusInternalServerError)
 )
@@ -0,0 +1,403 @@
+package reqres
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const openAPIVersion = "3.0.3"
+
+// OpenAPI OpenAPI 3.0文档
+type OpenAPI struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info 文档信息
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem 一个路径下各个方法的接口
+type PathItem map[string]*Operation
+
+// Operation 接口
+type Operation struct {
+	Tags        []string             `json:"tags,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	OperationID string               `json:"operationId,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+}
+
+// Parameter 路径或查询参数
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody 请求体
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response 响应
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType 内容类型对应的Schema
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components 具名结构体的Schema
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// route 通过Handle等函数注册的路由
+type route struct {
+	method    string
+	path      string
+	req       reflect.Type
+	res       reflect.Type
+	bindTypes []bindType
+	op        Operation
+}
+
+// RouteOption 设置接口文档信息
+type RouteOption func(r *route)
+
+// Summary 接口摘要
+func Summary(summary string) RouteOption {
+	return func(r *route) {
+		r.op.Summary = summary
+	}
+}
+
+// Description 接口描述
+func Description(description string) RouteOption {
+	return func(r *route) {
+		r.op.Description = description
+	}
+}
+
+// Tags 接口分组
+func Tags(tags ...string) RouteOption {
+	return func(r *route) {
+		r.op.Tags = append(r.op.Tags, tags...)
+	}
+}
+
+// OperationID 接口ID，默认由方法和路径生成
+func OperationID(id string) RouteOption {
+	return func(r *route) {
+		r.op.OperationID = id
+	}
+}
+
+// Deprecated 标记接口已废弃
+func Deprecated() RouteOption {
+	return func(r *route) {
+		r.op.Deprecated = true
+	}
+}
+
+// Bind 请求参数的绑定方式，与CallService的bt参数相同，默认BindTypeDefault
+func Bind(bt ...bindType) RouteOption {
+	return func(r *route) {
+		r.bindTypes = append(r.bindTypes, bt...)
+	}
+}
+
+// Docs 记录注册的路由并生成OpenAPI文档，并发安全
+type Docs struct {
+	Info Info
+
+	mu     sync.Mutex
+	routes []*route
+}
+
+// NewDocs 创建文档
+func NewDocs(title, version string) *Docs {
+	return &Docs{Info: Info{Title: title, Version: version}}
+}
+
+// Router 在gin路由组上注册路由并记录到文档
+func (d *Docs) Router(group *gin.RouterGroup) *Router {
+	return &Router{docs: d, group: group}
+}
+
+// Spec 生成OpenAPI文档，响应格式根据当前的Formatter生成
+func (d *Docs) Spec() *OpenAPI {
+	d.mu.Lock()
+	routes := make([]*route, len(d.routes))
+	copy(routes, d.routes)
+	d.mu.Unlock()
+
+	b := newSchemaBuilder()
+	spec := &OpenAPI{
+		OpenAPI: openAPIVersion,
+		Info:    d.Info,
+		Paths:   make(map[string]PathItem),
+	}
+	for _, r := range routes {
+		p := openAPIPath(r.path)
+		if spec.Paths[p] == nil {
+			spec.Paths[p] = PathItem{}
+		}
+		spec.Paths[p][strings.ToLower(r.method)] = r.operation(b)
+	}
+	spec.Components.Schemas = b.schemas
+	return spec
+}
+
+// Handler 返回文档的处理函数，如注册到/openapi.json
+func (d *Docs) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, d.Spec())
+	}
+}
+
+func (d *Docs) add(r *route) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.routes = append(d.routes, r)
+}
+
+// Router 注册路由的同时记录请求和响应类型，使用Handle、HandleNoReq、HandleNoRes、HandleNoReqRes注册
+type Router struct {
+	docs  *Docs
+	group *gin.RouterGroup
+}
+
+// Group 创建子路由组
+func (r *Router) Group(relativePath string, handlers ...gin.HandlerFunc) *Router {
+	return &Router{docs: r.docs, group: r.group.Group(relativePath, handlers...)}
+}
+
+// Use 添加中间件
+func (r *Router) Use(middleware ...gin.HandlerFunc) *Router {
+	r.group.Use(middleware...)
+	return r
+}
+
+// Gin 路由组，注册不需要记录到文档的路由
+func (r *Router) Gin() *gin.RouterGroup {
+	return r.group
+}
+
+// handle 注册路由，h由记录的路由生成处理函数，可以使用选项设置的绑定方式
+func (r *Router) handle(method, relativePath string, req, res reflect.Type, h func(rt *route) gin.HandlerFunc, opts []RouteOption) {
+	rt := &route{
+		method: method,
+		path:   path.Join(r.group.BasePath(), relativePath),
+		req:    req,
+		res:    res,
+	}
+	// path.Join会去掉结尾的/，与gin保持一致
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(rt.path, "/") {
+		rt.path += "/"
+	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	r.group.Handle(method, relativePath, h(rt))
+	r.docs.add(rt)
+}
+
+// Handle 注册调用CallService的路由，R为请求类型，P为响应类型
+func Handle[R any, P any](r *Router, method, path string, sh ServiceMethod[*R, *P], opts ...RouteOption) {
+	r.handle(method, path, reflect.TypeFor[R](), reflect.TypeFor[P](), func(rt *route) gin.HandlerFunc {
+		return func(g *gin.Context) {
+			var req R
+			CallService(g, req, sh, rt.bindTypes...)
+		}
+	}, opts)
+}
+
+// HandleNoReq 注册调用CallServiceNoReq的路由
+func HandleNoReq[P any](r *Router, method, path string, sh ServiceMethodNoReq[P], opts ...RouteOption) {
+	r.handle(method, path, nil, reflect.TypeFor[P](), func(*route) gin.HandlerFunc {
+		return func(g *gin.Context) {
+			CallServiceNoReq(g, sh)
+		}
+	}, opts)
+}
+
+// HandleNoRes 注册调用CallServiceNoRes的路由
+func HandleNoRes[R any](r *Router, method, path string, sh ServiceMethodNoRes[*R], opts ...RouteOption) {
+	r.handle(method, path, reflect.TypeFor[R](), nil, func(rt *route) gin.HandlerFunc {
+		return func(g *gin.Context) {
+			var req R
+			CallServiceNoRes(g, req, sh, rt.bindTypes...)
+		}
+	}, opts)
+}
+
+// HandleNoReqRes 注册调用CallServiceNoReqRes的路由
+func HandleNoReqRes(r *Router, method, path string, sh ServiceMethodNoReqRes, opts ...RouteOption) {
+	r.handle(method, path, nil, nil, func(*route) gin.HandlerFunc {
+		return func(g *gin.Context) {
+			CallServiceNoReqRes(g, sh)
+		}
+	}, opts)
+}
+
+func (r *route) operation(b *schemaBuilder) *Operation {
+	op := r.op
+	if op.OperationID == "" {
+		op.OperationID = operationID(r.method, r.path)
+	}
+	if r.req != nil {
+		op.Parameters, op.RequestBody = r.params(b)
+	}
+
+	var data *Schema
+	if r.res != nil {
+		data = b.schema(r.res)
+	} else {
+		data = &Schema{Type: "object"}
+	}
+	op.Responses = map[string]*Response{
+		"200":     successResponse(data),
+		"default": errorResponse(b),
+	}
+	return &op
+}
+
+// params 按绑定方式生成参数和请求体，与ginBind的绑定规则一致
+func (r *route) params(b *schemaBuilder) ([]*Parameter, *RequestBody) {
+	bts := r.bindTypes
+	if len(bts) == 0 {
+		bts = []bindType{BindTypeDefault}
+	}
+
+	var params []*Parameter
+	var body *RequestBody
+	seen := make(map[string]bool)
+	addParams := func(in, tag string) {
+		for _, f := range paramFields(r.req, tag) {
+			if seen[in+f.name] {
+				continue
+			}
+			seen[in+f.name] = true
+			s := b.schema(f.Type)
+			rules := parseBinding(f.Tag.Get("binding"))
+			if s.Ref == "" {
+				applyRules(s, f.Type, rules)
+			}
+			params = append(params, &Parameter{
+				Name:        f.name,
+				In:          in,
+				Description: f.Tag.Get("description"),
+				// 路径参数总是必需的
+				Required: in == "path" || rules.required,
+				Schema:   s,
+			})
+		}
+	}
+	for _, bt := range bts {
+		switch {
+		case bt == BindTypeURI:
+			addParams("path", "uri")
+		case bt == BindTypeQuery:
+			addParams("query", "form")
+		case r.method == http.MethodGet || r.method == http.MethodDelete || r.method == http.MethodHead:
+			// ShouldBind对GET等方法使用form绑定，只解析查询参数
+			addParams("query", "form")
+		case body == nil:
+			body = &RequestBody{
+				Required: true,
+				Content:  map[string]*MediaType{"application/json": {Schema: b.schema(r.req)}},
+			}
+		}
+	}
+	return params, body
+}
+
+// successResponse 成功响应的Schema，与当前Formatter的格式一致
+func successResponse(data *Schema) *Response {
+	var s *Schema
+	switch formatter.(type) {
+	case ProblemFormatter, *ProblemFormatter:
+		s = data
+	default:
+		s = &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"code":     {Type: "integer"},
+				"message":  {Type: "string"},
+				"data":     data,
+				"trace_id": {Type: "string"},
+			},
+			Required: []string{"code", "message", "data"},
+		}
+	}
+	return &Response{
+		Description: http.StatusText(http.StatusOK),
+		Content:     map[string]*MediaType{"application/json": {Schema: s}},
+	}
+}
+
+// errorResponse 错误响应的Schema，与当前Formatter的格式一致
+func errorResponse(b *schemaBuilder) *Response {
+	contentType, s := "application/json", b.schema(reflect.TypeFor[Envelope]())
+	switch formatter.(type) {
+	case ProblemFormatter, *ProblemFormatter:
+		contentType, s = "application/problem+json", b.schema(reflect.TypeFor[Problem]())
+	case LegacyFormatter, *LegacyFormatter:
+		s = b.schema(reflect.TypeFor[Format]())
+	}
+	return &Response{
+		Description: "Error",
+		Content:     map[string]*MediaType{contentType: {Schema: s}},
+	}
+}
+
+var pathParamRegex = regexp.MustCompile(`[:*]([^/]+)`)
+
+// openAPIPath 将gin的路径参数:id、*path转换为{id}、{path}
+func openAPIPath(p string) string {
+	return pathParamRegex.ReplaceAllString(p, "{$1}")
+}
+
+// operationID 由方法和路径生成，如GET /users/:id为getUsersById
+func operationID(method, p string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	for _, seg := range strings.FieldsFunc(p, func(r rune) bool {
+		return r == '/' || r == '-' || r == '_' || r == '.'
+	}) {
+		if name, ok := strings.CutPrefix(seg, ":"); ok {
+			sb.WriteString("By")
+			seg = name
+		} else if name, ok := strings.CutPrefix(seg, "*"); ok {
+			seg = name
+		}
+		if seg == "" {
+			continue
+		}
+		sb.WriteString(strings.ToUpper(seg[:1]) + seg[1:])
+	}
+	return sb.String()
+}
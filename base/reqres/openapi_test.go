@@ -0,0 +1,211 @@
+package reqres
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type pageQuery struct {
+	Page int `form:"page" binding:"omitempty,min=1"`
+	Size int `form:"size" binding:"omitempty,min=1,max=100"`
+}
+
+type listUserReq struct {
+	pageQuery
+	Status string `form:"status" binding:"omitempty,oneof=active banned"`
+}
+
+type updateUserReq struct {
+	ID    int64    `uri:"id"`
+	Name  string   `json:"name" binding:"required,min=2,max=20"`
+	Email string   `json:"email" binding:"omitempty,email"`
+	Tags  []string `json:"tags" binding:"max=5,dive,min=1"`
+}
+
+type user struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Friends   []*user   `json:"friends,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	password  string
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+		id   string
+	}{
+		{"/users", "/users", "getUsers"},
+		{"/users/:id", "/users/{id}", "getUsersById"},
+		{"/users/:id/orders/:order_id", "/users/{id}/orders/{order_id}", "getUsersByIdOrdersByOrderId"},
+		{"/static/*filepath", "/static/{filepath}", "getStaticFilepath"},
+	}
+	for _, tt := range tests {
+		if got := openAPIPath(tt.path); got != tt.want {
+			t.Errorf("openAPIPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+		if got := operationID(http.MethodGet, tt.path); got != tt.id {
+			t.Errorf("operationID(%q) = %q, want %q", tt.path, got, tt.id)
+		}
+	}
+}
+
+func TestApplyRules_Format(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"required,email", "email"},
+		{"omitempty,ip", ""},
+		{"ipv4", "ipv4"},
+		{"ipv6", "ipv6"},
+		{"uuid", "uuid"},
+	}
+	for _, tt := range tests {
+		s := &Schema{Type: "string"}
+		applyRules(s, reflect.TypeOf(""), parseBinding(tt.tag))
+		if s.Format != tt.want {
+			t.Errorf("format of %q = %q, want %q", tt.tag, s.Format, tt.want)
+		}
+	}
+}
+
+func TestDocs(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	docs := NewDocs("test", "1.0.0")
+	api := docs.Router(&e.RouterGroup).Group("/api")
+
+	Handle(api, http.MethodGet, "/users", func(ctx context.Context, req *listUserReq) (*[]user, error) {
+		return &[]user{{ID: 1, Name: req.Status}}, nil
+	}, Summary("list users"), Tags("user"))
+	Handle(api, http.MethodPut, "/users/:id", func(ctx context.Context, req *updateUserReq) (*user, error) {
+		return &user{ID: req.ID, Name: req.Name}, nil
+	}, Bind(BindTypeDefault, BindTypeURI))
+	HandleNoReqRes(api, http.MethodDelete, "/cache", func(ctx context.Context) error { return nil })
+	e.GET("/openapi.json", docs.Handler())
+
+	// 路由正常处理请求
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/api/users/7", strings.NewReader(`{"name":"tom"}`))
+	req.Header.Set("Content-Type", "application/json")
+	e.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"id":7`) {
+		t.Fatalf("PUT /api/users/7 = %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json = %d", w.Code)
+	}
+	var spec OpenAPI
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.OpenAPI != openAPIVersion || spec.Info.Title != "test" {
+		t.Errorf("spec header = %s %+v", spec.OpenAPI, spec.Info)
+	}
+
+	list := spec.Paths["/api/users"]["get"]
+	if list == nil || list.Summary != "list users" || !reflect.DeepEqual(list.Tags, []string{"user"}) {
+		t.Fatalf("GET /api/users = %+v", list)
+	}
+	if list.RequestBody != nil {
+		t.Errorf("GET request body = %+v, want nil", list.RequestBody)
+	}
+	var names []string
+	for _, p := range list.Parameters {
+		names = append(names, p.In+":"+p.Name)
+	}
+	if want := []string{"query:page", "query:size", "query:status"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("GET parameters = %v, want %v", names, want)
+	}
+	if size := list.Parameters[1].Schema; *size.Minimum != 1 || *size.Maximum != 100 {
+		t.Errorf("size schema = %+v", size)
+	}
+	if status := list.Parameters[2].Schema; !reflect.DeepEqual(status.Enum, []any{"active", "banned"}) {
+		t.Errorf("status enum = %v", status.Enum)
+	}
+	data := list.Responses["200"].Content["application/json"].Schema.Properties["data"]
+	if data.Type != "array" || data.Items.Ref != "#/components/schemas/user" {
+		t.Errorf("GET response data = %+v", data)
+	}
+
+	update := spec.Paths["/api/users/{id}"]["put"]
+	if update == nil || len(update.Parameters) != 1 {
+		t.Fatalf("PUT /api/users/{id} = %+v", update)
+	}
+	if p := update.Parameters[0]; p.In != "path" || p.Name != "id" || !p.Required || p.Schema.Format != "int64" {
+		t.Errorf("path parameter = %+v", p)
+	}
+	body := update.RequestBody.Content["application/json"].Schema
+	if body.Ref != "#/components/schemas/updateUserReq" {
+		t.Fatalf("PUT request body = %+v", body)
+	}
+
+	reqSchema := spec.Components.Schemas["updateUserReq"]
+	if _, ok := reqSchema.Properties["ID"]; ok {
+		t.Error("uri field in request body")
+	}
+	if !reflect.DeepEqual(reqSchema.Required, []string{"name"}) {
+		t.Errorf("required = %v", reqSchema.Required)
+	}
+	if name := reqSchema.Properties["name"]; *name.MinLength != 2 || *name.MaxLength != 20 {
+		t.Errorf("name schema = %+v", name)
+	}
+	if email := reqSchema.Properties["email"]; email.Format != "email" {
+		t.Errorf("email schema = %+v", email)
+	}
+	if tags := reqSchema.Properties["tags"]; *tags.MaxItems != 5 || tags.Items.MinLength != nil {
+		t.Errorf("tags schema = %+v", tags)
+	}
+
+	userSchema := spec.Components.Schemas["user"]
+	if _, ok := userSchema.Properties["password"]; ok {
+		t.Error("unexported field in schema")
+	}
+	if userSchema.Properties["friends"].Items.Ref != "#/components/schemas/user" {
+		t.Errorf("recursive field = %+v", userSchema.Properties["friends"])
+	}
+	if created := userSchema.Properties["created_at"]; created.Type != "string" || created.Format != "date-time" {
+		t.Errorf("time field = %+v", created)
+	}
+
+	del := spec.Paths["/api/cache"]["delete"]
+	if del == nil || del.Parameters != nil || del.RequestBody != nil {
+		t.Fatalf("DELETE /api/cache = %+v", del)
+	}
+	if errRes := del.Responses["default"].Content["application/json"].Schema; errRes.Ref != "#/components/schemas/Envelope" {
+		t.Errorf("error response = %+v", errRes)
+	}
+}
+
+func TestDocs_ProblemFormatter(t *testing.T) {
+	old := formatter
+	SetFormatter(ProblemFormatter{})
+	defer SetFormatter(old)
+
+	gin.SetMode(gin.ReleaseMode)
+	docs := NewDocs("test", "1.0.0")
+	HandleNoReq(docs.Router(&gin.New().RouterGroup), http.MethodGet, "/me", func(ctx context.Context) (*user, error) {
+		return &user{}, nil
+	})
+
+	op := docs.Spec().Paths["/me"]["get"]
+	if s := op.Responses["200"].Content["application/json"].Schema; s.Ref != "#/components/schemas/user" {
+		t.Errorf("success response = %+v", s)
+	}
+	if s := op.Responses["default"].Content["application/problem+json"].Schema; s == nil || s.Ref != "#/components/schemas/Problem" {
+		t.Errorf("error response = %+v", op.Responses["default"])
+	}
+}
@@ -30,6 +30,8 @@ var BindTypeQuery bindType = "query"
 
 // ginBind 解析请求参数
 func ginBind[R any](g *gin.Context, req R, bt ...bindType) (err error) {
+	// 校验错误的字段名使用标签名，需要在第一次校验前配置
+	initValidator()
 	btl := len(bt)
 	var cbt bindType
 	if btl == 1 {
@@ -68,7 +70,7 @@ func ginBind[R any](g *gin.Context, req R, bt ...bindType) (err error) {
 // CallService 是一个用于调用需要请求体的服务方法的函数。
 func CallService[R any, P any](g *gin.Context, req R, sh ServiceMethod[*R, *P], bt ...bindType) {
 	if err := ginBind(g, &req, bt...); err != nil {
-		Error(g, err)
+		Error(g, BindError(g, err))
 		return
 	}
 	res, err := sh(g, &req)
@@ -92,7 +94,7 @@ func CallServiceNoReq[P any](g *gin.Context, sh ServiceMethodNoReq[P]) {
 // CallServiceNoRes 是一个用于调用不需要响应体的服务方法的函数。
 func CallServiceNoRes[R any](g *gin.Context, req R, sh ServiceMethodNoRes[*R], bt ...bindType) {
 	if err := ginBind(g, &req, bt...); err != nil {
-		Error(g, err)
+		Error(g, BindError(g, err))
 		return
 	}
 	err := sh(g, &req)
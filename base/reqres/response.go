@@ -3,10 +3,13 @@
 package reqres
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	oteltrace "go.opentelemetry.io/otel/trace"
 
 	"github.com/ilaziness/gokit/base/errcode"
 )
@@ -14,6 +17,22 @@ import (
 const successCode = 0
 const failCode = 1
 
+// Formatter 响应格式，使用SetFormatter设置，默认EnvelopeFormatter
+type Formatter interface {
+	// Success 写入成功响应，data为nil时已替换为空对象
+	Success(ctx *gin.Context, data any)
+	// Error 写入错误响应，非errcode.Code的错误已转换为错误码1、HTTP状态码500的Code
+	Error(ctx *gin.Context, ec *errcode.Code)
+}
+
+var formatter Formatter = EnvelopeFormatter{}
+
+// SetFormatter 设置响应格式，在注册路由前调用
+func SetFormatter(f Formatter) {
+	formatter = f
+}
+
+// Format 旧版本的响应格式
 type Format struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -24,29 +43,142 @@ func Success(ctx *gin.Context, data any) {
 	if data == nil {
 		data = gin.H{}
 	}
-	respData := Format{
+	formatter.Success(ctx, data)
+}
+
+func Error(ctx *gin.Context, err error) {
+	var ec *errcode.Code
+	if !errors.As(err, &ec) {
+		ec = errcode.NewCode(failCode, err.Error()).SetHTTPStatus(http.StatusInternalServerError)
+	}
+	formatter.Error(ctx, ec)
+}
+
+// TraceID 获取请求的trace id，未开启链路追踪时返回空
+func TraceID(ctx *gin.Context) string {
+	span := oteltrace.SpanFromContext(ctx.Request.Context())
+	if !span.SpanContext().HasTraceID() {
+		return ""
+	}
+	return span.SpanContext().TraceID().String()
+}
+
+func httpStatus(ec *errcode.Code) int {
+	if ec.HTTPStatus == 0 {
+		return http.StatusOK
+	}
+	return ec.HTTPStatus
+}
+
+// Envelope EnvelopeFormatter的响应格式
+type Envelope struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	TraceID string `json:"trace_id,omitempty"`
+}
+
+// EnvelopeFormatter 响应格式为{code,message,data,trace_id}，HTTP状态码使用Code.HTTPStatus
+type EnvelopeFormatter struct{}
+
+func (EnvelopeFormatter) Success(ctx *gin.Context, data any) {
+	ctx.JSON(http.StatusOK, Envelope{
 		Code:    successCode,
-		Message: "",
 		Data:    data,
+		TraceID: TraceID(ctx),
+	})
+}
+
+func (EnvelopeFormatter) Error(ctx *gin.Context, ec *errcode.Code) {
+	ctx.JSON(httpStatus(ec), Envelope{
+		Code:    ec.Code,
+		Message: ec.Error(),
+		Data:    ec.Data,
+		TraceID: TraceID(ctx),
+	})
+}
+
+// LegacyFormatter 旧版本的响应格式{code,message,data}，HTTP状态码总是200
+type LegacyFormatter struct{}
+
+func (LegacyFormatter) Success(ctx *gin.Context, data any) {
+	ctx.JSON(http.StatusOK, Format{
+		Code: successCode,
+		Data: data,
+	})
+}
+
+func (LegacyFormatter) Error(ctx *gin.Context, ec *errcode.Code) {
+	ctx.JSON(http.StatusOK, Format{
+		Code:    ec.Code,
+		Message: ec.Error(),
+		Data:    ec.Data,
+	})
+}
+
+// Problem RFC 7807错误响应，code、trace_id、data为扩展字段
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     int    `json:"code"`
+	TraceID  string `json:"trace_id,omitempty"`
+	Data     any    `json:"data,omitempty"`
+}
+
+// ProblemFormatter 错误响应使用RFC 7807 application/problem+json格式，成功响应直接返回data
+type ProblemFormatter struct {
+	// TypeBase 非空时type为TypeBase加错误码，如https://example.com/errors/400，为空时type为about:blank
+	TypeBase string
+}
+
+func (ProblemFormatter) Success(ctx *gin.Context, data any) {
+	ctx.JSON(http.StatusOK, data)
+}
+
+func (f ProblemFormatter) Error(ctx *gin.Context, ec *errcode.Code) {
+	status := httpStatus(ec)
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   ec.Error(),
+		Instance: ctx.Request.URL.Path,
+		Code:     ec.Code,
+		TraceID:  TraceID(ctx),
+	}
+	if f.TypeBase != "" {
+		problem.Type = f.TypeBase + strconv.Itoa(ec.Code)
+	}
+	if !emptyData(ec.Data) {
+		problem.Data = ec.Data
 	}
-	ctx.JSON(http.StatusOK, respData)
+	ctx.Render(status, problemJSON{problem})
 }
 
-func Error(ctx *gin.Context, err error) {
-	var ec *errcode.Code
-	if errors.As(err, &ec) {
-		respData := Format{
-			Code:    ec.Code,
-			Message: ec.Message,
-			Data:    ec.Data,
-		}
-		ctx.JSON(http.StatusOK, respData)
-		return
-	}
-	respData := Format{
-		Code:    failCode,
-		Message: err.Error(),
-		Data:    gin.H{},
-	}
-	ctx.JSON(http.StatusOK, respData)
+// emptyData errcode.Code和Success的默认空数据
+func emptyData(data any) bool {
+	switch v := data.(type) {
+	case nil, struct{}:
+		return true
+	case gin.H:
+		return len(v) == 0
+	}
+	return false
+}
+
+// problemJSON 使用application/problem+json内容类型的JSON渲染
+type problemJSON struct {
+	data any
+}
+
+func (r problemJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.data)
+}
+
+func (r problemJSON) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
 }
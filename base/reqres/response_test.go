@@ -0,0 +1,114 @@
+package reqres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"github.com/ilaziness/gokit/base/errcode"
+)
+
+const testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+// serve 使用指定格式执行handler，返回状态码、Content-Type和解析后的响应
+func serve(t *testing.T, f Formatter, handler gin.HandlerFunc) (int, string, map[string]any) {
+	t.Helper()
+	old := formatter
+	SetFormatter(f)
+	defer SetFormatter(old)
+
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.GET("/orders/:id", handler)
+
+	traceID, _ := oteltrace.TraceIDFromHex(testTraceID)
+	spanCtx := oteltrace.NewSpanContext(oteltrace.SpanContextConfig{TraceID: traceID, SpanID: oteltrace.SpanID{1}})
+	ctx := oteltrace.ContextWithSpanContext(context.Background(), spanCtx)
+	req := httptest.NewRequest(http.MethodGet, "/orders/1", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+
+	body := map[string]any{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %q error: %v", w.Body.String(), err)
+	}
+	return w.Code, w.Header().Get("Content-Type"), body
+}
+
+func TestFormatter(t *testing.T) {
+	notFound := errcode.NewCode(404, "order %s not found").SetHTTPStatus(http.StatusNotFound).SetMessageData("1")
+	success := func(c *gin.Context) { Success(c, gin.H{"id": 1}) }
+	codeErr := func(c *gin.Context) { Error(c, notFound) }
+	plainErr := func(c *gin.Context) { Error(c, errors.New("boom")) }
+
+	tests := []struct {
+		name        string
+		formatter   Formatter
+		handler     gin.HandlerFunc
+		status      int
+		contentType string
+		want        map[string]any
+	}{
+		{"envelope success", EnvelopeFormatter{}, success, 200, "application/json; charset=utf-8",
+			map[string]any{"code": 0.0, "message": "", "data": map[string]any{"id": 1.0}, "trace_id": testTraceID}},
+		{"envelope code", EnvelopeFormatter{}, codeErr, 404, "application/json; charset=utf-8",
+			map[string]any{"code": 404.0, "message": "order 1 not found", "data": map[string]any{}, "trace_id": testTraceID}},
+		{"envelope plain error", EnvelopeFormatter{}, plainErr, 500, "application/json; charset=utf-8",
+			map[string]any{"code": 1.0, "message": "boom", "data": map[string]any{}, "trace_id": testTraceID}},
+		{"legacy code", LegacyFormatter{}, codeErr, 200, "application/json; charset=utf-8",
+			map[string]any{"code": 404.0, "message": "order 1 not found", "data": map[string]any{}}},
+		{"legacy plain error", LegacyFormatter{}, plainErr, 200, "application/json; charset=utf-8",
+			map[string]any{"code": 1.0, "message": "boom", "data": map[string]any{}}},
+		{"problem success", ProblemFormatter{}, success, 200, "application/json; charset=utf-8",
+			map[string]any{"id": 1.0}},
+		{"problem code", ProblemFormatter{TypeBase: "https://example.com/errors/"}, codeErr, 404, "application/problem+json; charset=utf-8",
+			map[string]any{"type": "https://example.com/errors/404", "title": "Not Found", "status": 404.0,
+				"detail": "order 1 not found", "instance": "/orders/1", "code": 404.0, "trace_id": testTraceID}},
+		{"problem plain error", ProblemFormatter{}, plainErr, 500, "application/problem+json; charset=utf-8",
+			map[string]any{"type": "about:blank", "title": "Internal Server Error", "status": 500.0,
+				"detail": "boom", "instance": "/orders/1", "code": 1.0, "trace_id": testTraceID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, contentType, body := serve(t, tt.formatter, tt.handler)
+			if status != tt.status {
+				t.Errorf("status = %d, want %d", status, tt.status)
+			}
+			if contentType != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", contentType, tt.contentType)
+			}
+			got, _ := json.Marshal(body)
+			want, _ := json.Marshal(tt.want)
+			if string(got) != string(want) {
+				t.Errorf("body = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestErrcodeHTTPStatus(t *testing.T) {
+	tests := []struct {
+		code   *errcode.Code
+		status int
+	}{
+		{errcode.ReqErr, http.StatusBadRequest},
+		{errcode.Unauthorized, http.StatusUnauthorized},
+		{errcode.Forbidden, http.StatusForbidden},
+		{errcode.NotFound, http.StatusNotFound},
+		{errcode.Unprocessable, http.StatusUnprocessableEntity},
+		{errcode.TooManyRequests, http.StatusTooManyRequests},
+		{errcode.NewCode(1001, "business error"), http.StatusOK},
+	}
+	for _, tt := range tests {
+		status, _, _ := serve(t, EnvelopeFormatter{}, func(c *gin.Context) { Error(c, tt.code) })
+		if status != tt.status {
+			t.Errorf("%s status = %d, want %d", tt.code.Message, status, tt.status)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package reqres
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema OpenAPI 3.0 Schema对象
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	byteSliceType   = reflect.TypeOf([]byte(nil))
+	schemaNameRegex = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// schemaBuilder 由Go类型生成Schema，具名结构体放到components并使用$ref引用
+type schemaBuilder struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// schema 生成类型的Schema，结构体字段名使用json标签
+func (b *schemaBuilder) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == byteSliceType:
+		return &Schema{Type: "string", Format: "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		return b.ref(t)
+	}
+	// interface等任意类型
+	return &Schema{}
+}
+
+// ref 具名结构体注册到components，递归类型先注册名字再生成
+func (b *schemaBuilder) ref(t reflect.Type) *Schema {
+	name, ok := b.names[t]
+	if !ok {
+		name = b.schemaName(t)
+		b.names[t] = name
+		b.schemas[name] = b.structSchema(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// schemaName 使用类型名，不同包的同名类型加上包名区分
+func (b *schemaBuilder) schemaName(t reflect.Type) string {
+	name := schemaNameRegex.ReplaceAllString(t.Name(), "_")
+	name = strings.Trim(name, "_")
+	if _, exists := b.schemas[name]; !exists {
+		return name
+	}
+	pkg := t.PkgPath()
+	pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+	for i := 0; ; i++ {
+		candidate := pkg + "." + name
+		if i > 0 {
+			candidate += strconv.Itoa(i)
+		}
+		if _, exists := b.schemas[candidate]; !exists {
+			return candidate
+		}
+	}
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, f := range bodyFields(t) {
+		fs := b.schema(f.Type)
+		rules := parseBinding(f.Tag.Get("binding"))
+		if fs.Ref == "" {
+			applyRules(fs, f.Type, rules)
+			fs.Description = f.Tag.Get("description")
+		}
+		if rules.required {
+			s.Required = append(s.Required, f.name)
+		}
+		s.Properties[f.name] = fs
+	}
+	return s
+}
+
+// namedField 结构体字段和其在json或参数中的名字
+type namedField struct {
+	reflect.StructField
+	name string
+}
+
+// bodyFields 请求体和响应体中的字段，使用json标签，只有uri标签的字段属于路径参数，不在请求体中
+func bodyFields(t reflect.Type) []namedField {
+	return fields(t, func(f reflect.StructField) (string, bool) {
+		tag, hasJSON := f.Tag.Lookup("json")
+		if !hasJSON && f.Tag.Get("uri") != "" {
+			return "", false
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			return "", false
+		}
+		if name == "" {
+			name = f.Name
+		}
+		return name, true
+	})
+}
+
+// paramFields 路径或查询参数字段，tag为uri或form
+func paramFields(t reflect.Type, tag string) []namedField {
+	return fields(t, func(f reflect.StructField) (string, bool) {
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			return "", false
+		}
+		if name == "" {
+			// 路径参数必须有uri标签，查询参数没有form标签时使用字段名
+			if tag == "uri" {
+				return "", false
+			}
+			name = f.Name
+		}
+		return name, true
+	})
+}
+
+// fields 展开匿名嵌入的结构体，跳过未导出字段
+func fields(t reflect.Type, name func(reflect.StructField) (string, bool)) []namedField {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var result []namedField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && ft.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+			result = append(result, fields(ft, name)...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if n, ok := name(f); ok {
+			result = append(result, namedField{StructField: f, name: n})
+		}
+	}
+	return result
+}
+
+// bindingRules binding标签中可以转换为Schema约束的规则
+type bindingRules struct {
+	required bool
+	params   map[string]string
+	flags    map[string]bool
+}
+
+// parseBinding 解析binding标签，dive之后的规则作用于元素，忽略
+func parseBinding(tag string) bindingRules {
+	rules := bindingRules{params: map[string]string{}, flags: map[string]bool{}}
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "dive" {
+			break
+		}
+		name, param, _ := strings.Cut(rule, "=")
+		if name == "required" {
+			rules.required = true
+		}
+		if param != "" {
+			rules.params[name] = param
+		} else if name != "" {
+			rules.flags[name] = true
+		}
+	}
+	return rules
+}
+
+// applyRules 将min、max、len、gt、gte、lt、lte、oneof和格式规则转换为Schema约束
+//
+// ip同时接受IPv4和IPv6，OpenAPI没有对应的格式，不设置format
+func applyRules(s *Schema, t reflect.Type, rules bindingRules) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		s.Nullable = !rules.required
+	}
+	for name, format := range map[string]string{"email": "email", "url": "uri", "uri": "uri", "uuid": "uuid", "ipv4": "ipv4", "ipv6": "ipv6", "datetime": "date-time"} {
+		if rules.flags[name] || rules.params[name] != "" {
+			s.Format = format
+		}
+	}
+	if oneof, ok := rules.params["oneof"]; ok {
+		for _, v := range strings.Fields(oneof) {
+			if s.Type == "integer" || s.Type == "number" {
+				if n, err := strconv.ParseFloat(v, 64); err == nil {
+					s.Enum = append(s.Enum, n)
+					continue
+				}
+			}
+			s.Enum = append(s.Enum, v)
+		}
+	}
+
+	number := func(name string) (float64, bool) {
+		v, ok := rules.params[name]
+		if !ok {
+			return 0, false
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+	count := func(name string) *int {
+		if n, ok := number(name); ok {
+			i := int(n)
+			return &i
+		}
+		return nil
+	}
+
+	switch s.Type {
+	case "integer", "number":
+		if n, ok := number("min"); ok {
+			s.Minimum = &n
+		}
+		if n, ok := number("gte"); ok {
+			s.Minimum = &n
+		}
+		if n, ok := number("gt"); ok {
+			s.Minimum, s.ExclusiveMinimum = &n, true
+		}
+		if n, ok := number("max"); ok {
+			s.Maximum = &n
+		}
+		if n, ok := number("lte"); ok {
+			s.Maximum = &n
+		}
+		if n, ok := number("lt"); ok {
+			s.Maximum, s.ExclusiveMaximum = &n, true
+		}
+	case "string":
+		s.MinLength, s.MaxLength = count("min"), count("max")
+		if l := count("len"); l != nil {
+			s.MinLength, s.MaxLength = l, l
+		}
+	case "array":
+		s.MinItems, s.MaxItems = count("min"), count("max")
+		if l := count("len"); l != nil {
+			s.MinItems, s.MaxItems = l, l
+		}
+	}
+}
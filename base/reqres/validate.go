@@ -0,0 +1,189 @@
+package reqres
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+
+	"github.com/ilaziness/gokit/base/errcode"
+)
+
+// 支持的错误消息语言
+const (
+	LocaleZH = "zh"
+	LocaleEN = "en"
+)
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	// Field 字段名，使用json标签，没有json标签时依次使用form、uri标签，嵌套字段如items[0].name
+	Field string `json:"field"`
+	// Rule 校验规则，如required、max，类型错误时为type
+	Rule string `json:"rule"`
+	// Param 规则参数，如max=10的10
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+var (
+	validateOnce  sync.Once
+	validate      *validator.Validate
+	uni           *ut.UniversalTranslator
+	defaultLocale = LocaleZH
+)
+
+// initValidator 配置gin的校验器，字段名使用标签名并注册中英文错误消息
+func initValidator() {
+	validateOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		v.RegisterTagNameFunc(tagName)
+
+		uni = ut.New(zh.New(), zh.New(), en.New())
+		zhTrans, _ := uni.GetTranslator(LocaleZH)
+		enTrans, _ := uni.GetTranslator(LocaleEN)
+		_ = zhTranslations.RegisterDefaultTranslations(v, zhTrans)
+		_ = enTranslations.RegisterDefaultTranslations(v, enTrans)
+		validate = v
+	})
+}
+
+func tagName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// SetDefaultLocale 设置默认的错误消息语言，请求的Accept-Language不支持时使用，默认zh
+func SetDefaultLocale(locale string) {
+	defaultLocale = locale
+}
+
+// RegisterValidation 注册自定义校验规则，在启动时调用
+//
+// messages为各语言的错误消息，key为语言，如zh、en，消息中{0}为字段名，{1}为规则参数
+func RegisterValidation(tag string, fn validator.Func, messages map[string]string) error {
+	initValidator()
+	if validate == nil {
+		return errors.New("binding validator is not go-playground/validator")
+	}
+	if err := validate.RegisterValidation(tag, fn); err != nil {
+		return err
+	}
+	for locale, message := range messages {
+		trans, found := uni.GetTranslator(locale)
+		if !found {
+			return errors.New("unsupported locale: " + locale)
+		}
+		err := validate.RegisterTranslation(tag, trans, func(trans ut.Translator) error {
+			return trans.Add(tag, message, true)
+		}, func(trans ut.Translator, fe validator.FieldError) string {
+			msg, _ := trans.T(tag, fe.Field(), fe.Param())
+			return msg
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BindError 将请求绑定错误转换为errcode.ReqErr，Data为{"errors": []FieldError}，Message为第一个字段的错误消息
+func BindError(ctx *gin.Context, err error) error {
+	initValidator()
+	var fields []FieldError
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrs):
+		trans := translator(ctx)
+		for _, fe := range validationErrs {
+			fields = append(fields, FieldError{
+				Field:   fieldPath(fe),
+				Rule:    fe.Tag(),
+				Param:   fe.Param(),
+				Message: translate(fe, trans),
+			})
+		}
+	case errors.As(err, &typeErr):
+		fe := FieldError{
+			Field: typeErr.Field,
+			Rule:  "type",
+			Param: typeErr.Type.String(),
+		}
+		fe.Message = fe.Field + " must be of type " + fe.Param
+		if trans := translator(ctx); trans != nil && trans.Locale() == LocaleZH {
+			fe.Message = fe.Field + "必须是" + fe.Param + "类型"
+		}
+		fields = append(fields, fe)
+	default:
+		return errcode.ReqErr.Clone().SetMessage(err.Error())
+	}
+	return errcode.ReqErr.Clone().
+		SetMessage(fields[0].Message).
+		SetData(gin.H{"errors": fields})
+}
+
+// fieldPath 去掉顶层结构体名的字段路径
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if _, path, found := strings.Cut(ns, "."); found {
+		return path
+	}
+	return ns
+}
+
+func translate(fe validator.FieldError, trans ut.Translator) string {
+	if trans == nil {
+		return fe.Error()
+	}
+	msg := fe.Translate(trans)
+	if msg == "" || msg == fe.Error() {
+		// 没有该规则的翻译
+		return fe.Field() + " failed on " + fe.Tag()
+	}
+	return msg
+}
+
+// translator 按Accept-Language选择语言
+func translator(ctx *gin.Context) ut.Translator {
+	if uni == nil {
+		return nil
+	}
+	var locales []string
+	for _, lang := range strings.Split(ctx.GetHeader("Accept-Language"), ",") {
+		lang, _, _ = strings.Cut(strings.TrimSpace(lang), ";")
+		if lang == "" {
+			continue
+		}
+		// zh-CN、en-US等只匹配语言部分
+		base, _, _ := strings.Cut(lang, "-")
+		locales = append(locales, strings.ToLower(base))
+	}
+	if trans, found := uni.FindTranslator(locales...); found {
+		return trans
+	}
+	trans, _ := uni.GetTranslator(defaultLocale)
+	return trans
+}
@@ -0,0 +1,133 @@
+package reqres
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type createOrderReq struct {
+	Name  string      `json:"name" binding:"required"`
+	Count int         `json:"count" binding:"min=1,max=10"`
+	SKU   string      `json:"sku" binding:"omitempty,sku"`
+	Items []orderItem `json:"items" binding:"dive"`
+}
+
+type orderItem struct {
+	Price int `json:"price" binding:"gt=0"`
+}
+
+type createOrderRes struct {
+	Name string `json:"name"`
+}
+
+func init() {
+	err := RegisterValidation("sku", func(fl validator.FieldLevel) bool {
+		return strings.HasPrefix(fl.Field().String(), "SKU-")
+	}, map[string]string{
+		LocaleZH: "{0}必须以SKU-开头",
+		LocaleEN: "{0} must start with SKU-",
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestCallService_BindError(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.POST("/orders", func(c *gin.Context) {
+		CallService(c, createOrderReq{}, func(_ context.Context, req *createOrderReq) (*createOrderRes, error) {
+			return &createOrderRes{Name: req.Name}, nil
+		})
+	})
+
+	tests := []struct {
+		name    string
+		body    string
+		lang    string
+		status  int
+		message string
+		errors  []FieldError
+	}{
+		{
+			name:   "valid",
+			body:   `{"name":"a","count":1,"sku":"SKU-1"}`,
+			status: http.StatusOK,
+		},
+		{
+			name:    "zh",
+			body:    `{"count":11,"sku":"x","items":[{"price":0}]}`,
+			status:  http.StatusBadRequest,
+			message: "name为必填字段",
+			errors: []FieldError{
+				{Field: "name", Rule: "required", Message: "name为必填字段"},
+				{Field: "count", Rule: "max", Param: "10", Message: "count必须小于或等于10"},
+				{Field: "sku", Rule: "sku", Message: "sku必须以SKU-开头"},
+				{Field: "items[0].price", Rule: "gt", Param: "0", Message: "price必须大于0"},
+			},
+		},
+		{
+			name:    "en",
+			body:    `{"name":"a","count":0,"sku":"x"}`,
+			lang:    "en-US,en;q=0.9",
+			status:  http.StatusBadRequest,
+			message: "count must be 1 or greater",
+			errors: []FieldError{
+				{Field: "count", Rule: "min", Param: "1", Message: "count must be 1 or greater"},
+				{Field: "sku", Rule: "sku", Message: "sku must start with SKU-"},
+			},
+		},
+		{
+			name:    "type error",
+			body:    `{"name":"a","count":"1"}`,
+			status:  http.StatusBadRequest,
+			message: "count必须是int类型",
+			errors: []FieldError{
+				{Field: "count", Rule: "type", Param: "int", Message: "count必须是int类型"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.lang != "" {
+				req.Header.Set("Accept-Language", tt.lang)
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status == http.StatusOK {
+				return
+			}
+			var resp struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+				Data    struct {
+					Errors []FieldError `json:"errors"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != 400 || resp.Message != tt.message {
+				t.Errorf("code = %d, message = %q, want 400 %q", resp.Code, resp.Message, tt.message)
+			}
+			got, _ := json.Marshal(resp.Data.Errors)
+			want, _ := json.Marshal(tt.errors)
+			if string(got) != string(want) {
+				t.Errorf("errors = %s\nwant %s", got, want)
+			}
+		})
+	}
+}
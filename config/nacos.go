@@ -21,9 +21,25 @@ func InitNacos[T any](cfg *Nacos, appCfg T) {
 		group:     cfg.Group,
 	}
 
+	param, err := cfg.ClientParam()
+	if err != nil {
+		panic(err)
+	}
+	cfc, err := clients.NewConfigClient(param)
+	if err != nil {
+		panic(err)
+	}
+	nacos.nacosConfigClient = cfc
+	nacos.get()
+
+	hook.Exit.Register(nacos.cancelListen)
+}
+
+// ClientParam 根据配置生成nacos客户端参数，配置中心和服务注册共用
+func (cfg *Nacos) ClientParam() (vo.NacosClientParam, error) {
 	cc := constant.ClientConfig{}
 	if err := copier.Copy(&cc, cfg.Client); err != nil {
-		panic(err)
+		return vo.NacosClientParam{}, err
 	}
 	cc.NamespaceId = cfg.Client.NamespaceID
 
@@ -35,19 +51,10 @@ func InitNacos[T any](cfg *Nacos, appCfg T) {
 		})
 	}
 
-	cfc, err := clients.NewConfigClient(
-		vo.NacosClientParam{
-			ClientConfig:  &cc,
-			ServerConfigs: sc,
-		},
-	)
-	if err != nil {
-		panic(err)
-	}
-	nacos.nacosConfigClient = cfc
-	nacos.get()
-
-	hook.Exit.Register(nacos.cancelListen)
+	return vo.NacosClientParam{
+		ClientConfig:  &cc,
+		ServerConfigs: sc,
+	}, nil
 }
 
 type nacosConfigType[T any] struct {
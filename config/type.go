@@ -14,10 +14,40 @@ type App struct {
 	RootDir       string `mapstructure:"root_dir"`
 	Cors          *Cors  `mapstructure:"cors"`
 	SessionSecret string `mapstructure:"session_secret"`
+	// 会话配置，配置session_secret时生效
+	Session *Session `mapstructure:"session"`
+	// JWT令牌验证配置，使用middleware/auth.NewVerifier创建验证器
+	JWT *JWT `mapstructure:"jwt"`
+	// 请求限流配置，配置时WebApp使用限流中间件
+	RateLimit *RateLimit `mapstructure:"rate_limit"`
+	// 信任的反向代理IP或CIDR，只有来自这些地址的请求才从X-Forwarded-For、X-Real-IP读取客户端IP，
+	// 影响限流、请求日志和业务中的ClientIP，避免伪造请求头绕过按IP限流。
+	// 不兼容变更：之前信任全部代理，现在默认不信任任何代理，客户端IP为连接的远端地址，
+	// 部署在ingress或负载均衡之后时需要配置其地址，配置["0.0.0.0/0", "::/0"]恢复之前的行为
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 	// 是否记录请求日志
 	LogReq bool `mapstructure:"log_req"`
 	// 是否开启pprof
 	Pprof bool `mapstructure:"pprof"`
+	// 是否注册/livez、/healthz、/readyz健康检查接口
+	Health bool `mapstructure:"health"`
+	// 是否记录HTTP请求指标并注册Prometheus格式的/metrics接口
+	Metrics bool `mapstructure:"metrics"`
+	// 是否注册/openapi.json接口，提供通过WebApp.Router()注册的路由的OpenAPI文档
+	OpenAPI bool `mapstructure:"openapi"`
+	// TLS证书，都配置时使用HTTPS，文件变化后自动重新加载
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	// TLS最低版本 1.2 1.3，默认1.2
+	TLSMinVersion string `mapstructure:"tls_min_version"`
+	// 非空时要求客户端证书并使用该CA验证(mTLS)
+	ClientCAFile string `mapstructure:"client_ca_file"`
+	// 大于0时在该端口监听HTTP，将请求重定向到HTTPS
+	HTTPRedirectPort uint16 `mapstructure:"http_redirect_port"`
+	// 大于0时HTTPS响应带Strict-Transport-Security头，值为max-age秒数
+	HSTSMaxAge int `mapstructure:"hsts_max_age"`
+	// 是否同时提供HTTP/3服务，需要配置TLS证书，监听与HTTPS相同的UDP端口
+	HTTP3 bool `mapstructure:"http3"`
 }
 
 type Cors struct {
@@ -27,6 +57,49 @@ type Cors struct {
 	AllowCredentials bool     `mapstructure:"allow_credentials"`
 }
 
+// Session 会话配置
+type Session struct {
+	Store           string `mapstructure:"store"`            // 存储方式 cookie redis，默认cookie，redis使用storage/redis.Client
+	CookieName      string `mapstructure:"cookie_name"`      // cookie名，默认session
+	Domain          string `mapstructure:"domain"`           // cookie域名
+	Secure          bool   `mapstructure:"secure"`           // 是否只在HTTPS中发送cookie，配置TLS证书时总是开启
+	IdleTimeout     int    `mapstructure:"idle_timeout"`     // 空闲超时(秒)，默认1800
+	AbsoluteTimeout int    `mapstructure:"absolute_timeout"` // 最长有效期(秒)，默认86400
+}
+
+// JWT 令牌验证配置，HS算法使用secret，RS算法使用公钥文件和JWKS文件，两者不能同时配置
+type JWT struct {
+	Algorithm     string `mapstructure:"algorithm"`       // HS256 HS384 HS512 RS256 RS384 RS512，默认配置secret时HS256，否则RS256
+	Secret        string `mapstructure:"secret"`          // HS算法密钥
+	PublicKeyFile string `mapstructure:"public_key_file"` // RS算法PEM格式公钥文件
+	JWKSFile      string `mapstructure:"jwks_file"`       // 本地JWKS文件，按kid选择密钥
+	Issuer        string `mapstructure:"issuer"`          // 非空时校验iss
+	Audience      string `mapstructure:"audience"`        // 非空时校验aud
+	Leeway        int    `mapstructure:"leeway"`          // 校验exp、nbf允许的时钟偏差(秒)
+}
+
+// RateLimit 请求限流配置
+//
+// 限流中间件全局注册，在认证之前执行，不支持按用户限流，需要时在auth.JWT之后使用ratelimit.UserID注册。
+// api_key不验证请求头中的key，每个不同的值单独计算配额，客户端更换key即可绕过限流，只适合key已在网关验证的场景
+type RateLimit struct {
+	Store        string           `mapstructure:"store"`          // 存储方式 memory redis，默认memory，redis使用storage/redis.Client，不可用时使用内存限流
+	Key          string           `mapstructure:"key"`            // 限流键 ip api_key route，默认ip，ip依赖trusted_proxies获取客户端IP
+	APIKeyHeader string           `mapstructure:"api_key_header"` // key为api_key时读取的请求头，默认X-API-Key
+	Limit        int              `mapstructure:"limit"`          // 默认配额，period内的请求数，0不限流
+	Period       int              `mapstructure:"period"`         // 配额周期(秒)，默认1
+	Burst        int              `mapstructure:"burst"`          // 突发请求数，默认等于limit
+	Routes       []RouteRateLimit `mapstructure:"routes"`         // 路由的单独配额
+}
+
+// RouteRateLimit 路由的限流配额
+type RouteRateLimit struct {
+	Route  string `mapstructure:"route"`  // 方法和路由模板，如GET /orders/:id，或只有路由模板
+	Limit  int    `mapstructure:"limit"`  // period内的请求数
+	Period int    `mapstructure:"period"` // 配额周期(秒)，默认1
+	Burst  int    `mapstructure:"burst"`  // 突发请求数，默认等于limit
+}
+
 // DB sql数据库配置
 type DB struct {
 	// sql方言 sqlite3 postgres mysql pgx
@@ -107,6 +180,18 @@ type UDPServer struct {
 	// DTLS (TLS over UDP)
 	CertFile string `mapstructure:"cert_file"`
 	KeyFile  string `mapstructure:"key_file"`
+	// 包认证共享密钥，非空时使用HMAC认证普通UDP包并拒绝重放
+	AuthSecret string `mapstructure:"auth_secret"`
+	// 批量收发的消息数，大于1时使用recvmmsg/sendmmsg批量收发，仅Linux生效
+	BatchSize int `mapstructure:"batch_size"`
+	// 固定工作协程数，大于0时使用固定的工作协程池处理请求，否则每个请求启动一个协程
+	FixedWorkers int `mapstructure:"fixed_workers"`
+	// 组播和广播，启用后仅监听IPv4
+	MulticastGroups    []string `mapstructure:"multicast_groups"`    // 加入的组播组，如 239.0.0.1
+	MulticastInterface string   `mapstructure:"multicast_interface"` // 组播网卡名，空则使用系统默认网卡
+	MulticastTTL       int      `mapstructure:"multicast_ttl"`       // 发送组播的TTL，0使用系统默认值
+	MulticastLoopback  bool     `mapstructure:"multicast_loopback"`  // 是否接收本机发送的组播
+	Broadcast          bool     `mapstructure:"broadcast"`           // 是否识别广播包
 }
 
 // QUICServer QUIC服务配置
@@ -115,12 +200,37 @@ type QUICServer struct {
 	Address   string `mapstructure:"address"`
 	WorkerNum int    `mapstructure:"worker_num"`
 	// TLS配置 (QUIC必需)
-	CertFile string `mapstructure:"cert_file"`
-	KeyFile  string `mapstructure:"key_file"`
+	CertFile     string `mapstructure:"cert_file"`
+	KeyFile      string `mapstructure:"key_file"`
+	ClientCAFile string `mapstructure:"client_ca_file"` // 非空时要求客户端证书并使用该CA验证(mTLS)
 	// QUIC特定配置
 	IdleTimeout      int  `mapstructure:"idle_timeout"`      // 空闲超时(秒)
 	KeepAlive        int  `mapstructure:"keep_alive"`        // 保活间隔(秒)
 	HandshakeTimeout int  `mapstructure:"handshake_timeout"` // 握手超时(秒)
 	MaxStreams       int  `mapstructure:"max_streams"`       // 最大流数量
 	Allow0RTT        bool `mapstructure:"allow_0rtt"`        // 允许0-RTT
+	MaxFrameSize     int  `mapstructure:"max_frame_size"`    // 流上单个包的最大长度(字节)
+	// 调试配置，只在Debug为true时生效
+	QlogDir    string `mapstructure:"qlog_dir"`     // 每个连接的qlog追踪写入该目录
+	KeyLogFile string `mapstructure:"key_log_file"` // TLS会话密钥写入该文件(SSLKEYLOGFILE格式)，用于Wireshark解密
+}
+
+// QUICClient QUIC客户端配置
+type QUICClient struct {
+	// TLS配置
+	CAFile             string `mapstructure:"ca_file"`              // 验证服务端证书的CA，空则使用系统CA
+	ServerName         string `mapstructure:"server_name"`          // 验证证书使用的服务端名称，空则使用目标地址的主机名
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"` // 不验证服务端证书，仅用于测试
+	CertFile           string `mapstructure:"cert_file"`            // 客户端证书，服务端开启mTLS时使用
+	KeyFile            string `mapstructure:"key_file"`
+	// QUIC特定配置
+	IdleTimeout      int  `mapstructure:"idle_timeout"`      // 空闲超时(秒)
+	KeepAlive        int  `mapstructure:"keep_alive"`        // 保活间隔(秒)，0不发送保活包
+	HandshakeTimeout int  `mapstructure:"handshake_timeout"` // 握手超时(秒)
+	Allow0RTT        bool `mapstructure:"allow_0rtt"`        // 使用会话票据0-RTT恢复连接，服务端需开启allow_0rtt
+	MaxFrameSize     int  `mapstructure:"max_frame_size"`    // 流上单个包的最大长度(字节)
+	// 连接池和请求
+	PoolSize        int `mapstructure:"pool_size"`         // 每个目标地址的连接数
+	MaxDatagramSize int `mapstructure:"max_datagram_size"` // 请求包不超过该长度时使用数据报发送，否则使用流，0使用默认值，小于0总是使用流
+	RequestTimeout  int `mapstructure:"request_timeout"`   // 请求超时(秒)，ctx未设置截止时间时使用
 }
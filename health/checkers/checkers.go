@@ -0,0 +1,54 @@
+// Package checkers 提供storage/sql、storage/redis、RocketMQ和nacos的内置健康检查项
+//
+// 单独成包，避免health和server依赖数据库、redis和RocketMQ客户端
+package checkers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ilaziness/gokit/health"
+	"github.com/ilaziness/gokit/queue/rocketmq"
+	"github.com/ilaziness/gokit/storage/redis"
+	"github.com/ilaziness/gokit/storage/sql"
+)
+
+var (
+	ErrRedisNotInitialized = errors.New("redis client not initialized")
+	ErrNacosUnhealthy      = errors.New("nacos server unhealthy")
+)
+
+// SQL 检查storage/sql已初始化的数据库连接池
+func SQL() health.Checker {
+	return health.NewChecker("sql", sql.Ping)
+}
+
+// Redis 检查storage/redis.Client
+func Redis() health.Checker {
+	return health.NewChecker("redis", func(ctx context.Context) error {
+		if redis.Client == nil {
+			return ErrRedisNotInitialized
+		}
+		return redis.Client.Ping(ctx).Err()
+	})
+}
+
+// RocketMQ 检查RocketMQ生产者
+func RocketMQ() health.Checker {
+	return health.NewChecker("rocketmq", rocketmq.ProducerHealth)
+}
+
+// NacosClient nacos客户端，naming_client.INamingClient满足该接口
+type NacosClient interface {
+	ServerHealthy() bool
+}
+
+// Nacos 检查nacos服务端连接，命名客户端使用server.NamingClient()获取
+func Nacos(client NacosClient) health.Checker {
+	return health.NewChecker("nacos", func(context.Context) error {
+		if client == nil || !client.ServerHealthy() {
+			return ErrNacosUnhealthy
+		}
+		return nil
+	})
+}
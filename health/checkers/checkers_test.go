@@ -0,0 +1,32 @@
+package checkers
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNacos(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  NacosClient
+		wantErr bool
+	}{
+		{"nil", nil, true},
+		{"healthy", nacosStub(true), false},
+		{"unhealthy", nacosStub(false), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Nacos(tt.client).Check(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+type nacosStub bool
+
+func (n nacosStub) ServerHealthy() bool {
+	return bool(n)
+}
@@ -0,0 +1,222 @@
+// Package health 提供健康检查、就绪检查和存活检查
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ilaziness/gokit/log"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+
+	defaultTimeout  = 3 * time.Second
+	defaultCacheTTL = time.Second
+)
+
+// Checker 健康检查项，Check返回nil表示健康，ctx超时后应尽快返回
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type checkerFunc struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (c *checkerFunc) Name() string {
+	return c.name
+}
+
+func (c *checkerFunc) Check(ctx context.Context) error {
+	return c.fn(ctx)
+}
+
+// NewChecker 使用函数创建检查项
+func NewChecker(name string, fn func(ctx context.Context) error) Checker {
+	return &checkerFunc{name: name, fn: fn}
+}
+
+// Result 单个检查项的结果
+type Result struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report 检查报告，任意检查项失败时Status为down
+type Report struct {
+	Status    string    `json:"status"`
+	CheckedAt time.Time `json:"checked_at"`
+	Checks    []Result  `json:"checks,omitempty"`
+}
+
+// Healthy 是否全部健康
+func (r *Report) Healthy() bool {
+	return r.Status == StatusUp
+}
+
+// Health 健康检查集合，并发执行全部检查项并缓存结果，并发安全
+type Health struct {
+	// Timeout 单个检查项的超时时间，默认3秒
+	Timeout time.Duration
+	// CacheTTL 检查结果的缓存时间，默认1秒，缓存期间的请求直接返回上次的结果，避免探针请求压垮依赖服务
+	CacheTTL time.Duration
+
+	mu       sync.RWMutex
+	checkers []Checker
+
+	runMu    sync.Mutex // 同一时间只执行一轮检查
+	cached   *Report
+	cachedAt time.Time
+
+	shutdown atomic.Bool
+}
+
+// NewHealth 创建健康检查集合
+func NewHealth(checkers ...Checker) *Health {
+	return &Health{
+		Timeout:  defaultTimeout,
+		CacheTTL: defaultCacheTTL,
+		checkers: checkers,
+	}
+}
+
+// Register 添加检查项
+func (h *Health) Register(checkers ...Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checkers = append(h.checkers, checkers...)
+}
+
+// Shutdown 标记服务开始关闭，之后就绪检查失败，负载均衡不再转发新请求
+func (h *Health) Shutdown() {
+	h.shutdown.Store(true)
+}
+
+// Check 执行全部检查项，缓存未过期时返回缓存的结果
+func (h *Health) Check(ctx context.Context) *Report {
+	h.runMu.Lock()
+	defer h.runMu.Unlock()
+	if h.cached != nil && time.Since(h.cachedAt) < h.CacheTTL {
+		return h.cached
+	}
+
+	h.mu.RLock()
+	checkers := append([]Checker(nil), h.checkers...)
+	h.mu.RUnlock()
+
+	report := &Report{
+		Status:    StatusUp,
+		CheckedAt: time.Now(),
+		Checks:    make([]Result, len(checkers)),
+	}
+	// 结果会被缓存并返回给其他请求，检查项不受当前请求取消的影响，只受Timeout限制
+	checkCtx := context.WithoutCancel(ctx)
+	var wg sync.WaitGroup
+	for i, c := range checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = h.run(checkCtx, c)
+		}()
+	}
+	wg.Wait()
+
+	for _, r := range report.Checks {
+		if r.Status != StatusUp {
+			report.Status = StatusDown
+			log.Warn(ctx, "health check %s failed: %s", r.Name, r.Error)
+		}
+	}
+	h.cached, h.cachedAt = report, time.Now()
+	return report
+}
+
+// Ready 就绪检查，开始关闭后直接返回失败，否则执行全部检查项
+func (h *Health) Ready(ctx context.Context) *Report {
+	if h.shutdown.Load() {
+		return &Report{
+			Status:    StatusDown,
+			CheckedAt: time.Now(),
+			Checks:    []Result{{Name: "shutdown", Status: StatusDown, Error: "server is shutting down"}},
+		}
+	}
+	return h.Check(ctx)
+}
+
+// run 执行单个检查项，检查项超时未返回或panic时视为失败
+func (h *Health) run(ctx context.Context, c Checker) Result {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- c.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{
+		Name:     c.Name(),
+		Status:   StatusUp,
+		Duration: time.Since(start).String(),
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LiveHandler 存活检查接口，进程能响应请求即为存活，不检查依赖服务，避免依赖故障导致服务被重启
+func (h *Health) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeReport(w, &Report{Status: StatusUp, CheckedAt: time.Now()})
+	})
+}
+
+// HealthHandler 健康检查接口，返回全部检查项的结果，失败时状态码为503
+func (h *Health) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, h.Check(r.Context()))
+	})
+}
+
+// ReadyHandler 就绪检查接口，开始关闭或检查失败时状态码为503
+func (h *Health) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, h.Ready(r.Context()))
+	})
+}
+
+func writeReport(w http.ResponseWriter, report *Report) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Healthy() {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
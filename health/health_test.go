@@ -0,0 +1,171 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilaziness/gokit/log"
+)
+
+// TestMain 日志写入临时目录，不在包目录下生成日志文件
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gokit-log")
+	if err != nil {
+		panic(err)
+	}
+	log.SetFile(filepath.Join(dir, "app.log"))
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestHealth_Check(t *testing.T) {
+	ok := NewChecker("ok", func(context.Context) error { return nil })
+	fail := NewChecker("fail", func(context.Context) error { return errors.New("boom") })
+	slow := NewChecker("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	// 不响应ctx的检查项也要在超时后返回
+	stuck := NewChecker("stuck", func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	panics := NewChecker("panic", func(context.Context) error { panic("oops") })
+
+	tests := []struct {
+		name     string
+		checkers []Checker
+		status   string
+		errs     map[string]bool
+	}{
+		{"empty", nil, StatusUp, nil},
+		{"ok", []Checker{ok}, StatusUp, map[string]bool{"ok": false}},
+		{"fail", []Checker{ok, fail}, StatusDown, map[string]bool{"ok": false, "fail": true}},
+		{"timeout", []Checker{slow, stuck}, StatusDown, map[string]bool{"slow": true, "stuck": true}},
+		{"panic", []Checker{panics}, StatusDown, map[string]bool{"panic": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealth(tt.checkers...)
+			h.Timeout = 50 * time.Millisecond
+			start := time.Now()
+			report := h.Check(context.Background())
+			if d := time.Since(start); d > 500*time.Millisecond {
+				t.Errorf("Check() took %s, want checks to run in parallel with timeout", d)
+			}
+			if report.Status != tt.status {
+				t.Errorf("Status = %s, want %s", report.Status, tt.status)
+			}
+			if len(report.Checks) != len(tt.checkers) {
+				t.Fatalf("len(Checks) = %d, want %d", len(report.Checks), len(tt.checkers))
+			}
+			for i, r := range report.Checks {
+				if r.Name != tt.checkers[i].Name() {
+					t.Errorf("Checks[%d].Name = %s, want %s", i, r.Name, tt.checkers[i].Name())
+				}
+				if got := r.Error != ""; got != tt.errs[r.Name] {
+					t.Errorf("%s error = %q, want error %v", r.Name, r.Error, tt.errs[r.Name])
+				}
+			}
+		})
+	}
+}
+
+func TestHealth_Cache(t *testing.T) {
+	var calls atomic.Int32
+	h := NewHealth(NewChecker("count", func(context.Context) error {
+		calls.Add(1)
+		return nil
+	}))
+	h.CacheTTL = 100 * time.Millisecond
+
+	h.Check(context.Background())
+	h.Check(context.Background())
+	if n := calls.Load(); n != 1 {
+		t.Errorf("calls = %d, want 1 within CacheTTL", n)
+	}
+	time.Sleep(150 * time.Millisecond)
+	h.Check(context.Background())
+	if n := calls.Load(); n != 2 {
+		t.Errorf("calls = %d, want 2 after CacheTTL", n)
+	}
+}
+
+func TestHealth_CanceledRequest(t *testing.T) {
+	h := NewHealth(NewChecker("dep", func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(20 * time.Millisecond):
+			return nil
+		}
+	}))
+
+	// 发起检查的请求已断开时，缓存的结果不受影响
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r := h.Check(ctx); r.Status != StatusUp {
+		t.Errorf("status = %s, want %s: %+v", r.Status, StatusUp, r.Checks)
+	}
+}
+
+func TestHealth_Handlers(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	h := NewHealth(NewChecker("dep", func(context.Context) error {
+		if !healthy.Load() {
+			return errors.New("down")
+		}
+		return nil
+	}))
+	h.CacheTTL = 0
+
+	serve := func(handler http.Handler) (int, *Report) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		report := &Report{}
+		if err := json.Unmarshal(w.Body.Bytes(), report); err != nil {
+			t.Fatalf("decode report error: %v", err)
+		}
+		return w.Code, report
+	}
+
+	tests := []struct {
+		name     string
+		healthy  bool
+		shutdown bool
+		live     int
+		health   int
+		ready    int
+	}{
+		{"healthy", true, false, http.StatusOK, http.StatusOK, http.StatusOK},
+		{"dependency down", false, false, http.StatusOK, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		{"shutting down", true, true, http.StatusOK, http.StatusOK, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			healthy.Store(tt.healthy)
+			if tt.shutdown {
+				h.Shutdown()
+			}
+			if code, _ := serve(h.LiveHandler()); code != tt.live {
+				t.Errorf("live = %d, want %d", code, tt.live)
+			}
+			if code, report := serve(h.HealthHandler()); code != tt.health || len(report.Checks) != 1 {
+				t.Errorf("health = %d %+v, want %d", code, report, tt.health)
+			}
+			if code, _ := serve(h.ReadyHandler()); code != tt.ready {
+				t.Errorf("ready = %d, want %d", code, tt.ready)
+			}
+		})
+	}
+}
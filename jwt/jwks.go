@@ -0,0 +1,114 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+
+	jwtLib "github.com/golang-jwt/jwt/v5"
+)
+
+var ErrKeyInvalid = errors.New("key is invalid")
+
+// jwk JSON Web Key，只使用签名需要的字段
+type jwk struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA公钥
+	N string `json:"n"`
+	E string `json:"e"`
+	// 对称密钥
+	K string `json:"k"`
+}
+
+// ParseJWKS 解析JWKS {"keys":[...]}，支持RSA公钥和oct对称密钥，跳过use不是sig和不支持的密钥
+//
+// alg为空时RSA使用RS256，oct使用HS256
+func ParseJWKS(data []byte) ([]*Key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeyInvalid, err)
+	}
+	var keys []*Key
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.key()
+		if err != nil {
+			return nil, fmt.Errorf("jwk %q: %w", k.KeyID, err)
+		}
+		if key != nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// LoadJWKS 从本地文件读取JWKS
+func LoadJWKS(path string) ([]*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(data)
+}
+
+// key 转换为签名密钥，不支持的密钥类型返回nil
+func (k *jwk) key() (*Key, error) {
+	switch k.KeyType {
+	case "RSA":
+		alg := k.Algorithm
+		if alg == "" {
+			alg = RS256
+		}
+		if !slices.Contains([]string{RS256, RS384, RS512}, alg) {
+			return nil, ErrAlgorithmInvalid
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil || len(n) == 0 {
+			return nil, ErrKeyInvalid
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, ErrKeyInvalid
+		}
+		pub := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		return &Key{ID: k.KeyID, Algorithm: alg, PublicKey: pub}, nil
+	case "oct":
+		alg := k.Algorithm
+		if alg == "" {
+			alg = HS256
+		}
+		if !slices.Contains([]string{HS256, HS384, HS512}, alg) {
+			return nil, ErrAlgorithmInvalid
+		}
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return nil, ErrKeyInvalid
+		}
+		return &Key{ID: k.KeyID, Algorithm: alg, Secret: secret}, nil
+	}
+	return nil, nil
+}
+
+// ParseRSAPublicKeyPEM 解析PEM格式的RSA公钥，支持PKIX、PKCS1公钥和证书
+func ParseRSAPublicKeyPEM(data []byte) (*rsa.PublicKey, error) {
+	pub, err := jwtLib.ParseRSAPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeyInvalid, err)
+	}
+	return pub, nil
+}
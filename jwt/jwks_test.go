@@ -0,0 +1,122 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadJWKS(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := fmt.Sprintf(`{"keys":[
+		{"kty":"RSA","kid":"rsa","use":"sig","n":%q,"e":%q},
+		{"kty":"oct","kid":"hmac","alg":"HS512","k":%q},
+		{"kty":"RSA","kid":"enc","use":"enc","n":%q,"e":%q},
+		{"kty":"EC","kid":"ec","crv":"P-256"}
+	]}`, b64(priv.N.Bytes()), b64(big.NewInt(int64(priv.E)).Bytes()), b64([]byte("secret")),
+		b64(priv.N.Bytes()), b64(big.NewInt(int64(priv.E)).Bytes()))
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err = os.WriteFile(path, []byte(jwks), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadJWKS(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].Algorithm != RS256 || keys[1].Algorithm != HS512 {
+		t.Fatalf("keys = %+v", keys)
+	}
+	v := NewVerifier(keys...)
+	for _, signKey := range []*Key{
+		{ID: "rsa", Algorithm: RS256, PrivateKey: priv},
+		{ID: "hmac", Algorithm: HS512, Secret: []byte("secret")},
+	} {
+		token, _ := Sign(&Claims{Subject: "u1"}, signKey)
+		if _, err = v.Verify(token); err != nil {
+			t.Errorf("Verify() with %s error: %v", signKey.ID, err)
+		}
+	}
+}
+
+func TestParseJWKS_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		jwks    string
+		wantErr error
+	}{
+		{"not json", `{`, ErrKeyInvalid},
+		{"bad modulus", `{"keys":[{"kty":"RSA","n":"!","e":"AQAB"}]}`, ErrKeyInvalid},
+		{"empty secret", `{"keys":[{"kty":"oct","k":""}]}`, ErrKeyInvalid},
+		{"alg mismatch", `{"keys":[{"kty":"oct","alg":"RS256","k":"c2VjcmV0"}]}`, ErrAlgorithmInvalid},
+	}
+	for _, tt := range tests {
+		if _, err := ParseJWKS([]byte(tt.jwks)); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseRSAPublicKeyPEM(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkix, _ := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	for name, block := range map[string]*pem.Block{
+		"pkix":   {Type: "PUBLIC KEY", Bytes: pkix},
+		"pkcs1":  {Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&priv.PublicKey)},
+		"no pem": nil,
+	} {
+		var data []byte
+		if block != nil {
+			data = pem.EncodeToMemory(block)
+		}
+		pub, err := ParseRSAPublicKeyPEM(data)
+		if block == nil {
+			if !errors.Is(err, ErrKeyInvalid) {
+				t.Errorf("%s: error = %v", name, err)
+			}
+			continue
+		}
+		if err != nil || !pub.Equal(&priv.PublicKey) {
+			t.Errorf("%s: pub = %v, error = %v", name, pub, err)
+		}
+	}
+}
+
+func TestClaims_HasPermission(t *testing.T) {
+	c := &Claims{Roles: []string{"admin"}, Permissions: []string{"order:read", "user:*"}}
+	tests := []struct {
+		permission string
+		want       bool
+	}{
+		{"order:read", true},
+		{"order:write", false},
+		{"user:delete", true},
+		{"users", false},
+	}
+	for _, tt := range tests {
+		if got := c.HasPermission(tt.permission); got != tt.want {
+			t.Errorf("HasPermission(%q) = %v, want %v", tt.permission, got, tt.want)
+		}
+	}
+	if !c.HasRole("admin") || c.HasRole("user") {
+		t.Error("HasRole mismatch")
+	}
+	if !(&Claims{Permissions: []string{"*"}}).HasPermission("any") {
+		t.Error("* should match any permission")
+	}
+}
@@ -0,0 +1,377 @@
+// Package jwt 基于github.com/golang-jwt/jwt/v5签名和验证令牌，提供声明、角色和权限判断以及多密钥轮换
+package jwt
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	jwtLib "github.com/golang-jwt/jwt/v5"
+)
+
+// 支持的签名算法
+const (
+	HS256 = "HS256"
+	HS384 = "HS384"
+	HS512 = "HS512"
+	RS256 = "RS256"
+	RS384 = "RS384"
+	RS512 = "RS512"
+)
+
+var (
+	ErrTokenMalformed    = errors.New("token is malformed")
+	ErrAlgorithmInvalid  = errors.New("signing algorithm is invalid")
+	ErrUnknownKey        = errors.New("signing key is unknown")
+	ErrSignatureInvalid  = errors.New("signature is invalid")
+	ErrTokenExpired      = errors.New("token is expired")
+	ErrTokenNotValidYet  = errors.New("token is not valid yet")
+	ErrAudienceInvalid   = errors.New("token audience is invalid")
+	ErrIssuerInvalid     = errors.New("token issuer is invalid")
+	ErrExpirationMissing = errors.New("token expiration is missing")
+)
+
+// libErrors golang-jwt的错误对应的错误，按顺序匹配，keyFunc返回的错误优先
+var libErrors = []struct {
+	lib, err error
+}{
+	{ErrUnknownKey, ErrUnknownKey},
+	{ErrAlgorithmInvalid, ErrAlgorithmInvalid},
+	{jwtLib.ErrTokenMalformed, ErrTokenMalformed},
+	{jwtLib.ErrTokenSignatureInvalid, ErrSignatureInvalid},
+	{jwtLib.ErrTokenExpired, ErrTokenExpired},
+	{jwtLib.ErrTokenNotValidYet, ErrTokenNotValidYet},
+	{jwtLib.ErrTokenInvalidAudience, ErrAudienceInvalid},
+	{jwtLib.ErrTokenInvalidIssuer, ErrIssuerInvalid},
+	{jwtLib.ErrTokenRequiredClaimMissing, ErrExpirationMissing},
+}
+
+// verifyError 转换golang-jwt返回的错误
+func verifyError(err error) error {
+	for _, e := range libErrors {
+		if errors.Is(err, e.lib) {
+			return e.err
+		}
+	}
+	return err
+}
+
+var methods = map[string]jwtLib.SigningMethod{
+	HS256: jwtLib.SigningMethodHS256,
+	HS384: jwtLib.SigningMethodHS384,
+	HS512: jwtLib.SigningMethodHS512,
+	RS256: jwtLib.SigningMethodRS256,
+	RS384: jwtLib.SigningMethodRS384,
+	RS512: jwtLib.SigningMethodRS512,
+}
+
+// Key 签名密钥，HS算法使用Secret，RS算法签名使用PrivateKey、验证使用PublicKey
+type Key struct {
+	ID         string // kid，密钥轮换时用于选择验证密钥
+	Algorithm  string
+	Secret     []byte
+	PrivateKey *rsa.PrivateKey
+	PublicKey  *rsa.PublicKey
+}
+
+// signKey 签名使用的密钥，没有对应密钥时返回nil
+func (k *Key) signKey() any {
+	if strings.HasPrefix(k.Algorithm, "HS") {
+		if len(k.Secret) == 0 {
+			return nil
+		}
+		return k.Secret
+	}
+	if k.PrivateKey == nil {
+		return nil
+	}
+	return k.PrivateKey
+}
+
+// verifyKey 验证使用的密钥，HS算法的空密钥返回nil，避免接受空密钥签名的令牌
+func (k *Key) verifyKey() any {
+	if strings.HasPrefix(k.Algorithm, "HS") {
+		if len(k.Secret) == 0 {
+			return nil
+		}
+		return k.Secret
+	}
+	if k.PublicKey != nil {
+		return k.PublicKey
+	}
+	if k.PrivateKey != nil {
+		return &k.PrivateKey.PublicKey
+	}
+	return nil
+}
+
+// Audience aud声明，JSON中可以是字符串或字符串数组
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Claims 令牌声明，Raw保存全部声明，可读取自定义字段
+type Claims struct {
+	Issuer      string   `json:"iss,omitempty"`
+	Subject     string   `json:"sub,omitempty"`
+	Audience    Audience `json:"aud,omitempty"`
+	ExpiresAt   int64    `json:"exp,omitempty"`
+	NotBefore   int64    `json:"nbf,omitempty"`
+	IssuedAt    int64    `json:"iat,omitempty"`
+	ID          string   `json:"jti,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+
+	Raw map[string]any `json:"-"`
+}
+
+// UnmarshalJSON 解码声明，exp、nbf、iat按RFC 7519可以是带小数的秒数，exp向下取整、nbf向上取整
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	type claims Claims
+	aux := struct {
+		*claims
+		ExpiresAt json.Number `json:"exp,omitempty"`
+		NotBefore json.Number `json:"nbf,omitempty"`
+		IssuedAt  json.Number `json:"iat,omitempty"`
+	}{claims: (*claims)(c)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	var err error
+	if c.ExpiresAt, err = numericDate(aux.ExpiresAt, math.Floor); err != nil {
+		return err
+	}
+	if c.NotBefore, err = numericDate(aux.NotBefore, math.Ceil); err != nil {
+		return err
+	}
+	c.IssuedAt, err = numericDate(aux.IssuedAt, math.Floor)
+	return err
+}
+
+func numericDate(n json.Number, round func(float64) float64) (int64, error) {
+	if n == "" {
+		return 0, nil
+	}
+	if i, err := n.Int64(); err == nil {
+		return i, nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return 0, err
+	}
+	return int64(round(f)), nil
+}
+
+// GetExpirationTime 实现jwtLib.Claims
+func (c *Claims) GetExpirationTime() (*jwtLib.NumericDate, error) {
+	return numericTime(c.ExpiresAt), nil
+}
+
+// GetNotBefore 实现jwtLib.Claims
+func (c *Claims) GetNotBefore() (*jwtLib.NumericDate, error) {
+	return numericTime(c.NotBefore), nil
+}
+
+// GetIssuedAt 实现jwtLib.Claims
+func (c *Claims) GetIssuedAt() (*jwtLib.NumericDate, error) {
+	return numericTime(c.IssuedAt), nil
+}
+
+// GetIssuer 实现jwtLib.Claims
+func (c *Claims) GetIssuer() (string, error) {
+	return c.Issuer, nil
+}
+
+// GetSubject 实现jwtLib.Claims
+func (c *Claims) GetSubject() (string, error) {
+	return c.Subject, nil
+}
+
+// GetAudience 实现jwtLib.Claims
+func (c *Claims) GetAudience() (jwtLib.ClaimStrings, error) {
+	return jwtLib.ClaimStrings(c.Audience), nil
+}
+
+func numericTime(sec int64) *jwtLib.NumericDate {
+	if sec == 0 {
+		return nil
+	}
+	return jwtLib.NewNumericDate(time.Unix(sec, 0))
+}
+
+// HasRole 是否有指定角色
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// HasPermission 是否有指定权限，权限*匹配全部，order:*匹配order:read、order:write等
+func (c *Claims) HasPermission(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission || p == "*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(permission, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyClaims 将任意可JSON编码的值作为签名的声明，签名时不验证声明
+type anyClaims struct {
+	jwtLib.RegisteredClaims
+	v any
+}
+
+func (c anyClaims) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.v)
+}
+
+// Sign 使用key签名生成令牌，claims可以是*Claims或任意可JSON编码的值
+func Sign(claims any, key *Key) (string, error) {
+	method, ok := methods[key.Algorithm]
+	if !ok {
+		return "", ErrAlgorithmInvalid
+	}
+	k := key.signKey()
+	if k == nil {
+		return "", ErrUnknownKey
+	}
+	token := jwtLib.NewWithClaims(method, anyClaims{v: claims})
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(k)
+}
+
+// Verifier 令牌验证器，支持多个密钥以便轮换，并发安全
+type Verifier struct {
+	mu   sync.RWMutex
+	keys []*Key
+
+	// Audience 非空时令牌的aud必须包含该值
+	Audience string
+	// Issuer 非空时令牌的iss必须等于该值
+	Issuer string
+	// Leeway 验证exp、nbf时允许的时钟偏差
+	Leeway time.Duration
+	// RequireExpiration 为true时拒绝没有exp的令牌
+	RequireExpiration bool
+
+	now func() time.Time
+}
+
+// NewVerifier 创建验证器
+func NewVerifier(keys ...*Key) *Verifier {
+	return &Verifier{keys: keys, now: time.Now}
+}
+
+// AddKey 添加验证密钥，kid相同时替换
+func (v *Verifier) AddKey(key *Key) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys = slices.DeleteFunc(v.keys, func(k *Key) bool {
+		return key.ID != "" && k.ID == key.ID
+	})
+	v.keys = append(v.keys, key)
+}
+
+// RemoveKey 移除验证密钥，轮换完成后移除旧密钥
+func (v *Verifier) RemoveKey(kid string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys = slices.DeleteFunc(v.keys, func(k *Key) bool {
+		return k.ID == kid
+	})
+}
+
+// SetKeys 替换全部验证密钥
+func (v *Verifier) SetKeys(keys []*Key) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys = keys
+}
+
+// Verify 验证令牌签名和声明，令牌头有kid时使用对应密钥，否则依次尝试算法相同的密钥
+//
+// exp必须晚于当前时间，nbf不能晚于当前时间，都允许Leeway的偏差
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	opts := []jwtLib.ParserOption{
+		jwtLib.WithLeeway(v.Leeway),
+		jwtLib.WithTimeFunc(v.now),
+	}
+	if v.Audience != "" {
+		opts = append(opts, jwtLib.WithAudience(v.Audience))
+	}
+	if v.Issuer != "" {
+		opts = append(opts, jwtLib.WithIssuer(v.Issuer))
+	}
+	if v.RequireExpiration {
+		opts = append(opts, jwtLib.WithExpirationRequired())
+	}
+	parser := jwtLib.NewParser(opts...)
+
+	claims := &Claims{}
+	if _, err := parser.ParseWithClaims(token, claims, v.keyFunc); err != nil {
+		return nil, verifyError(err)
+	}
+	payload, err := parser.DecodeSegment(strings.Split(token, ".")[1])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if err = json.Unmarshal(payload, &claims.Raw); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrTokenMalformed, err)
+	}
+	return claims, nil
+}
+
+// keyFunc 按令牌头的kid和alg选择验证密钥，kid对应的密钥算法不一致时返回错误，防止算法混淆攻击
+func (v *Verifier) keyFunc(token *jwtLib.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	alg := token.Method.Alg()
+	if _, ok := methods[alg]; !ok {
+		// none等不支持的算法没有对应的密钥
+		return nil, ErrUnknownKey
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	var set jwtLib.VerificationKeySet
+	for _, key := range v.keys {
+		if kid != "" && key.ID != kid {
+			continue
+		}
+		if key.Algorithm != alg {
+			if kid != "" {
+				return nil, ErrAlgorithmInvalid
+			}
+			continue
+		}
+		if k := key.verifyKey(); k != nil {
+			set.Keys = append(set.Keys, k)
+		}
+	}
+	if len(set.Keys) == 0 {
+		return nil, ErrUnknownKey
+	}
+	return set, nil
+}
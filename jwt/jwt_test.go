@@ -0,0 +1,252 @@
+package jwt
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	now := time.Now()
+	key := &Key{ID: "k1", Algorithm: HS256, Secret: []byte("secret")}
+
+	tests := []struct {
+		name    string
+		claims  *Claims
+		setup   func(v *Verifier)
+		wantErr error
+	}{
+		{
+			name:   "valid",
+			claims: &Claims{Subject: "u1", ExpiresAt: now.Add(time.Hour).Unix(), Audience: Audience{"api"}},
+		},
+		{
+			name:    "expired",
+			claims:  &Claims{Subject: "u1", ExpiresAt: now.Add(-time.Minute).Unix()},
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name:   "expired within leeway",
+			claims: &Claims{Subject: "u1", ExpiresAt: now.Add(-time.Minute).Unix()},
+			setup: func(v *Verifier) {
+				v.Leeway = 2 * time.Minute
+			},
+		},
+		{
+			name:    "not valid yet",
+			claims:  &Claims{Subject: "u1", NotBefore: now.Add(time.Hour).Unix()},
+			wantErr: ErrTokenNotValidYet,
+		},
+		{
+			name:    "expiration required",
+			claims:  &Claims{Subject: "u1"},
+			setup:   func(v *Verifier) { v.RequireExpiration = true },
+			wantErr: ErrExpirationMissing,
+		},
+		{
+			name:    "audience mismatched",
+			claims:  &Claims{Subject: "u1", Audience: Audience{"web"}},
+			setup:   func(v *Verifier) { v.Audience = "api" },
+			wantErr: ErrAudienceInvalid,
+		},
+		{
+			name:   "audience matched",
+			claims: &Claims{Subject: "u1", Audience: Audience{"web", "api"}},
+			setup:  func(v *Verifier) { v.Audience = "api" },
+		},
+		{
+			name:    "issuer mismatched",
+			claims:  &Claims{Subject: "u1", Issuer: "other"},
+			setup:   func(v *Verifier) { v.Issuer = "gokit" },
+			wantErr: ErrIssuerInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewVerifier(key)
+			if tt.setup != nil {
+				tt.setup(v)
+			}
+			token, err := Sign(tt.claims, key)
+			if err != nil {
+				t.Fatalf("Sign() error: %v", err)
+			}
+			claims, err := v.Verify(token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (claims.Subject != "u1" || claims.Raw["sub"] != "u1") {
+				t.Errorf("Verify() claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestVerifier_ExpiresNow(t *testing.T) {
+	key := &Key{Algorithm: HS256, Secret: []byte("secret")}
+	now := time.Unix(time.Now().Unix(), 0)
+	v := NewVerifier(key)
+	v.now = func() time.Time { return now }
+
+	// RFC 7519要求当前时间早于exp
+	tests := []struct {
+		exp     time.Time
+		wantErr error
+	}{
+		{now, ErrTokenExpired},
+		{now.Add(time.Second), nil},
+	}
+	for _, tt := range tests {
+		token, _ := Sign(&Claims{Subject: "u1", ExpiresAt: tt.exp.Unix()}, key)
+		if _, err := v.Verify(token); !errors.Is(err, tt.wantErr) {
+			t.Errorf("exp %d: Verify() error = %v, want %v", tt.exp.Unix(), err, tt.wantErr)
+		}
+	}
+}
+
+func TestVerifier_AudienceString(t *testing.T) {
+	key := &Key{Algorithm: HS256, Secret: []byte("secret")}
+	token, err := Sign(map[string]any{"sub": "u1", "aud": "api"}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewVerifier(key)
+	v.Audience = "api"
+	if _, err = v.Verify(token); err != nil {
+		t.Errorf("Verify() error: %v", err)
+	}
+}
+
+func TestVerifier_KeyRotation(t *testing.T) {
+	oldKey := &Key{ID: "old", Algorithm: HS256, Secret: []byte("old-secret")}
+	newKey := &Key{ID: "new", Algorithm: HS512, Secret: []byte("new-secret")}
+	v := NewVerifier(oldKey)
+
+	oldToken, _ := Sign(&Claims{Subject: "u1"}, oldKey)
+	newToken, _ := Sign(&Claims{Subject: "u1"}, newKey)
+
+	if _, err := v.Verify(newToken); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Verify() before rotation error = %v, want %v", err, ErrUnknownKey)
+	}
+
+	// 轮换期间新旧密钥同时有效
+	v.AddKey(newKey)
+	for _, token := range []string{oldToken, newToken} {
+		if _, err := v.Verify(token); err != nil {
+			t.Errorf("Verify() during rotation error: %v", err)
+		}
+	}
+
+	v.RemoveKey("old")
+	if _, err := v.Verify(oldToken); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Verify() removed key error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestVerifier_RS256(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signKey := &Key{ID: "rsa", Algorithm: RS256, PrivateKey: priv}
+	verifyKey := &Key{ID: "rsa", Algorithm: RS256, PublicKey: &priv.PublicKey}
+
+	token, err := Sign(&Claims{Subject: "u1", Roles: []string{"admin"}}, signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := NewVerifier(verifyKey).Verify(token)
+	if err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+	if len(claims.Roles) != 1 || claims.Roles[0] != "admin" {
+		t.Errorf("roles = %v", claims.Roles)
+	}
+
+	// 使用HS算法和公钥伪造的令牌不能通过验证
+	forged, _ := Sign(&Claims{Subject: "u1"}, &Key{ID: "rsa", Algorithm: HS256, Secret: priv.PublicKey.N.Bytes()})
+	if _, err = NewVerifier(verifyKey).Verify(forged); !errors.Is(err, ErrAlgorithmInvalid) {
+		t.Errorf("Verify() forged error = %v, want %v", err, ErrAlgorithmInvalid)
+	}
+}
+
+func TestVerifier_Invalid(t *testing.T) {
+	key := &Key{Algorithm: HS256, Secret: []byte("secret")}
+	token, _ := Sign(&Claims{Subject: "u1"}, key)
+	parts := strings.Split(token, ".")
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "malformed", token: "abc", wantErr: ErrTokenMalformed},
+		{name: "bad header", token: "!." + parts[1] + "." + parts[2], wantErr: ErrTokenMalformed},
+		{name: "tampered payload", token: parts[0] + "." + encodeSegment([]byte(`{"sub":"admin"}`)) + "." + parts[2], wantErr: ErrSignatureInvalid},
+		{name: "alg none", token: encodeSegment([]byte(`{"alg":"none"}`)) + "." + parts[1] + ".", wantErr: ErrUnknownKey},
+	}
+	v := NewVerifier(key)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Verify(tt.token); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifier_EmptySecret(t *testing.T) {
+	// 使用空密钥签名的HS256令牌
+	input := encodeSegment([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + encodeSegment([]byte(`{"sub":"admin","permissions":["*"]}`))
+	mac := hmac.New(sha256.New, nil)
+	mac.Write([]byte(input))
+	token := input + "." + encodeSegment(mac.Sum(nil))
+
+	if _, err := NewVerifier(&Key{Algorithm: HS256}).Verify(token); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Verify() error = %v, want %v", err, ErrUnknownKey)
+	}
+	if _, err := Sign(&Claims{Subject: "u1"}, &Key{Algorithm: HS256}); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Sign() error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestVerifier_NumericDate(t *testing.T) {
+	key := &Key{Algorithm: HS256, Secret: []byte("secret")}
+	now := float64(time.Now().Unix())
+	tests := []struct {
+		name    string
+		claims  map[string]any
+		wantErr error
+	}{
+		{name: "fractional", claims: map[string]any{"exp": now + 3600.5, "nbf": now - 60.25, "iat": now + 0.75}},
+		{name: "fractional expired", claims: map[string]any{"exp": now - 60.5}, wantErr: ErrTokenExpired},
+		{name: "string", claims: map[string]any{"exp": "soon"}, wantErr: ErrTokenMalformed},
+	}
+	v := NewVerifier(key)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := Sign(tt.claims, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := v.Verify(token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (claims.ExpiresAt != int64(now)+3600 || claims.NotBefore != int64(now)-60 || claims.IssuedAt != int64(now)) {
+				t.Errorf("claims = %d %d %d", claims.ExpiresAt, claims.NotBefore, claims.IssuedAt)
+			}
+		})
+	}
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
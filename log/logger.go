@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/ilaziness/gokit/hook"
@@ -30,8 +31,9 @@ const (
 var (
 	Logger    *zap.SugaredLogger
 	zapLogger *zap.Logger
-	logLevel  = zapcore.DebugLevel
-	logMode   string
+	logLevel  = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	logMode   atomic.Value // string，多个服务可能同时设置
+	logFile   = "log/app.log"
 )
 
 // SetLevel 设置日志级别
@@ -42,14 +44,20 @@ func SetLevel(mode ...string) {
 
 	switch mode[0] {
 	case ModeDebug:
-		logMode = mode[0]
-		logLevel = zapcore.DebugLevel
+		logMode.Store(mode[0])
+		logLevel.SetLevel(zapcore.DebugLevel)
 	case ModeRelease:
-		logMode = mode[0]
-		logLevel = zapcore.InfoLevel
+		logMode.Store(mode[0])
+		logLevel.SetLevel(zapcore.InfoLevel)
 	}
 }
 
+// SetFile 设置日志文件路径，默认log/app.log，需要在写日志之前调用，如测试中指向临时目录
+func SetFile(filename string) {
+	logFile = filename
+	setLogger()
+}
+
 func init() {
 	setLogger()
 
@@ -59,18 +67,16 @@ func init() {
 func setLogger() {
 	// 配置日志写入文件
 	fileWriter := zapcore.AddSync(&lumberjack.Logger{
-		Filename:   "log/app.log", // 日志文件名
-		MaxSize:    10,            // 每个日志文件的最大大小（MB）
-		MaxBackups: 10,            // 保留的旧日志文件的最大数量
-		MaxAge:     60,            // 保留旧日志文件的最大天数
-		Compress:   true,          // 是否压缩旧日志文件
+		Filename:   logFile, // 日志文件名
+		MaxSize:    10,      // 每个日志文件的最大大小（MB）
+		MaxBackups: 10,      // 保留的旧日志文件的最大数量
+		MaxAge:     60,      // 保留旧日志文件的最大天数
+		Compress:   true,    // 是否压缩旧日志文件
 	})
 	// 配置日志写入控制台
 	consoleWriter := zapcore.Lock(os.Stdout)
 	// 配置日志级别
-	highPriority := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= logLevel
-	})
+	highPriority := logLevel
 	// 创建一个核心（Core），将日志同时写入文件和控制台
 	core := zapcore.NewTee(
 		zapcore.NewCore(
@@ -119,7 +125,7 @@ func buildEncoderConsoleConfig() zapcore.EncoderConfig {
 
 // IsDebugMode 是否是debug模式，是返回true
 func IsDebugMode() bool {
-	return logMode == ModeDebug
+	return logMode.Load() == ModeDebug
 }
 
 // Debug 增加了记录trace id
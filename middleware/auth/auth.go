@@ -0,0 +1,209 @@
+// Package auth 提供JWT认证和基于角色、权限的访问控制中间件
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ilaziness/gokit/base/errcode"
+	"github.com/ilaziness/gokit/base/reqres"
+	"github.com/ilaziness/gokit/config"
+	"github.com/ilaziness/gokit/jwt"
+)
+
+// claimsKey 令牌声明在gin.Context中的key
+const claimsKey = "gokit/auth.claims"
+
+// claimsCtxKey 令牌声明在请求context中的key
+type claimsCtxKey struct{}
+
+// ErrNoToken 请求中没有令牌
+var ErrNoToken = errors.New("authentication required")
+
+// NewVerifier 由配置创建验证器，HS算法使用Secret，RS算法使用PublicKeyFile，配置JWKSFile时加载其中的密钥
+//
+// 未配置算法时有Secret使用HS256，否则使用RS256。HS算法没有Secret、RS算法没有公钥或JWKS、
+// 以及Secret与RS算法或PublicKeyFile与HS算法同时配置时返回错误，避免使用空密钥验证HS签名
+func NewVerifier(cfg *config.JWT) (*jwt.Verifier, error) {
+	alg := cfg.Algorithm
+	if alg == "" {
+		alg = jwt.RS256
+		if cfg.Secret != "" {
+			alg = jwt.HS256
+		}
+	}
+	switch alg {
+	case jwt.HS256, jwt.HS384, jwt.HS512:
+		if cfg.Secret == "" {
+			return nil, fmt.Errorf("%w: algorithm %s requires secret", jwt.ErrUnknownKey, alg)
+		}
+		if cfg.PublicKeyFile != "" {
+			return nil, fmt.Errorf("%w: public_key_file requires an RS algorithm, got %s", jwt.ErrAlgorithmInvalid, alg)
+		}
+	case jwt.RS256, jwt.RS384, jwt.RS512:
+		if cfg.PublicKeyFile == "" && cfg.JWKSFile == "" {
+			return nil, fmt.Errorf("%w: algorithm %s requires public_key_file or jwks_file", jwt.ErrUnknownKey, alg)
+		}
+		if cfg.Secret != "" {
+			return nil, fmt.Errorf("%w: secret requires an HS algorithm, got %s", jwt.ErrAlgorithmInvalid, alg)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", jwt.ErrAlgorithmInvalid, alg)
+	}
+	var keys []*jwt.Key
+	if cfg.Secret != "" {
+		keys = append(keys, &jwt.Key{Algorithm: alg, Secret: []byte(cfg.Secret)})
+	}
+	if cfg.PublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		pub, err := jwt.ParseRSAPublicKeyPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &jwt.Key{Algorithm: alg, PublicKey: pub})
+	}
+	if cfg.JWKSFile != "" {
+		jwks, err := jwt.LoadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, jwks...)
+	}
+	if len(keys) == 0 {
+		return nil, jwt.ErrUnknownKey
+	}
+	v := jwt.NewVerifier(keys...)
+	v.Issuer = cfg.Issuer
+	v.Audience = cfg.Audience
+	v.Leeway = time.Duration(cfg.Leeway) * time.Second
+	return v, nil
+}
+
+// JWTConfig JWT中间件配置
+type JWTConfig struct {
+	// Verifier 令牌验证器，过期时间、签发者、受众和时钟偏差在验证器上配置
+	Verifier *jwt.Verifier
+	// Query 非空时请求头没有令牌则从该查询参数读取，用于WebSocket等不能设置请求头的场景
+	Query string
+	// Cookie 非空时请求头没有令牌则从该cookie读取
+	Cookie string
+	// Optional 为true时没有令牌的请求继续处理，令牌无效时仍然拒绝
+	Optional bool
+}
+
+// JWT 认证中间件，从Authorization: Bearer请求头读取令牌，验证通过后将声明保存到请求context
+//
+// 没有令牌或令牌无效时返回errcode.Unauthorized，处理函数和service中使用GetClaims获取声明
+func JWT(cfg JWTConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := cfg.token(c)
+		if token == "" {
+			if cfg.Optional {
+				c.Next()
+				return
+			}
+			unauthorized(c, ErrNoToken)
+			return
+		}
+		claims, err := cfg.Verifier.Verify(token)
+		if err != nil {
+			unauthorized(c, err)
+			return
+		}
+		c.Set(claimsKey, claims)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), claimsCtxKey{}, claims))
+		c.Next()
+	}
+}
+
+func (cfg *JWTConfig) token(c *gin.Context) string {
+	if h := c.GetHeader("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	if cfg.Query != "" {
+		if token := c.Query(cfg.Query); token != "" {
+			return token
+		}
+	}
+	if cfg.Cookie != "" {
+		if token, err := c.Cookie(cfg.Cookie); err == nil {
+			return token
+		}
+	}
+	return ""
+}
+
+// unauthorized 返回401，按RFC 6750设置WWW-Authenticate
+func unauthorized(c *gin.Context, err error) {
+	challenge := "Bearer"
+	if !errors.Is(err, ErrNoToken) {
+		challenge = `Bearer error="invalid_token"`
+	}
+	c.Header("WWW-Authenticate", challenge)
+	reqres.Error(c, errcode.Unauthorized.Clone().SetMessage(err.Error()))
+	c.Abort()
+}
+
+// GetClaims 获取JWT中间件验证的令牌声明，未认证时返回nil，ctx可以是*gin.Context或请求context
+func GetClaims(ctx context.Context) *jwt.Claims {
+	if c, ok := ctx.(*gin.Context); ok {
+		if v, ok := c.Get(claimsKey); ok {
+			return v.(*jwt.Claims)
+		}
+		if c.Request == nil {
+			return nil
+		}
+		ctx = c.Request.Context()
+	}
+	claims, _ := ctx.Value(claimsCtxKey{}).(*jwt.Claims)
+	return claims
+}
+
+// RequirePermission 要求认证用户有全部指定权限，在JWT之后使用，未认证返回errcode.Unauthorized，无权限返回errcode.Forbidden
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return require(func(claims *jwt.Claims) bool {
+		for _, p := range permissions {
+			if !claims.HasPermission(p) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// RequireRole 要求认证用户有任意一个指定角色，在JWT之后使用
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return require(func(claims *jwt.Claims) bool {
+		for _, role := range roles {
+			if claims.HasRole(role) {
+				return true
+			}
+		}
+		return len(roles) == 0
+	})
+}
+
+func require(allow func(claims *jwt.Claims) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil {
+			unauthorized(c, ErrNoToken)
+			return
+		}
+		if !allow(claims) {
+			reqres.Error(c, errcode.Forbidden.Clone().SetMessage("permission denied"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
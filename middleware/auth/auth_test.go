@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ilaziness/gokit/config"
+	"github.com/ilaziness/gokit/jwt"
+)
+
+var hsKey = &jwt.Key{Algorithm: jwt.HS256, Secret: []byte("secret")}
+
+func sign(t *testing.T, claims *jwt.Claims, key *jwt.Key) string {
+	t.Helper()
+	token, err := jwt.Sign(claims, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func newEngine(cfg JWTConfig) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.ContextWithFallback = true
+	api := e.Group("/", JWT(cfg))
+	api.GET("/me", func(c *gin.Context) {
+		// service中使用派生的context获取声明
+		ctx, cancel := context.WithTimeout(c, time.Second)
+		defer cancel()
+		c.String(http.StatusOK, GetClaims(ctx).Subject)
+	})
+	api.POST("/orders", RequirePermission("order:write"), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	api.DELETE("/users", RequireRole("admin", "ops"), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return e
+}
+
+func do(e *gin.Engine, method, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestJWT(t *testing.T) {
+	v := jwt.NewVerifier(hsKey)
+	v.Leeway = time.Minute
+	e := newEngine(JWTConfig{Verifier: v, Query: "access_token"})
+	now := time.Now()
+
+	valid := sign(t, &jwt.Claims{Subject: "u1", ExpiresAt: now.Add(time.Hour).Unix()}, hsKey)
+	skewed := sign(t, &jwt.Claims{Subject: "u2", ExpiresAt: now.Add(-30 * time.Second).Unix()}, hsKey)
+	expired := sign(t, &jwt.Claims{Subject: "u3", ExpiresAt: now.Add(-time.Hour).Unix()}, hsKey)
+	forged := sign(t, &jwt.Claims{Subject: "u1"}, &jwt.Key{Algorithm: jwt.HS256, Secret: []byte("other")})
+
+	tests := []struct {
+		name      string
+		target    string
+		token     string
+		status    int
+		body      string
+		challenge string
+	}{
+		{"valid", "/me", valid, http.StatusOK, "u1", ""},
+		{"within clock skew", "/me", skewed, http.StatusOK, "u2", ""},
+		{"query token", "/me?access_token=" + valid, "", http.StatusOK, "u1", ""},
+		{"missing", "/me", "", http.StatusUnauthorized, "authentication required", "Bearer"},
+		{"expired", "/me", expired, http.StatusUnauthorized, "token is expired", `Bearer error="invalid_token"`},
+		{"bad signature", "/me", forged, http.StatusUnauthorized, "signature is invalid", `Bearer error="invalid_token"`},
+	}
+	for _, tt := range tests {
+		w := do(e, http.MethodGet, tt.target, tt.token)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+		if w.Code == http.StatusOK {
+			if w.Body.String() != tt.body {
+				t.Errorf("%s: body = %q, want %q", tt.name, w.Body.String(), tt.body)
+			}
+			continue
+		}
+		var res struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &res)
+		if res.Code != 401 || res.Message != tt.body {
+			t.Errorf("%s: response = %+v, want message %q", tt.name, res, tt.body)
+		}
+		if got := w.Header().Get("WWW-Authenticate"); got != tt.challenge {
+			t.Errorf("%s: WWW-Authenticate = %q, want %q", tt.name, got, tt.challenge)
+		}
+	}
+}
+
+func TestRequire(t *testing.T) {
+	e := newEngine(JWTConfig{Verifier: jwt.NewVerifier(hsKey)})
+	writer := sign(t, &jwt.Claims{Subject: "u1", Permissions: []string{"order:*"}, Roles: []string{"ops"}}, hsKey)
+	reader := sign(t, &jwt.Claims{Subject: "u2", Permissions: []string{"order:read"}}, hsKey)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		token  string
+		status int
+	}{
+		{"permission granted", http.MethodPost, "/orders", writer, http.StatusCreated},
+		{"permission denied", http.MethodPost, "/orders", reader, http.StatusForbidden},
+		{"role granted", http.MethodDelete, "/users", writer, http.StatusNoContent},
+		{"role denied", http.MethodDelete, "/users", reader, http.StatusForbidden},
+		{"unauthenticated", http.MethodPost, "/orders", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if w := do(e, tt.method, tt.target, tt.token); w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d, body %s", tt.name, w.Code, tt.status, w.Body.String())
+		}
+	}
+}
+
+func TestJWT_Optional(t *testing.T) {
+	e := newEngine(JWTConfig{Verifier: jwt.NewVerifier(hsKey), Optional: true})
+	if w := do(e, http.MethodPost, "/orders", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("optional without token RequirePermission = %d, want 401", w.Code)
+	}
+	if w := do(e, http.MethodGet, "/me", "bad"); w.Code != http.StatusUnauthorized {
+		t.Errorf("optional with invalid token = %d, want 401", w.Code)
+	}
+}
+
+func TestNewVerifier(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	pkix, _ := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	pemFile := filepath.Join(dir, "pub.pem")
+	_ = os.WriteFile(pemFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}), 0o600)
+	jwksFile := filepath.Join(dir, "jwks.json")
+	_ = os.WriteFile(jwksFile, []byte(`{"keys":[{"kty":"oct","kid":"k2","k":"c2VjcmV0Mg"}]}`), 0o600)
+
+	v, err := NewVerifier(&config.JWT{Algorithm: jwt.RS256, PublicKeyFile: pemFile, JWKSFile: jwksFile, Issuer: "gokit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []*jwt.Key{
+		{Algorithm: jwt.RS256, PrivateKey: priv},
+		{ID: "k2", Algorithm: jwt.HS256, Secret: []byte("secret2")},
+	} {
+		if _, err = v.Verify(sign(t, &jwt.Claims{Issuer: "gokit"}, key)); err != nil {
+			t.Errorf("Verify() with %s key error: %v", key.Algorithm, err)
+		}
+	}
+	if _, err = v.Verify(sign(t, &jwt.Claims{Issuer: "other"}, &jwt.Key{Algorithm: jwt.RS256, PrivateKey: priv})); !errors.Is(err, jwt.ErrIssuerInvalid) {
+		t.Errorf("Verify() issuer error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.JWT
+		wantErr error
+	}{
+		{"no keys", config.JWT{}, jwt.ErrUnknownKey},
+		{"hs without secret", config.JWT{Algorithm: jwt.HS256, JWKSFile: jwksFile}, jwt.ErrUnknownKey},
+		{"hs with public key", config.JWT{Algorithm: jwt.HS256, Secret: "s", PublicKeyFile: pemFile}, jwt.ErrAlgorithmInvalid},
+		{"rs without public key", config.JWT{Algorithm: jwt.RS256}, jwt.ErrUnknownKey},
+		{"rs with secret", config.JWT{Algorithm: jwt.RS256, Secret: "s", PublicKeyFile: pemFile}, jwt.ErrAlgorithmInvalid},
+		{"unknown algorithm", config.JWT{Algorithm: "none", Secret: "s"}, jwt.ErrAlgorithmInvalid},
+		{"default hs", config.JWT{Secret: "s"}, nil},
+		{"default rs", config.JWT{PublicKeyFile: pemFile}, nil},
+		{"default jwks", config.JWT{JWKSFile: jwksFile}, nil},
+	}
+	for _, tt := range tests {
+		if _, err = NewVerifier(&tt.cfg); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: NewVerifier() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// 只配置公钥时，使用空HMAC密钥伪造的HS256令牌不能通过验证
+	v, err = NewVerifier(&config.JWT{PublicKeyFile: pemFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","permissions":["*"]}`))
+	mac := hmac.New(sha256.New, nil)
+	mac.Write([]byte(input))
+	forged := input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if claims, err := v.Verify(forged); err == nil {
+		t.Errorf("Verify() forged token accepted: %+v", claims)
+	}
+}
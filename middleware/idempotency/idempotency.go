@@ -0,0 +1,244 @@
+// Package idempotency 提供基于Idempotency-Key请求头的幂等中间件，重复请求直接返回第一次请求的响应
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ilaziness/gokit/base/errcode"
+	"github.com/ilaziness/gokit/base/reqres"
+	"github.com/ilaziness/gokit/log"
+	"github.com/ilaziness/gokit/middleware/auth"
+)
+
+const (
+	defaultHeader  = "Idempotency-Key"
+	defaultTTL     = 24 * time.Hour
+	defaultLockTTL = time.Minute
+	defaultMaxBody = 1 << 20
+	maxKeyLength   = 255
+	// ReplayedHeader 重放的响应带该响应头
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// skipHeaders 不保存的响应头
+var skipHeaders = []string{"Date", "Content-Length", "Set-Cookie"}
+
+// Config 幂等中间件配置
+type Config struct {
+	// Store 记录存储，默认使用storage/redis.Client的RedisStore
+	Store Store
+	// Header 幂等键请求头，默认Idempotency-Key
+	Header string
+	// Methods 需要幂等处理的请求方法，默认POST、PATCH
+	Methods []string
+	// Required 为true时Methods中的请求必须带幂等键
+	Required bool
+	// Scope 幂等键的范围，如用户ID，不同范围的相同键互不影响
+	//
+	// 默认使用auth.JWT认证的sub，需要在auth.JWT之后使用，未认证的请求不区分范围，
+	// 使用相同键和请求体的不同客户端会得到彼此的响应
+	Scope func(c *gin.Context) string
+	// TTL 响应保存时间，默认24小时
+	TTL time.Duration
+	// LockTTL 处理中记录的过期时间，处理超过该时间后相同键的请求会再次处理，默认1分钟
+	LockTTL time.Duration
+	// MaxBodySize 计算摘要时读取的请求体上限(字节)，超过时返回413，默认1MB
+	MaxBodySize int64
+}
+
+// New 创建幂等中间件
+//
+// 第一个请求处理完成后保存状态码、响应头和响应体，之后相同键和相同请求的请求直接返回保存的响应。
+// 相同键的请求正在处理时返回errcode.Conflict，相同键但请求方法、路径或请求体不同时返回errcode.Unprocessable。
+// 响应状态码为5xx时不保存，客户端可以使用相同键重试。存储不可用时返回错误，不处理请求
+func New(cfg Config) gin.HandlerFunc {
+	if cfg.Store == nil {
+		cfg.Store = NewRedisStore(nil, "")
+	}
+	if cfg.Header == "" {
+		cfg.Header = defaultHeader
+	}
+	if len(cfg.Methods) == 0 {
+		cfg.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultTTL
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = defaultLockTTL
+	}
+	if cfg.Scope == nil {
+		cfg.Scope = subject
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = defaultMaxBody
+	}
+	return cfg.handle
+}
+
+func (cfg *Config) handle(c *gin.Context) {
+	if !slices.Contains(cfg.Methods, c.Request.Method) {
+		c.Next()
+		return
+	}
+	key := c.GetHeader(cfg.Header)
+	if key == "" {
+		if cfg.Required {
+			abort(c, errcode.ReqErr.Clone().SetMessage(cfg.Header+" header is required"))
+			return
+		}
+		c.Next()
+		return
+	}
+	if len(key) > maxKeyLength {
+		abort(c, errcode.ReqErr.Clone().SetMessage(cfg.Header+" header is too long"))
+		return
+	}
+	key = cfg.Scope(c) + ":" + key
+
+	hash, err := requestHash(c, cfg.MaxBodySize)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			abort(c, errcode.ReqErr.Clone().SetMessage("request body is too large").SetHTTPStatus(http.StatusRequestEntityTooLarge))
+			return
+		}
+		abort(c, errcode.ReqErr.Clone().SetMessage(err.Error()))
+		return
+	}
+	token := newToken()
+	locked, err := cfg.Store.Lock(c, key, &Record{Hash: hash, Token: token}, cfg.LockTTL)
+	if err != nil {
+		log.Error(c, "idempotency lock %s error: %s", key, err)
+		abort(c, err)
+		return
+	}
+	if !locked {
+		cfg.replay(c, key, hash)
+		return
+	}
+
+	w := &recorder{ResponseWriter: c.Writer}
+	c.Writer = w
+	saved := false
+	defer func() {
+		// 处理失败或panic时释放锁，允许使用相同键重试
+		if !saved {
+			if err := cfg.Store.Delete(context.WithoutCancel(c), key, token); err != nil {
+				log.Error(c, "idempotency unlock %s error: %s", key, err)
+			}
+		}
+	}()
+	c.Next()
+
+	status := w.Status()
+	if status >= http.StatusInternalServerError {
+		return
+	}
+	rec := &Record{
+		Hash:   hash,
+		Done:   true,
+		Status: status,
+		Header: w.Header().Clone(),
+		Body:   w.body.Bytes(),
+	}
+	for _, h := range skipHeaders {
+		rec.Header.Del(h)
+	}
+	if err = cfg.Store.Save(context.WithoutCancel(c), key, rec, cfg.TTL); err != nil {
+		log.Error(c, "idempotency save %s error: %s", key, err)
+		return
+	}
+	saved = true
+}
+
+// replay 幂等键已存在时，返回保存的响应或错误
+func (cfg *Config) replay(c *gin.Context, key, hash string) {
+	rec, err := cfg.Store.Get(c, key)
+	if err != nil {
+		log.Error(c, "idempotency get %s error: %s", key, err)
+		abort(c, err)
+		return
+	}
+	switch {
+	case rec == nil:
+		// 第一个请求在Lock和Get之间处理失败并释放了锁
+		abort(c, errcode.Conflict.Clone().SetMessage("request with the same idempotency key failed, retry later"))
+	case rec.Hash != hash:
+		abort(c, errcode.Unprocessable.Clone().SetMessage("idempotency key was used with a different request"))
+	case !rec.Done:
+		abort(c, errcode.Conflict.Clone().SetMessage("request with the same idempotency key is being processed"))
+	default:
+		header := c.Writer.Header()
+		for k, v := range rec.Header {
+			header[k] = v
+		}
+		header.Set(ReplayedHeader, "true")
+		c.Writer.WriteHeader(rec.Status)
+		_, _ = c.Writer.Write(rec.Body)
+		c.Abort()
+	}
+}
+
+// subject 默认的幂等键范围，已认证时为JWT的sub
+func subject(c *gin.Context) string {
+	if claims := auth.GetClaims(c); claims != nil {
+		return claims.Subject
+	}
+	return ""
+}
+
+// newToken 生成锁的持有者标识
+func newToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestHash 请求方法、路径和请求体的摘要，请求体最多读取maxBody字节，读取后恢复请求体
+func requestHash(c *gin.Context, maxBody int64) (string, error) {
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		if body, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBody)); err != nil {
+			return "", err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func abort(c *gin.Context, err error) {
+	reqres.Error(c, err)
+	c.Abort()
+}
+
+// recorder 记录写入的响应体
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
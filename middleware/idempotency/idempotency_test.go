@@ -0,0 +1,270 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	redisLib "github.com/redis/go-redis/v9"
+
+	"github.com/ilaziness/gokit/jwt"
+	"github.com/ilaziness/gokit/middleware/auth"
+)
+
+func newEngine(cfg Config, calls *atomic.Int32) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.Use(New(cfg))
+	e.POST("/orders", func(c *gin.Context) {
+		n := calls.Add(1)
+		c.Header("X-Order", strconv.Itoa(int(n)))
+		c.String(http.StatusCreated, "order %d", n)
+	})
+	e.POST("/fail", func(c *gin.Context) {
+		calls.Add(1)
+		c.Status(http.StatusInternalServerError)
+	})
+	e.GET("/orders", func(c *gin.Context) {
+		calls.Add(1)
+		c.Status(http.StatusOK)
+	})
+	return e
+}
+
+func do(e *gin.Engine, method, target, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestNew(t *testing.T) {
+	var calls atomic.Int32
+	e := newEngine(Config{Store: NewMemoryStore()}, &calls)
+
+	w := do(e, http.MethodPost, "/orders", "k1", `{"n":1}`)
+	if w.Code != http.StatusCreated || w.Body.String() != "order 1" || w.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf("first = %d %q", w.Code, w.Body.String())
+	}
+
+	// 相同键和请求返回保存的响应
+	w = do(e, http.MethodPost, "/orders", "k1", `{"n":1}`)
+	if w.Code != http.StatusCreated || w.Body.String() != "order 1" || w.Header().Get("X-Order") != "1" ||
+		w.Header().Get(ReplayedHeader) != "true" {
+		t.Fatalf("replay = %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("handler calls = %d, want 1", n)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		key    string
+		body   string
+		status int
+		called bool
+	}{
+		{"different body", http.MethodPost, "k1", `{"n":2}`, http.StatusUnprocessableEntity, false},
+		{"different key", http.MethodPost, "k2", `{"n":1}`, http.StatusCreated, true},
+		{"no key", http.MethodPost, "", "", http.StatusCreated, true},
+		{"method not handled", http.MethodGet, "k1", "", http.StatusOK, true},
+		{"key too long", http.MethodPost, strings.Repeat("k", maxKeyLength+1), "", http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		before := calls.Load()
+		w = do(e, tt.method, "/orders", tt.key, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+		if called := calls.Load() != before; called != tt.called {
+			t.Errorf("%s: handler called = %v, want %v", tt.name, called, tt.called)
+		}
+	}
+}
+
+func TestNew_Retry(t *testing.T) {
+	var calls atomic.Int32
+	store := NewMemoryStore()
+	e := newEngine(Config{Store: store}, &calls)
+
+	// 5xx响应不保存，可以使用相同键重试
+	for i := 0; i < 2; i++ {
+		if w := do(e, http.MethodPost, "/fail", "k", ""); w.Code != http.StatusInternalServerError {
+			t.Fatalf("request %d = %d", i, w.Code)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("handler calls = %d, want 2", n)
+	}
+
+	// 处理中的请求返回冲突
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/orders", nil)
+	hash, _ := requestHash(c, defaultMaxBody)
+	_, _ = store.Lock(context.Background(), ":busy", &Record{Hash: hash, Token: "t"}, time.Minute)
+	if w := do(e, http.MethodPost, "/orders", "busy", ""); w.Code != http.StatusConflict {
+		t.Errorf("in progress = %d, want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestNew_Required(t *testing.T) {
+	var calls atomic.Int32
+	e := newEngine(Config{
+		Store:    NewMemoryStore(),
+		Required: true,
+		Scope:    func(c *gin.Context) string { return c.GetHeader("X-User") },
+	}, &calls)
+
+	if w := do(e, http.MethodPost, "/orders", "", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("missing key = %d", w.Code)
+	}
+	if w := do(e, http.MethodGet, "/orders", "", ""); w.Code != http.StatusOK {
+		t.Errorf("GET without key = %d", w.Code)
+	}
+
+	// 不同范围的相同键互不影响
+	for _, user := range []string{"a", "b"} {
+		req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+		req.Header.Set("Idempotency-Key", "k")
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "" {
+			t.Errorf("user %s = %d, replayed %q", user, w.Code, w.Header().Get(ReplayedHeader))
+		}
+	}
+}
+
+func TestNew_DefaultScope(t *testing.T) {
+	key := &jwt.Key{Algorithm: jwt.HS256, Secret: []byte("secret")}
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.Use(auth.JWT(auth.JWTConfig{Verifier: jwt.NewVerifier(key)}), New(Config{Store: NewMemoryStore()}))
+	e.POST("/orders", func(c *gin.Context) {
+		c.String(http.StatusCreated, auth.GetClaims(c).Subject)
+	})
+
+	// 默认按认证用户区分范围，不同用户使用相同键和请求体时不会得到彼此的响应
+	for _, user := range []string{"a", "b"} {
+		token, err := jwt.Sign(&jwt.Claims{Subject: user}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader("{}"))
+		req.Header.Set("Idempotency-Key", "k")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, req)
+		if w.Body.String() != user || w.Header().Get(ReplayedHeader) != "" {
+			t.Errorf("user %s = %q, replayed %q", user, w.Body.String(), w.Header().Get(ReplayedHeader))
+		}
+	}
+}
+
+func TestNew_MaxBodySize(t *testing.T) {
+	var calls atomic.Int32
+	e := newEngine(Config{Store: NewMemoryStore(), MaxBodySize: 4}, &calls)
+	if w := do(e, http.MethodPost, "/orders", "k", "12345"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large body = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	if w := do(e, http.MethodPost, "/orders", "k", "1234"); w.Code != http.StatusCreated {
+		t.Errorf("body within limit = %d", w.Code)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler calls = %d, want 1", n)
+	}
+}
+
+func TestMemoryStore_Delete(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	_, _ = s.Lock(ctx, "k", &Record{Hash: "h", Token: "a"}, time.Minute)
+
+	// 锁过期后被其他请求持有，原持有者不能删除
+	_ = s.Delete(ctx, "k", "b")
+	if rec, _ := s.Get(ctx, "k"); rec == nil {
+		t.Fatal("lock deleted by other token")
+	}
+	_ = s.Delete(ctx, "k", "a")
+	if rec, _ := s.Get(ctx, "k"); rec != nil {
+		t.Fatalf("lock not deleted by owner: %+v", rec)
+	}
+
+	// 完成的记录不删除
+	_ = s.Save(ctx, "k", &Record{Hash: "h", Token: "a", Done: true}, time.Minute)
+	_ = s.Delete(ctx, "k", "a")
+	if rec, _ := s.Get(ctx, "k"); rec == nil {
+		t.Fatal("done record deleted")
+	}
+}
+
+func TestMemoryStore_Expire(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	if ok, _ := s.Lock(ctx, "k", &Record{Hash: "h"}, time.Millisecond); !ok {
+		t.Fatal("first lock failed")
+	}
+	if ok, _ := s.Lock(ctx, "k", &Record{Hash: "h"}, time.Minute); ok {
+		t.Fatal("second lock succeeded")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if rec, _ := s.Get(ctx, "k"); rec != nil {
+		t.Fatalf("expired record = %+v", rec)
+	}
+	if ok, _ := s.Lock(ctx, "k", &Record{Hash: "h"}, time.Minute); !ok {
+		t.Fatal("lock after expire failed")
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	client := redisLib.NewClient(&redisLib.Options{Addr: "127.0.0.1:6379"})
+	defer client.Close()
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis not available: %v", err)
+	}
+	s := NewRedisStore(client, "test:idempotency:")
+	key := strconv.FormatInt(time.Now().UnixNano(), 10)
+	defer s.client.Del(ctx, s.prefix+key)
+
+	if rec, err := s.Get(ctx, key); rec != nil || err != nil {
+		t.Fatalf("get missing = %+v, %v", rec, err)
+	}
+	if ok, err := s.Lock(ctx, key, &Record{Hash: "h", Token: "t"}, time.Minute); !ok || err != nil {
+		t.Fatalf("lock = %v, %v", ok, err)
+	}
+	if ok, _ := s.Lock(ctx, key, &Record{Hash: "h"}, time.Minute); ok {
+		t.Fatal("second lock succeeded")
+	}
+	// 只有持有者可以释放锁
+	if err := s.Delete(ctx, key, "other"); err != nil {
+		t.Fatal(err)
+	}
+	if rec, _ := s.Get(ctx, key); rec == nil {
+		t.Fatal("lock deleted by other token")
+	}
+	if err := s.Delete(ctx, key, "t"); err != nil {
+		t.Fatal(err)
+	}
+	if rec, _ := s.Get(ctx, key); rec != nil {
+		t.Fatalf("lock not deleted by owner: %+v", rec)
+	}
+	want := &Record{Hash: "h", Done: true, Status: 201, Header: http.Header{"X-Order": {"1"}}, Body: []byte("ok")}
+	if err := s.Save(ctx, key, want, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := s.Get(ctx, key)
+	if err != nil || !rec.Done || rec.Status != 201 || string(rec.Body) != "ok" || rec.Header.Get("X-Order") != "1" {
+		t.Fatalf("get = %+v, %v", rec, err)
+	}
+}
@@ -0,0 +1,168 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	redisLib "github.com/redis/go-redis/v9"
+
+	"github.com/ilaziness/gokit/storage/redis"
+)
+
+// Record 幂等键对应的请求记录，Done为false时请求正在处理
+type Record struct {
+	// Hash 请求方法、路径和请求体的摘要
+	Hash string `json:"hash"`
+	// Token 处理中记录的持有者标识，只有持有者可以释放锁
+	Token  string      `json:"token,omitempty"`
+	Done   bool        `json:"done"`
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// Store 幂等记录存储
+type Store interface {
+	// Lock 键不存在时保存处理中的记录，返回是否成功，ttl后锁自动释放
+	Lock(ctx context.Context, key string, rec *Record, ttl time.Duration) (bool, error)
+	// Get 读取记录，不存在时返回nil
+	Get(ctx context.Context, key string) (*Record, error)
+	// Save 保存处理完成的记录
+	Save(ctx context.Context, key string, rec *Record, ttl time.Duration) error
+	// Delete 删除token对应的处理中记录，处理失败时释放锁以便重试，锁过期后被其他请求持有时不删除
+	Delete(ctx context.Context, key, token string) error
+}
+
+// RedisStore 使用Redis保存幂等记录，多个服务实例共享
+type RedisStore struct {
+	client *redisLib.Client
+	prefix string
+}
+
+// NewRedisStore 创建Redis存储，client为nil时使用storage/redis.Client，prefix为key前缀，默认idempotency:
+func NewRedisStore(client *redisLib.Client, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = "idempotency:"
+	}
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) redis() *redisLib.Client {
+	if s.client != nil {
+		return s.client
+	}
+	return redis.Client
+}
+
+func (s *RedisStore) Lock(ctx context.Context, key string, rec *Record, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return false, err
+	}
+	return s.redis().SetNX(ctx, s.prefix+key, data, ttl).Result()
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (*Record, error) {
+	data, err := s.redis().Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redisLib.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec := &Record{}
+	if err = json.Unmarshal(data, rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (s *RedisStore) Save(ctx context.Context, key string, rec *Record, ttl time.Duration) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.redis().Set(ctx, s.prefix+key, data, ttl).Err()
+}
+
+// redisDeleteScript 记录的token与ARGV[1]相同且未完成时删除
+var redisDeleteScript = redisLib.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if not v then
+	return 0
+end
+local rec = cjson.decode(v)
+if rec.token == ARGV[1] and not rec.done then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func (s *RedisStore) Delete(ctx context.Context, key, token string) error {
+	return redisDeleteScript.Run(ctx, s.redis(), []string{s.prefix + key}, token).Err()
+}
+
+// MemoryStore 使用内存保存幂等记录，用于开发测试或单实例部署
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]memoryRecord
+}
+
+type memoryRecord struct {
+	rec      Record
+	expireAt time.Time
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]memoryRecord)}
+}
+
+func (s *MemoryStore) Lock(_ context.Context, key string, rec *Record, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.get(key); ok {
+		return false, nil
+	}
+	s.records[key] = memoryRecord{rec: *rec, expireAt: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.get(key); ok {
+		return &r.rec, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, key string, rec *Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = memoryRecord{rec: *rec, expireAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.get(key); ok && r.rec.Token == token && !r.rec.Done {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+// get 读取未过期的记录，调用方需持有锁
+func (s *MemoryStore) get(key string) (memoryRecord, bool) {
+	r, ok := s.records[key]
+	if ok && time.Now().After(r.expireAt) {
+		delete(s.records, key)
+		return r, false
+	}
+	return r, ok
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute 未匹配路由的请求使用的route标签，避免按原始路径产生大量标签值
+const unmatchedRoute = "unmatched"
+
+// otherMethod 非标准请求方法使用的method标签，避免客户端发送任意方法产生大量标签值
+const otherMethod = "other"
+
+// Metrics HTTP请求指标，按路由模板(c.FullPath())、请求方法和状态码统计
+//
+// 指标注册在独立的Registry上，同时包含Go运行时和进程指标，通过Handler以Prometheus格式输出
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inflight *prometheus.GaugeVec
+	size     *prometheus.HistogramVec
+}
+
+// NewMetrics 创建HTTP请求指标
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests.",
+		}, []string{"route", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency in seconds.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		inflight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests being served.",
+		}, []string{"route", "method"}),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "HTTP response body size in bytes.",
+			Buckets: prometheus.ExponentialBuckets(100, 10, 7),
+		}, []string{"route", "method", "status"}),
+	}
+	m.registry.MustRegister(
+		m.requests, m.duration, m.inflight, m.size,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Registry 指标注册表，用于注册业务自定义指标
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Middleware 记录请求数、耗时、处理中的请求数和响应大小
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := methodLabel(c.Request.Method)
+		inflight := m.inflight.WithLabelValues(route, method)
+		inflight.Inc()
+		start := time.Now()
+
+		defer func() {
+			inflight.Dec()
+			status := strconv.Itoa(c.Writer.Status())
+			m.requests.WithLabelValues(route, method, status).Inc()
+			m.duration.WithLabelValues(route, method, status).Observe(time.Since(start).Seconds())
+			m.size.WithLabelValues(route, method, status).Observe(float64(max(c.Writer.Size(), 0)))
+		}()
+		c.Next()
+	}
+}
+
+// methodLabel 请求方法的标签值，标准方法之外的方法使用otherMethod
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return otherMethod
+}
+
+// Handler Prometheus格式的指标接口
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
@@ -0,0 +1,190 @@
+// Package ratelimit 提供基于GCRA算法的HTTP限流中间件，支持Redis分布式限流和内存限流
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	redisLib "github.com/redis/go-redis/v9"
+
+	"github.com/ilaziness/gokit/log"
+	"github.com/ilaziness/gokit/storage/redis"
+)
+
+// Quota 限流配额，Period内平均允许Limit个请求，最多可以突发Burst个请求
+type Quota struct {
+	Limit  int
+	Period time.Duration
+	// Burst 突发请求数，默认等于Limit
+	Burst int
+}
+
+func (q Quota) burst() int {
+	if q.Burst > 0 {
+		return q.Burst
+	}
+	return q.Limit
+}
+
+// interval 两个请求之间的平均间隔
+func (q Quota) interval() time.Duration {
+	return q.Period / time.Duration(q.Limit)
+}
+
+// Result 限流结果
+type Result struct {
+	Allowed bool
+	// Limit 配额上限，即突发请求数
+	Limit int
+	// Remaining 当前剩余可用请求数
+	Remaining int
+	// RetryAfter 被拒绝时需要等待的时间
+	RetryAfter time.Duration
+	// ResetAfter 配额完全恢复需要的时间
+	ResetAfter time.Duration
+}
+
+// Limiter 限流器，key为限流键
+type Limiter interface {
+	Allow(ctx context.Context, key string, q Quota) (Result, error)
+}
+
+// gcra 计算GCRA结果，tat为理论到达时间，返回新的tat
+func gcra(now, tat time.Time, q Quota) (Result, time.Time) {
+	interval := q.interval()
+	tolerance := interval * time.Duration(q.burst())
+	if tat.Before(now) {
+		tat = now
+	}
+	newTAT := tat.Add(interval)
+	allowAt := newTAT.Add(-tolerance)
+	res := Result{Limit: q.burst()}
+	if now.Before(allowAt) {
+		res.RetryAfter = allowAt.Sub(now)
+		res.ResetAfter = tat.Sub(now)
+		return res, tat
+	}
+	res.Allowed = true
+	res.Remaining = int(now.Sub(allowAt) / interval)
+	res.ResetAfter = newTAT.Sub(now)
+	return res, newTAT
+}
+
+// MemoryLimiter 单实例内存限流器，并发安全
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryLimiter 创建内存限流器
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{tats: make(map[string]time.Time), lastSweep: time.Now()}
+}
+
+// memorySweepInterval 清理已恢复配额的键的间隔
+const memorySweepInterval = time.Minute
+
+func (l *MemoryLimiter) Allow(_ context.Context, key string, q Quota) (Result, error) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) > memorySweepInterval {
+		// tat早于当前时间的键与不存在的键状态相同
+		for k, tat := range l.tats {
+			if tat.Before(now) {
+				delete(l.tats, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	res, tat := gcra(now, l.tats[key], q)
+	l.tats[key] = tat
+	return res, nil
+}
+
+// redisGCRAScript KEYS[1]为限流键，ARGV为请求间隔和突发容量(微秒)，使用redis服务器时间避免实例间时钟偏差
+//
+// 返回是否允许、剩余请求数、重试等待时间和配额恢复时间(微秒)
+var redisGCRAScript = redisLib.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local new_tat = tat + interval
+local allow_at = new_tat - tolerance
+if now < allow_at then
+	return {0, 0, allow_at - now, tat - now}
+end
+redis.call('SET', KEYS[1], new_tat, 'PX', math.ceil((new_tat - now) / 1000))
+return {1, math.floor((now - allow_at) / interval), 0, new_tat - now}
+`)
+
+// redisRetryInterval Redis出错后使用内存限流的时间，期间不访问Redis
+const redisRetryInterval = time.Second
+
+// RedisLimiter 基于Redis的分布式限流器，多个服务实例共享配额
+//
+// Redis出错时改用内存限流，每个实例单独计算配额，redisRetryInterval后再尝试Redis，需要Redis 5及以上版本
+type RedisLimiter struct {
+	client   *redisLib.Client
+	prefix   string
+	timeout  time.Duration
+	fallback *MemoryLimiter
+	// downUntil Redis不可用时为恢复尝试的时间(UnixNano)
+	downUntil atomic.Int64
+}
+
+// NewRedisLimiter 创建Redis限流器，client为nil时使用storage/redis.Client，prefix为限流键前缀，默认ratelimit:
+func NewRedisLimiter(client *redisLib.Client, prefix string) *RedisLimiter {
+	if prefix == "" {
+		prefix = "ratelimit:"
+	}
+	return &RedisLimiter{
+		client:   client,
+		prefix:   prefix,
+		timeout:  100 * time.Millisecond,
+		fallback: NewMemoryLimiter(),
+	}
+}
+
+func (l *RedisLimiter) redis() *redisLib.Client {
+	if l.client != nil {
+		return l.client
+	}
+	return redis.Client
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, q Quota) (Result, error) {
+	client := l.redis()
+	if client == nil || time.Now().UnixNano() < l.downUntil.Load() {
+		return l.fallback.Allow(ctx, key, q)
+	}
+	rctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+	tolerance := q.interval() * time.Duration(q.burst())
+	values, err := redisGCRAScript.Run(rctx, client, []string{l.prefix + key},
+		q.interval().Microseconds(), tolerance.Microseconds()).Int64Slice()
+	if err != nil || len(values) != 4 {
+		if l.downUntil.Swap(time.Now().Add(redisRetryInterval).UnixNano()) == 0 {
+			log.Warn(ctx, "redis rate limiter error, fallback to memory: %v", err)
+		}
+		return l.fallback.Allow(ctx, key, q)
+	}
+	if l.downUntil.Swap(0) != 0 {
+		log.Info(ctx, "redis rate limiter recovered")
+	}
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      q.burst(),
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Microsecond,
+		ResetAfter: time.Duration(values[3]) * time.Microsecond,
+	}, nil
+}
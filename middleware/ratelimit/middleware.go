@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ilaziness/gokit/base/errcode"
+	"github.com/ilaziness/gokit/base/reqres"
+	"github.com/ilaziness/gokit/config"
+	"github.com/ilaziness/gokit/log"
+	"github.com/ilaziness/gokit/middleware/auth"
+)
+
+// KeyFunc 限流键函数，返回空字符串时不限流
+type KeyFunc func(c *gin.Context) string
+
+// ClientIP 以客户端IP作为限流键，只有来自gin.Engine.SetTrustedProxies信任的代理的请求才使用X-Forwarded-For
+func ClientIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// UserID 以JWT的sub作为限流键，需要在auth.JWT之后使用，未认证时使用客户端IP
+func UserID(c *gin.Context) string {
+	if claims := auth.GetClaims(c); claims != nil && claims.Subject != "" {
+		return "user:" + claims.Subject
+	}
+	return ClientIP(c)
+}
+
+// APIKey 以请求头中的API Key作为限流键，header为空时使用X-API-Key，没有API Key时使用客户端IP
+//
+// 不验证API Key，每个不同的值单独计算配额，需要在验证API Key的中间件之后使用
+func APIKey(header string) KeyFunc {
+	if header == "" {
+		header = "X-API-Key"
+	}
+	return func(c *gin.Context) string {
+		if key := c.GetHeader(header); key != "" {
+			return "key:" + key
+		}
+		return ClientIP(c)
+	}
+}
+
+// Route 以路由模板作为限流键，同一路由的全部请求共享配额
+func Route(c *gin.Context) string {
+	return "route:" + c.FullPath()
+}
+
+// Config 限流中间件配置
+type Config struct {
+	// Limiter 限流器，默认使用内存限流器
+	Limiter Limiter
+	// Key 限流键函数，默认ClientIP
+	Key KeyFunc
+	// Default 没有单独配置的路由使用的配额，Limit为0时不限流，全部路由共享该配额
+	Default Quota
+	// Routes 路由的单独配额，key为方法和路由模板，如"GET /orders/:id"，或只有路由模板，每个路由单独计算
+	Routes map[string]Quota
+}
+
+// FromConfig 由配置生成中间件配置
+//
+// 配置的限流中间件在认证之前执行，不支持user限流键，配置为user时使用客户端IP并记录警告
+func FromConfig(cfg *config.RateLimit) Config {
+	quota := func(limit, period, burst int) Quota {
+		if period <= 0 {
+			period = 1
+		}
+		return Quota{Limit: limit, Period: time.Duration(period) * time.Second, Burst: burst}
+	}
+	c := Config{
+		Default: quota(cfg.Limit, cfg.Period, cfg.Burst),
+		Routes:  make(map[string]Quota),
+	}
+	if cfg.Store == "redis" {
+		c.Limiter = NewRedisLimiter(nil, "")
+	}
+	switch cfg.Key {
+	case "", "ip":
+	case "api_key":
+		c.Key = APIKey(cfg.APIKeyHeader)
+	case "route":
+		c.Key = Route
+	default:
+		log.Warn(context.Background(), "unsupported rate limit key %q, use client ip", cfg.Key)
+	}
+	for _, r := range cfg.Routes {
+		c.Routes[r.Route] = quota(r.Limit, r.Period, r.Burst)
+	}
+	return c
+}
+
+// New 创建限流中间件，超出配额时返回errcode.TooManyRequests
+//
+// 响应带RateLimit-Limit、RateLimit-Remaining、RateLimit-Reset、RateLimit-Policy头，被限流时带Retry-After头
+func New(cfg Config) gin.HandlerFunc {
+	if cfg.Limiter == nil {
+		cfg.Limiter = NewMemoryLimiter()
+	}
+	if cfg.Key == nil {
+		cfg.Key = ClientIP
+	}
+	return func(c *gin.Context) {
+		quota, scope := cfg.quota(c)
+		if quota.Limit <= 0 || quota.Period <= 0 {
+			c.Next()
+			return
+		}
+		key := cfg.Key(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		res, err := cfg.Limiter.Allow(c, scope+"|"+key, quota)
+		if err != nil {
+			// 限流器故障时放行，避免影响服务可用性
+			log.Warn(c, "rate limiter error: %s", err)
+			c.Next()
+			return
+		}
+		setHeaders(c, quota, res)
+		if !res.Allowed {
+			c.Header("Retry-After", strconv.Itoa(seconds(res.RetryAfter)))
+			reqres.Error(c, errcode.TooManyRequests)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// quota 请求使用的配额和配额范围，路由配额的范围为路由，默认配额的范围为*
+func (cfg *Config) quota(c *gin.Context) (Quota, string) {
+	route := c.FullPath()
+	if route != "" {
+		for _, name := range []string{c.Request.Method + " " + route, route} {
+			if q, ok := cfg.Routes[name]; ok {
+				return q, name
+			}
+		}
+	}
+	return cfg.Default, "*"
+}
+
+func setHeaders(c *gin.Context, q Quota, res Result) {
+	c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(seconds(res.ResetAfter)))
+	c.Header("RateLimit-Policy", strconv.Itoa(res.Limit)+";w="+strconv.Itoa(seconds(q.Period*time.Duration(res.Limit)/time.Duration(q.Limit))))
+}
+
+// seconds 向上取整的秒数
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,212 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	redisLib "github.com/redis/go-redis/v9"
+
+	"github.com/ilaziness/gokit/config"
+	"github.com/ilaziness/gokit/log"
+)
+
+// TestMain 日志写入临时目录，不在包目录下生成日志文件
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gokit-log")
+	if err != nil {
+		panic(err)
+	}
+	log.SetFile(filepath.Join(dir, "app.log"))
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestGCRA(t *testing.T) {
+	q := Quota{Limit: 10, Period: time.Second, Burst: 3}
+	now := time.Now()
+	var tat time.Time
+	var res Result
+
+	// 突发3个请求后被拒绝
+	for i := 0; i < 3; i++ {
+		res, tat = gcra(now, tat, q)
+		if !res.Allowed || res.Remaining != 2-i || res.Limit != 3 {
+			t.Fatalf("request %d = %+v", i, res)
+		}
+	}
+	res, tat = gcra(now, tat, q)
+	if res.Allowed || res.RetryAfter != 100*time.Millisecond || res.ResetAfter != 300*time.Millisecond {
+		t.Fatalf("request over burst = %+v", res)
+	}
+
+	// 一个间隔后恢复一个请求
+	now = now.Add(100 * time.Millisecond)
+	if res, tat = gcra(now, tat, q); !res.Allowed || res.Remaining != 0 {
+		t.Fatalf("request after interval = %+v", res)
+	}
+	// 空闲足够长时间后配额完全恢复
+	now = now.Add(time.Second)
+	if res, _ = gcra(now, tat, q); !res.Allowed || res.Remaining != 2 {
+		t.Fatalf("request after idle = %+v", res)
+	}
+}
+
+func newEngine(cfg Config) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.Use(New(cfg))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	e.GET("/orders", ok)
+	e.POST("/orders", ok)
+	e.GET("/users/:id", ok)
+	return e
+}
+
+func do(e *gin.Engine, method, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestNew(t *testing.T) {
+	e := newEngine(Config{
+		Default: Quota{Limit: 2, Period: time.Minute},
+		Routes: map[string]Quota{
+			"POST /orders": {Limit: 1, Period: time.Minute},
+			"/users/:id":   {Limit: 3, Period: time.Minute},
+		},
+	})
+
+	w := do(e, http.MethodGet, "/orders")
+	if w.Code != http.StatusOK {
+		t.Fatalf("first request = %d", w.Code)
+	}
+	for name, want := range map[string]string{
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "1",
+		"RateLimit-Reset":     "30",
+		"RateLimit-Policy":    "2;w=60",
+	} {
+		if got := w.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	do(e, http.MethodGet, "/orders")
+	w = do(e, http.MethodGet, "/orders")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" {
+		t.Fatalf("over quota = %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	// 路由单独计算配额
+	if w = do(e, http.MethodPost, "/orders"); w.Code != http.StatusOK {
+		t.Errorf("POST /orders = %d", w.Code)
+	}
+	if w = do(e, http.MethodPost, "/orders"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("second POST /orders = %d", w.Code)
+	}
+	for i := 0; i < 3; i++ {
+		// 不同路径匹配同一路由模板，共享配额
+		if w = do(e, http.MethodGet, "/users/"+strconv.Itoa(i)); w.Code != http.StatusOK {
+			t.Errorf("GET /users/%d = %d", i, w.Code)
+		}
+	}
+	if w = do(e, http.MethodGet, "/users/9"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("GET /users over quota = %d", w.Code)
+	}
+}
+
+func TestKeyFunc(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     KeyFunc
+		headers [][]string
+		allowed []bool
+	}{
+		{"client ip", ClientIP, [][]string{{"X-Forwarded-For", "1.1.1.1"}, {"X-Forwarded-For", "1.1.1.1"}, {"X-Forwarded-For", "2.2.2.2"}}, []bool{true, false, true}},
+		{"api key", APIKey(""), [][]string{{"X-API-Key", "a"}, {"X-API-Key", "b"}, {"X-API-Key", "a"}}, []bool{true, true, false}},
+		{"route", Route, [][]string{{"X-API-Key", "a"}, {"X-API-Key", "b"}}, []bool{true, false}},
+		{"skip", func(*gin.Context) string { return "" }, [][]string{nil, nil}, []bool{true, true}},
+	}
+	for _, tt := range tests {
+		e := newEngine(Config{Key: tt.key, Default: Quota{Limit: 1, Period: time.Minute}})
+		for i, h := range tt.headers {
+			w := do(e, http.MethodGet, "/orders", h...)
+			if got := w.Code == http.StatusOK; got != tt.allowed[i] {
+				t.Errorf("%s: request %d status = %d", tt.name, i, w.Code)
+			}
+		}
+	}
+}
+
+func TestRedisLimiter_Fallback(t *testing.T) {
+	client := redisLib.NewClient(&redisLib.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+	l := NewRedisLimiter(client, "test:")
+	q := Quota{Limit: 1, Period: time.Minute}
+
+	res, err := l.Allow(context.Background(), "k", q)
+	if err != nil || !res.Allowed {
+		t.Fatalf("first = %+v, %v", res, err)
+	}
+	if l.downUntil.Load() == 0 {
+		t.Error("redis not marked down")
+	}
+	// 内存限流仍然生效
+	if res, _ = l.Allow(context.Background(), "k", q); res.Allowed {
+		t.Errorf("second = %+v, want rejected by fallback", res)
+	}
+}
+
+func TestRedisLimiter(t *testing.T) {
+	client := redisLib.NewClient(&redisLib.Options{Addr: "127.0.0.1:6379"})
+	defer client.Close()
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis not available: %v", err)
+	}
+	l := NewRedisLimiter(client, "test:ratelimit:")
+	key := strconv.FormatInt(time.Now().UnixNano(), 10)
+	q := Quota{Limit: 2, Period: time.Minute}
+	for i, want := range []bool{true, true, false} {
+		res, err := l.Allow(ctx, key, q)
+		if err != nil || res.Allowed != want {
+			t.Fatalf("request %d = %+v, %v", i, res, err)
+		}
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	cfg := FromConfig(&config.RateLimit{
+		Key:    "api_key",
+		Limit:  10,
+		Routes: []config.RouteRateLimit{{Route: "POST /orders", Limit: 5, Period: 60, Burst: 2}},
+	})
+	if cfg.Default != (Quota{Limit: 10, Period: time.Second}) {
+		t.Errorf("default quota = %+v", cfg.Default)
+	}
+	if q := cfg.Routes["POST /orders"]; q != (Quota{Limit: 5, Period: time.Minute, Burst: 2}) {
+		t.Errorf("route quota = %+v", q)
+	}
+	if cfg.Limiter != nil || cfg.Key == nil {
+		t.Errorf("limiter %v, key %v", cfg.Limiter, cfg.Key)
+	}
+	if _, ok := FromConfig(&config.RateLimit{Store: "redis"}).Limiter.(*RedisLimiter); !ok {
+		t.Error("redis store not used")
+	}
+	// 全局限流在认证之前执行，user限流键使用客户端IP
+	if key := FromConfig(&config.RateLimit{Key: "user"}).Key; key != nil {
+		t.Error("user key should fall back to client ip")
+	}
+}
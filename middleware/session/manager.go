@@ -0,0 +1,250 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ilaziness/gokit/log"
+)
+
+const (
+	defaultCookieName      = "session"
+	defaultIdleTimeout     = 30 * time.Minute
+	defaultAbsoluteTimeout = 24 * time.Hour
+	// maxCookieSize 浏览器对单个cookie的大小限制
+	maxCookieSize = 4096
+)
+
+// errInvalidCookie cookie格式错误或签名不匹配
+var errInvalidCookie = errors.New("invalid session cookie")
+
+// Options 会话配置
+type Options struct {
+	// Secret cookie签名密钥，必填
+	Secret string
+	// Store 服务端存储，为nil时会话数据保存在签名的cookie中，大小受cookie 4KB限制
+	Store Store
+	// CookieName 默认session
+	CookieName string
+	// Path 默认/
+	Path   string
+	Domain string
+	Secure bool
+	// SameSite 默认Lax
+	SameSite http.SameSite
+	// IdleTimeout 空闲超时，超过该时间没有请求会话失效，默认30分钟
+	IdleTimeout time.Duration
+	// AbsoluteTimeout 会话从创建开始的最长有效期，默认24小时
+	AbsoluteTimeout time.Duration
+}
+
+// Manager 会话管理
+type Manager struct {
+	opts Options
+}
+
+// New 创建会话管理，Secret为空时panic
+func New(opts Options) *Manager {
+	if opts.Secret == "" {
+		panic("session: secret is required")
+	}
+	if opts.CookieName == "" {
+		opts.CookieName = defaultCookieName
+	}
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = defaultIdleTimeout
+	}
+	if opts.AbsoluteTimeout <= 0 {
+		opts.AbsoluteTimeout = defaultAbsoluteTimeout
+	}
+	return &Manager{opts: opts}
+}
+
+// Middleware 会话中间件，读取cookie中的会话，在响应写入前保存修改
+func (m *Manager) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s := m.load(c)
+		c.Set(contextKey, s)
+		w := &writer{ResponseWriter: c.Writer, save: func() { m.save(c, s) }}
+		c.Writer = w
+		c.Next()
+		// 处理函数没有写入响应体时保存
+		w.commit()
+	}
+}
+
+// load 读取会话，cookie无效、会话不存在或已过期时创建新会话
+func (m *Manager) load(c *gin.Context) *Session {
+	now := time.Now()
+	cookie, err := c.Cookie(m.opts.CookieName)
+	if err != nil || cookie == "" {
+		return newSession(now)
+	}
+	rec, err := m.decode(c, cookie)
+	if err != nil {
+		if !errors.Is(err, errInvalidCookie) && !errors.Is(err, ErrNotFound) {
+			log.Warn(c, "load session error: %s", err)
+		}
+		return newSession(now)
+	}
+	if now.Sub(time.Unix(rec.AccessedAt, 0)) > m.opts.IdleTimeout ||
+		now.Sub(time.Unix(rec.CreatedAt, 0)) > m.opts.AbsoluteTimeout {
+		m.delete(c, rec.ID)
+		return newSession(now)
+	}
+	return fromRecord(rec)
+}
+
+func (m *Manager) decode(c *gin.Context, cookie string) (*record, error) {
+	payload, err := m.verify(cookie)
+	if err != nil {
+		return nil, err
+	}
+	if m.opts.Store != nil {
+		if payload, err = m.opts.Store.Load(c, string(payload)); err != nil {
+			return nil, err
+		}
+	}
+	rec := &record{}
+	if err = json.Unmarshal(payload, rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// save 保存会话并设置cookie，已存在的会话每次请求都保存以更新空闲时间
+func (m *Manager) save(c *gin.Context, s *Session) {
+	if s.destroyed {
+		m.delete(c, s.oldID)
+		if !s.isNew {
+			m.delete(c, s.id)
+		}
+		m.setCookie(c, "", -1)
+		return
+	}
+	if s.isNew && !s.modified {
+		return
+	}
+	if s.oldID != "" {
+		m.delete(c, s.oldID)
+	}
+
+	now := time.Now()
+	s.accessedAt = now
+	remaining := s.createdAt.Add(m.opts.AbsoluteTimeout).Sub(now)
+	data, err := json.Marshal(s.record())
+	if err != nil {
+		log.Error(c, "encode session error: %s", err)
+		return
+	}
+	payload := data
+	if m.opts.Store != nil {
+		if err = m.opts.Store.Save(c, s.id, data, min(m.opts.IdleTimeout, remaining)); err != nil {
+			log.Error(c, "save session error: %s", err)
+			return
+		}
+		payload = []byte(s.id)
+	}
+	value := m.sign(payload)
+	if len(value) > maxCookieSize {
+		log.Error(c, "session cookie size %d exceeds %d bytes, use a server side store", len(value), maxCookieSize)
+		return
+	}
+	m.setCookie(c, value, int(remaining.Seconds()))
+}
+
+func (m *Manager) delete(c *gin.Context, id string) {
+	if m.opts.Store == nil || id == "" {
+		return
+	}
+	if err := m.opts.Store.Delete(c, id); err != nil {
+		log.Error(c, "delete session error: %s", err)
+	}
+}
+
+func (m *Manager) setCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     m.opts.CookieName,
+		Value:    value,
+		Path:     m.opts.Path,
+		Domain:   m.opts.Domain,
+		MaxAge:   maxAge,
+		Secure:   m.opts.Secure,
+		HttpOnly: true,
+		SameSite: m.opts.SameSite,
+	})
+}
+
+// sign 生成cookie值 base64(payload).base64(hmac)，签名包含cookie名，防止不同cookie的值互换
+func (m *Manager) sign(payload []byte) string {
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(m.mac(encoded))
+}
+
+func (m *Manager) verify(cookie string) ([]byte, error) {
+	encoded, sig, ok := strings.Cut(cookie, ".")
+	if !ok {
+		return nil, errInvalidCookie
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, m.mac(encoded)) {
+		return nil, errInvalidCookie
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidCookie
+	}
+	return payload, nil
+}
+
+func (m *Manager) mac(encoded string) []byte {
+	h := hmac.New(sha256.New, []byte(m.opts.Secret))
+	h.Write([]byte(m.opts.CookieName + "|" + encoded))
+	return h.Sum(nil)
+}
+
+// writer 在响应头写入前保存会话，保证Set-Cookie能写入响应
+type writer struct {
+	gin.ResponseWriter
+	once sync.Once
+	save func()
+}
+
+func (w *writer) commit() {
+	w.once.Do(w.save)
+}
+
+func (w *writer) WriteHeaderNow() {
+	w.commit()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *writer) Write(data []byte) (int, error) {
+	w.commit()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *writer) WriteString(s string) (int, error) {
+	w.commit()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *writer) Flush() {
+	w.commit()
+	w.ResponseWriter.Flush()
+}
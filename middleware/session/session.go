@@ -0,0 +1,178 @@
+// Package session 提供基于签名cookie的会话中间件，会话数据可以保存在cookie中或Redis等存储中
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contextKey 会话在gin.Context中的key
+const contextKey = "gokit/session"
+
+// flashKey 闪存消息使用的key
+const flashKey = "_flashes"
+
+// Session 当前请求的会话，使用Get获取，只在处理请求的goroutine中使用
+//
+// 修改后在响应写入前自动保存，未修改的新会话不会设置cookie
+type Session struct {
+	id         string
+	values     map[string]json.RawMessage
+	createdAt  time.Time
+	accessedAt time.Time
+	isNew      bool
+	modified   bool
+	destroyed  bool
+	// oldID Regenerate前的会话ID，保存时从存储中删除
+	oldID string
+}
+
+// record 会话序列化后的数据
+type record struct {
+	ID         string                     `json:"id"`
+	Values     map[string]json.RawMessage `json:"values,omitempty"`
+	CreatedAt  int64                      `json:"created_at"`
+	AccessedAt int64                      `json:"accessed_at"`
+}
+
+func newSession(now time.Time) *Session {
+	return &Session{
+		id:         newID(),
+		values:     make(map[string]json.RawMessage),
+		createdAt:  now,
+		accessedAt: now,
+		isNew:      true,
+	}
+}
+
+func fromRecord(rec *record) *Session {
+	s := &Session{
+		id:         rec.ID,
+		values:     rec.Values,
+		createdAt:  time.Unix(rec.CreatedAt, 0),
+		accessedAt: time.Unix(rec.AccessedAt, 0),
+	}
+	if s.values == nil {
+		s.values = make(map[string]json.RawMessage)
+	}
+	return s
+}
+
+func (s *Session) record() *record {
+	return &record{
+		ID:         s.id,
+		Values:     s.values,
+		CreatedAt:  s.createdAt.Unix(),
+		AccessedAt: s.accessedAt.Unix(),
+	}
+}
+
+// Get 获取当前请求的会话，需要先使用Manager.Middleware
+func Get(c *gin.Context) *Session {
+	return c.MustGet(contextKey).(*Session)
+}
+
+// ID 会话ID
+func (s *Session) ID() string {
+	return s.id
+}
+
+// IsNew 是否是本次请求创建的会话
+func (s *Session) IsNew() bool {
+	return s.isNew
+}
+
+// CreatedAt 会话创建时间
+func (s *Session) CreatedAt() time.Time {
+	return s.createdAt
+}
+
+// Set 设置值，v使用JSON序列化
+func (s *Session) Set(key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.values[key] = data
+	s.modified = true
+	return nil
+}
+
+// Has 是否存在key
+func (s *Session) Has(key string) bool {
+	_, ok := s.values[key]
+	return ok
+}
+
+// Delete 删除值
+func (s *Session) Delete(key string) {
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.modified = true
+	}
+}
+
+// Clear 删除全部值，会话ID不变
+func (s *Session) Clear() {
+	if len(s.values) > 0 {
+		s.values = make(map[string]json.RawMessage)
+		s.modified = true
+	}
+}
+
+// Regenerate 更换会话ID并保留数据，登录等权限变化时调用，防止会话固定攻击
+func (s *Session) Regenerate() {
+	if !s.isNew && s.oldID == "" {
+		s.oldID = s.id
+	}
+	s.id = newID()
+	s.modified = true
+}
+
+// Destroy 删除会话数据并清除cookie，退出登录时调用
+func (s *Session) Destroy() {
+	s.values = make(map[string]json.RawMessage)
+	s.destroyed = true
+}
+
+// AddFlash 添加闪存消息，消息在下一次调用Flashes时读取并删除
+func (s *Session) AddFlash(msg string) {
+	flashes := s.flashes()
+	_ = s.Set(flashKey, append(flashes, msg))
+}
+
+// Flashes 读取并删除全部闪存消息
+func (s *Session) Flashes() []string {
+	flashes := s.flashes()
+	s.Delete(flashKey)
+	return flashes
+}
+
+func (s *Session) flashes() []string {
+	flashes, _ := Value[[]string](s, flashKey)
+	return flashes
+}
+
+// Value 获取类型为T的值，不存在或类型不匹配时返回false
+func Value[T any](s *Session, key string) (T, bool) {
+	var v T
+	data, ok := s.values[key]
+	if !ok {
+		return v, false
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, false
+	}
+	return v, true
+}
+
+// newID 生成32字节随机会话ID
+func newID() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,235 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	redisLib "github.com/redis/go-redis/v9"
+)
+
+type profile struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// client 保存cookie，按顺序发送请求
+type client struct {
+	t      *testing.T
+	e      *gin.Engine
+	cookie *http.Cookie
+}
+
+func (c *client) get(path string) *httptest.ResponseRecorder {
+	c.t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if c.cookie != nil {
+		req.AddCookie(c.cookie)
+	}
+	w := httptest.NewRecorder()
+	c.e.ServeHTTP(w, req)
+	for _, ck := range w.Result().Cookies() {
+		if ck.MaxAge < 0 {
+			c.cookie = nil
+		} else {
+			c.cookie = ck
+		}
+	}
+	return w
+}
+
+func newEngine(m *Manager) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	e := gin.New()
+	e.Use(m.Middleware())
+	e.GET("/login", func(c *gin.Context) {
+		s := Get(c)
+		s.Regenerate()
+		_ = s.Set("user", profile{ID: 7, Name: "tom"})
+		s.AddFlash("welcome")
+		c.String(http.StatusOK, s.ID())
+	})
+	e.GET("/me", func(c *gin.Context) {
+		s := Get(c)
+		u, ok := Value[profile](s, "user")
+		if !ok {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		c.String(http.StatusOK, "%s %s %v", s.ID(), u.Name, s.Flashes())
+	})
+	e.GET("/logout", func(c *gin.Context) {
+		Get(c).Destroy()
+		c.Status(http.StatusNoContent)
+	})
+	e.GET("/anonymous", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return e
+}
+
+func TestManager(t *testing.T) {
+	stores := map[string]Store{"cookie": nil, "memory": NewMemoryStore()}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			c := &client{t: t, e: newEngine(New(Options{Secret: "secret", Store: store}))}
+
+			// 未修改的新会话不设置cookie
+			if w := c.get("/anonymous"); len(w.Result().Cookies()) != 0 {
+				t.Fatalf("anonymous set cookie %v", w.Result().Cookies())
+			}
+			if w := c.get("/me"); w.Code != http.StatusUnauthorized {
+				t.Fatalf("GET /me before login = %d", w.Code)
+			}
+
+			w := c.get("/login")
+			id := w.Body.String()
+			if c.cookie == nil || !c.cookie.HttpOnly || c.cookie.SameSite != http.SameSiteLaxMode {
+				t.Fatalf("login cookie = %+v", c.cookie)
+			}
+			if w := c.get("/me"); w.Body.String() != id+" tom [welcome]" {
+				t.Fatalf("GET /me = %q", w.Body.String())
+			}
+			// 闪存消息只读取一次
+			if w := c.get("/me"); w.Body.String() != id+" tom []" {
+				t.Fatalf("GET /me again = %q", w.Body.String())
+			}
+
+			// 重新登录更换会话ID，旧会话失效
+			old := c.cookie
+			newID := c.get("/login").Body.String()
+			if newID == id {
+				t.Fatal("session id not regenerated")
+			}
+			if store != nil {
+				if _, err := store.Load(context.Background(), id); !errors.Is(err, ErrNotFound) {
+					t.Errorf("old session still in store: %v", err)
+				}
+				replay := &client{t: t, e: c.e, cookie: old}
+				if w := replay.get("/me"); w.Code != http.StatusUnauthorized {
+					t.Errorf("GET /me with old cookie = %d", w.Code)
+				}
+			}
+
+			if w := c.get("/logout"); w.Code != http.StatusNoContent || c.cookie != nil {
+				t.Fatalf("logout = %d, cookie %v", w.Code, c.cookie)
+			}
+			if store != nil {
+				if _, err := store.Load(context.Background(), newID); !errors.Is(err, ErrNotFound) {
+					t.Errorf("destroyed session still in store: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestManager_InvalidCookie(t *testing.T) {
+	m := New(Options{Secret: "secret"})
+	c := &client{t: t, e: newEngine(m)}
+	c.get("/login")
+
+	tampered := *c.cookie
+	payload, sig, _ := strings.Cut(tampered.Value, ".")
+	tampered.Value = payload + "x." + sig
+	other := &client{t: t, e: newEngine(New(Options{Secret: "other"})), cookie: c.cookie}
+	for name, cl := range map[string]*client{
+		"tampered":   {t: t, e: c.e, cookie: &tampered},
+		"other key":  other,
+		"no sig":     {t: t, e: c.e, cookie: &http.Cookie{Name: defaultCookieName, Value: payload}},
+		"bad base64": {t: t, e: c.e, cookie: &http.Cookie{Name: defaultCookieName, Value: "!!.!!"}},
+	} {
+		if w := cl.get("/me"); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s cookie GET /me = %d", name, w.Code)
+		}
+	}
+}
+
+func TestManager_Expiry(t *testing.T) {
+	tests := []struct {
+		name     string
+		created  time.Duration
+		accessed time.Duration
+		valid    bool
+	}{
+		{"active", -time.Hour, -time.Minute, true},
+		{"idle", -time.Hour, -31 * time.Minute, false},
+		{"absolute", -25 * time.Hour, -time.Minute, false},
+	}
+	store := NewMemoryStore()
+	m := New(Options{Secret: "secret", Store: store})
+	e := newEngine(m)
+	for _, tt := range tests {
+		now := time.Now()
+		s := newSession(now)
+		_ = s.Set("user", profile{Name: "tom"})
+		rec := s.record()
+		rec.CreatedAt = now.Add(tt.created).Unix()
+		rec.AccessedAt = now.Add(tt.accessed).Unix()
+		data, _ := json.Marshal(rec)
+		_ = store.Save(context.Background(), s.id, data, time.Hour)
+
+		c := &client{t: t, e: e, cookie: &http.Cookie{Name: defaultCookieName, Value: m.sign([]byte(s.id))}}
+		w := c.get("/me")
+		if got := w.Code == http.StatusOK; got != tt.valid {
+			t.Errorf("%s: GET /me = %d, want valid %v", tt.name, w.Code, tt.valid)
+		}
+		if !tt.valid {
+			if _, err := store.Load(context.Background(), s.id); !errors.Is(err, ErrNotFound) {
+				t.Errorf("%s: expired session not deleted", tt.name)
+			}
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	s := newSession(time.Now())
+	_ = s.Set("count", 3)
+	_ = s.Set("tags", []string{"a", "b"})
+
+	if v, ok := Value[int](s, "count"); !ok || v != 3 {
+		t.Errorf("count = %v %v", v, ok)
+	}
+	if v, ok := Value[[]string](s, "tags"); !ok || len(v) != 2 {
+		t.Errorf("tags = %v %v", v, ok)
+	}
+	if _, ok := Value[string](s, "count"); ok {
+		t.Error("type mismatch returned ok")
+	}
+	if _, ok := Value[int](s, "missing"); ok {
+		t.Error("missing key returned ok")
+	}
+	s.Delete("count")
+	if s.Has("count") {
+		t.Error("count not deleted")
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	client := redisLib.NewClient(&redisLib.Options{Addr: "127.0.0.1:6379"})
+	defer client.Close()
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis not available: %v", err)
+	}
+
+	store := NewRedisStore(client, "test:session:")
+	if err := store.Save(ctx, "id", []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := store.Load(ctx, "id"); err != nil || string(data) != "data" {
+		t.Fatalf("Load = %q, %v", data, err)
+	}
+	if ttl := client.TTL(ctx, "test:session:id").Val(); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("ttl = %s", ttl)
+	}
+	_ = store.Delete(ctx, "id")
+	if _, err := store.Load(ctx, "id"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load after Delete error = %v", err)
+	}
+}
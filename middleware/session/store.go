@@ -0,0 +1,113 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	redisLib "github.com/redis/go-redis/v9"
+
+	"github.com/ilaziness/gokit/storage/redis"
+)
+
+// ErrNotFound 会话不存在或已过期
+var ErrNotFound = errors.New("session not found")
+
+// Store 服务端会话存储，cookie中只保存签名的会话ID
+type Store interface {
+	// Load 读取会话数据，不存在时返回ErrNotFound
+	Load(ctx context.Context, id string) ([]byte, error)
+	// Save 保存会话数据，ttl后过期
+	Save(ctx context.Context, id string, data []byte, ttl time.Duration) error
+	// Delete 删除会话数据
+	Delete(ctx context.Context, id string) error
+}
+
+// RedisStore 使用Redis保存会话数据
+type RedisStore struct {
+	client *redisLib.Client
+	prefix string
+}
+
+// NewRedisStore 创建Redis会话存储，client为nil时使用storage/redis.Client，prefix为key前缀，默认session:
+func NewRedisStore(client *redisLib.Client, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = "session:"
+	}
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) redis() *redisLib.Client {
+	if s.client != nil {
+		return s.client
+	}
+	return redis.Client
+}
+
+func (s *RedisStore) Load(ctx context.Context, id string) ([]byte, error) {
+	data, err := s.redis().Get(ctx, s.prefix+id).Bytes()
+	if errors.Is(err, redisLib.Nil) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *RedisStore) Save(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	return s.redis().Set(ctx, s.prefix+id, data, ttl).Err()
+}
+
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	return s.redis().Del(ctx, s.prefix+id).Err()
+}
+
+// MemoryStore 使用内存保存会话数据，用于开发测试或单实例部署
+type MemoryStore struct {
+	mu    sync.Mutex
+	items map[string]memoryItem
+}
+
+type memoryItem struct {
+	data     []byte
+	expireAt time.Time
+}
+
+// NewMemoryStore 创建内存会话存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[string]memoryItem)}
+}
+
+func (s *MemoryStore) Load(_ context.Context, id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if time.Now().After(item.expireAt) {
+		delete(s.items, id)
+		return nil, ErrNotFound
+	}
+	return item.data, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, id string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	// 保存时顺便清理过期的会话
+	for k, item := range s.items {
+		if now.After(item.expireAt) {
+			delete(s.items, k)
+		}
+	}
+	s.items[id] = memoryItem{data: data, expireAt: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, id)
+	return nil
+}
@@ -0,0 +1,136 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ilaziness/gokit/log"
+)
+
+// certReloadDelay 文件变化后延迟加载，证书和私钥通常先后写入，合并为一次加载
+const certReloadDelay = 200 * time.Millisecond
+
+// CertReloader 证书热加载，证书或私钥文件变化后重新加载，新连接使用新证书
+//
+// 监听文件所在目录，支持直接覆盖、重命名替换和Kubernetes Secret挂载的符号链接切换。
+// 加载失败时继续使用旧证书。通过tls.Config.GetCertificate使用，适用于HTTP、TCP和QUIC服务
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+	watcher  *fsnotify.Watcher
+
+	mu      sync.Mutex // 保护certPEM、keyPEM
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// NewCertReloader 加载证书并开始监听文件变化
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range []string{filepath.Dir(certFile), filepath.Dir(keyFile)} {
+		if err = watcher.Add(dir); err != nil {
+			_ = watcher.Close()
+			return nil, err
+		}
+	}
+	r.watcher = watcher
+	go r.watch()
+	return r, nil
+}
+
+// GetCertificate 返回当前证书，用作tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Reload 重新加载证书，文件内容未变化时不替换
+func (r *CertReloader) Reload() error {
+	_, err := r.load()
+	return err
+}
+
+// load 加载证书，返回证书是否被替换
+func (r *CertReloader) load() (bool, error) {
+	certPEM, err := os.ReadFile(r.certFile)
+	if err != nil {
+		return false, err
+	}
+	keyPEM, err := os.ReadFile(r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if bytes.Equal(certPEM, r.certPEM) && bytes.Equal(keyPEM, r.keyPEM) {
+		return false, nil
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, err
+	}
+	r.cert.Store(&cert)
+	r.certPEM, r.keyPEM = certPEM, keyPEM
+	return true, nil
+}
+
+// Close 停止监听文件变化
+func (r *CertReloader) Close() error {
+	return r.watcher.Close()
+}
+
+func (r *CertReloader) watch() {
+	var timer *time.Timer
+	for {
+		select {
+		case _, ok := <-r.watcher.Events:
+			if !ok {
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			}
+			// 目录中任意文件变化都尝试加载，内容未变化时Reload不做替换
+			if timer == nil {
+				timer = time.AfterFunc(certReloadDelay, r.reload)
+			} else {
+				timer.Reset(certReloadDelay)
+			}
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warn(context.Background(), "watch certificate error: %s", err)
+		}
+	}
+}
+
+func (r *CertReloader) reload() {
+	changed, err := r.load()
+	if err != nil {
+		log.Warn(context.Background(), "reload certificate %s error: %s", r.certFile, err)
+		return
+	}
+	if changed {
+		log.Info(context.Background(), "certificate %s reloaded", r.certFile)
+	}
+}
@@ -0,0 +1,115 @@
+package net
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert 写入序列号为serial的自签名证书，先写临时文件再重命名，与证书管理工具的替换方式相同
+func writeTestCert(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func certSerial(t *testing.T, r *CertReloader) int64 {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.SerialNumber.Int64()
+}
+
+func waitSerial(t *testing.T, r *CertReloader, want int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for certSerial(t, r) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("certificate serial = %d, want %d", certSerial(t, r), want)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeTestCert(t, certFile, keyFile, 1)
+
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewCertReloader() error: %v", err)
+	}
+	defer r.Close()
+	if serial := certSerial(t, r); serial != 1 {
+		t.Fatalf("certificate serial = %d, want 1", serial)
+	}
+
+	writeTestCert(t, certFile, keyFile, 2)
+	waitSerial(t, r, 2)
+
+	// 无效的证书不替换当前证书
+	writeFile(t, certFile, []byte("invalid"))
+	if err = r.Reload(); err == nil {
+		t.Error("Reload() invalid certificate expected error")
+	}
+	time.Sleep(2 * certReloadDelay)
+	if serial := certSerial(t, r); serial != 2 {
+		t.Errorf("certificate serial = %d after invalid update, want 2", serial)
+	}
+
+	writeTestCert(t, certFile, keyFile, 3)
+	waitSerial(t, r, 3)
+}
+
+func TestCertReloader_Missing(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewCertReloader(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")); err == nil {
+		t.Error("NewCertReloader() missing files expected error")
+	}
+}
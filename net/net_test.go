@@ -1,11 +1,27 @@
 package net
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/ilaziness/gokit/log"
 )
 
+// TestMain 日志写入临时目录，不在包目录下生成日志文件
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gokit-log")
+	if err != nil {
+		panic(err)
+	}
+	log.SetFile(filepath.Join(dir, "app.log"))
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestGetInternalIP(t *testing.T) {
 	ip, err := GetInternalIP()
 	if err != nil {
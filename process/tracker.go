@@ -0,0 +1,51 @@
+package process
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// trackerPollInterval Wait检查处理中任务数的间隔
+const trackerPollInterval = 10 * time.Millisecond
+
+// Tracker 统计处理中的任务数，用于优雅关闭时等待任务完成，零值可用，并发安全
+//
+// 与sync.WaitGroup不同，Add可以与Wait同时调用，适合关闭期间仍可能有任务进入的场景
+type Tracker struct {
+	n atomic.Int64
+}
+
+// Add 任务开始
+func (t *Tracker) Add() {
+	t.n.Add(1)
+}
+
+// Done 任务结束
+func (t *Tracker) Done() {
+	t.n.Add(-1)
+}
+
+// Len 处理中的任务数
+func (t *Tracker) Len() int64 {
+	return t.n.Load()
+}
+
+// Wait 等待处理中的任务全部结束，ctx结束时返回ctx.Err()
+func (t *Tracker) Wait(ctx context.Context) error {
+	if t.n.Load() <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(trackerPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if t.n.Load() <= 0 {
+				return nil
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package rocketmq
 import (
 	"context"
 	"errors"
+	"net"
 	"os"
 
 	"github.com/ilaziness/gokit/config"
@@ -15,8 +16,10 @@ import (
 
 var producer rmq.Producer
 var producerStarted bool
+var producerEndpoint string
 
 var ErrReceipt = errors.New("send message receipt empty")
+var ErrProducerNotStarted = errors.New("producer not started")
 
 // InitProducer 初始化rocket mq生产者
 func InitProducer(cfg *config.RocketMq) {
@@ -49,6 +52,7 @@ func InitProducer(cfg *config.RocketMq) {
 		panic(err)
 	}
 	producerStarted = true
+	producerEndpoint = cfg.Endpoint
 	hook.Exit.Register(ProducerStop)
 	log.Logger.Info("queue producer create done")
 }
@@ -61,9 +65,23 @@ func ProducerStop() {
 	if err := producer.GracefulStop(); err != nil {
 		log.Logger.Errorf("producer graceful stop fail: %v", err)
 	}
+	producerStarted = false
 	log.Logger.Info("queue producer stop done")
 }
 
+// ProducerHealth 检查生产者已启动并且能连接到接入点
+func ProducerHealth(ctx context.Context) error {
+	if !producerStarted {
+		return ErrProducerNotStarted
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", producerEndpoint)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
 type MessageOpts struct {
 	Tag       string
 	Keys      []string
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ilaziness/gokit/hook"
+	"github.com/ilaziness/gokit/log"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+// ErrServiceExited 服务未调用Shutdown就退出
+var ErrServiceExited = errors.New("service exited unexpectedly")
+
+// Service 由App统一运行的服务，WebApp、tcp.Server、udp.Server和quic.Server都实现了该接口
+type Service interface {
+	// Serve 监听并提供服务，阻塞直到Shutdown，监听失败或服务异常退出时返回错误
+	Serve() error
+	// Shutdown 停止接收新请求并等待处理中的请求完成，ctx结束时不再等待
+	Shutdown(ctx context.Context) error
+}
+
+type namedService struct {
+	name string
+	svc  Service
+}
+
+type serveResult struct {
+	name string
+	err  error
+}
+
+// App 同时运行多个服务，统一处理退出信号、启动和退出钩子
+//
+// 收到退出信号、调用Stop或任一服务异常退出时，按添加顺序依次关闭全部服务，所有服务共用ShutdownTimeout
+type App struct {
+	// ShutdownTimeout 关闭全部服务的总超时时间，默认10秒
+	ShutdownTimeout time.Duration
+
+	services []namedService
+	stopc    chan struct{}
+	stopOnce sync.Once
+}
+
+// NewApp 创建应用
+func NewApp() *App {
+	return &App{
+		ShutdownTimeout: defaultShutdownTimeout,
+		stopc:           make(chan struct{}),
+	}
+}
+
+// Add 添加服务，name用于日志和错误信息，服务按添加顺序关闭，通常先添加对外提供服务的入口
+func (a *App) Add(name string, svc Service) *App {
+	a.services = append(a.services, namedService{name: name, svc: svc})
+	return a
+}
+
+// Stop 关闭全部服务，与收到退出信号效果相同
+func (a *App) Stop() {
+	a.stopOnce.Do(func() {
+		close(a.stopc)
+	})
+}
+
+// Run 触发启动钩子并启动全部服务，阻塞直到全部服务关闭，然后触发退出钩子
+//
+// 返回服务异常退出和关闭失败的错误，收到退出信号正常关闭时返回nil
+func (a *App) Run() error {
+	hook.Start.Trigger()
+
+	results := make(chan serveResult, len(a.services))
+	for _, s := range a.services {
+		go func() {
+			results <- serveResult{name: s.name, err: s.svc.Serve()}
+		}()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	var err error
+	running := len(a.services)
+	select {
+	case sig := <-quit:
+		log.Logger.Infof("received signal %s, shutdown services ...", sig)
+	case <-a.stopc:
+		log.Logger.Infoln("stop app, shutdown services ...")
+	case r := <-results:
+		running--
+		if r.err == nil {
+			r.err = ErrServiceExited
+		}
+		err = fmt.Errorf("serve %s error: %w", r.name, r.err)
+		log.Logger.Errorf("%s, shutdown services ...", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.ShutdownTimeout)
+	defer cancel()
+	for _, s := range a.services {
+		if shutdownErr := s.svc.Shutdown(ctx); shutdownErr != nil {
+			err = errors.Join(err, fmt.Errorf("shutdown %s error: %w", s.name, shutdownErr))
+		}
+	}
+
+	// 等待其余服务的Serve返回
+wait:
+	for ; running > 0; running-- {
+		select {
+		case r := <-results:
+			if r.err != nil {
+				err = errors.Join(err, fmt.Errorf("serve %s error: %w", r.name, r.err))
+			}
+		case <-ctx.Done():
+			err = errors.Join(err, fmt.Errorf("wait services exit error: %w", ctx.Err()))
+			break wait
+		}
+	}
+
+	hook.Exit.Trigger()
+	log.Logger.Infoln("app exited")
+	return err
+}
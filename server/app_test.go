@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ilaziness/gokit/config"
+	"github.com/ilaziness/gokit/log"
+	"github.com/ilaziness/gokit/server/tcp"
+	"github.com/ilaziness/gokit/server/udp"
+)
+
+// TestMain 日志写入临时目录，不在包目录下生成日志文件
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gokit-log")
+	if err != nil {
+		panic(err)
+	}
+	log.SetFile(filepath.Join(dir, "app.log"))
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// fakeService 记录关闭顺序的测试服务
+type fakeService struct {
+	name     string
+	serveErr error         // 非nil时Serve立即返回该错误
+	block    bool          // Shutdown阻塞到ctx结束
+	order    *[]string     // 关闭顺序
+	mu       *sync.Mutex   // 保护order
+	done     chan struct{} // Shutdown时关闭
+	once     sync.Once
+}
+
+func (s *fakeService) Serve() error {
+	if s.serveErr != nil {
+		return s.serveErr
+	}
+	<-s.done
+	return nil
+}
+
+func (s *fakeService) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	*s.order = append(*s.order, s.name)
+	s.mu.Unlock()
+	if s.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	s.once.Do(func() {
+		close(s.done)
+	})
+	return nil
+}
+
+func TestApp_Run(t *testing.T) {
+	errServe := errors.New("bind failed")
+	tests := []struct {
+		name    string
+		failing string // Serve返回errServe的服务
+		block   string // Shutdown超时的服务
+		stop    bool
+		wantErr error
+	}{
+		{name: "stop", stop: true},
+		{name: "service failed", failing: "b", wantErr: errServe},
+		{name: "shutdown timeout", block: "a", stop: true, wantErr: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order []string
+			var mu sync.Mutex
+			app := NewApp()
+			app.ShutdownTimeout = 100 * time.Millisecond
+			for _, name := range []string{"a", "b", "c"} {
+				svc := &fakeService{name: name, order: &order, mu: &mu, done: make(chan struct{})}
+				if name == tt.failing {
+					svc.serveErr = errServe
+				}
+				svc.block = name == tt.block
+				app.Add(name, svc)
+			}
+			if tt.stop {
+				time.AfterFunc(10*time.Millisecond, app.Stop)
+			}
+
+			start := time.Now()
+			err := app.Run()
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+			if d := time.Since(start); d > time.Second {
+				t.Errorf("Run() took %s, want within ShutdownTimeout", d)
+			}
+			// 全部服务都按添加顺序关闭，即使其中一个已失败或超时
+			if got := len(order); got != 3 || order[0] != "a" || order[1] != "b" || order[2] != "c" {
+				t.Errorf("shutdown order = %v, want [a b c]", order)
+			}
+		})
+	}
+}
+
+func TestApp_RunServers(t *testing.T) {
+	app := NewApp()
+	app.Add("web", NewWeb(&config.App{Mode: gin.ReleaseMode, Port: 0})).
+		Add("tcp", tcp.NewDefaultTCP(&config.TCPServer{Address: "127.0.0.1:0"})).
+		Add("udp", udp.NewDefaultUDP(&config.UDPServer{Address: "127.0.0.1:0"}))
+
+	time.AfterFunc(100*time.Millisecond, app.Stop)
+	if err := app.Run(); err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package quic
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/ilaziness/gokit/jwt"
+)
+
+var ErrNoClientCert = errors.New("no client certificate")
+
+// JWTAuthenticator JWT令牌认证器，令牌可带Bearer前缀
+//
+// sub作为用户ID，name作为用户名，roles作为角色。
+// 过期时间、受众、签发者和时钟偏差在jwt.Verifier上配置，密钥轮换使用Verifier.AddKey和RemoveKey
+type JWTAuthenticator struct {
+	verifier *jwt.Verifier
+}
+
+// NewJWTAuthenticator 创建JWT认证器
+func NewJWTAuthenticator(verifier *jwt.Verifier) *JWTAuthenticator {
+	return &JWTAuthenticator{verifier: verifier}
+}
+
+func (a *JWTAuthenticator) Authenticate(token string) (*User, error) {
+	if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+		token = token[7:]
+	}
+	claims, err := a.verifier.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	return &User{
+		ID:       claims.Subject,
+		Username: claims.Name,
+		Roles:    claims.Roles,
+	}, nil
+}
+
+// CertUserMapper 将已验证的客户端证书映射为用户
+type CertUserMapper func(cert *x509.Certificate) (*User, error)
+
+// CertAuthenticator TLS客户端证书认证器，服务端需配置client_ca_file要求并验证客户端证书
+type CertAuthenticator struct {
+	mapper CertUserMapper
+}
+
+// NewCertAuthenticator 创建客户端证书认证器，mapper为nil时使用DefaultCertUserMapper
+func NewCertAuthenticator(mapper CertUserMapper) *CertAuthenticator {
+	if mapper == nil {
+		mapper = DefaultCertUserMapper
+	}
+	return &CertAuthenticator{mapper: mapper}
+}
+
+// DefaultCertUserMapper 证书CN作为用户ID和用户名，OU作为角色
+func DefaultCertUserMapper(cert *x509.Certificate) (*User, error) {
+	return &User{
+		ID:       cert.Subject.CommonName,
+		Username: cert.Subject.CommonName,
+		Roles:    cert.Subject.OrganizationalUnit,
+	}, nil
+}
+
+// Authenticate 证书认证需要连接信息，不支持令牌认证
+func (a *CertAuthenticator) Authenticate(string) (*User, error) {
+	return nil, ErrNoClientCert
+}
+
+// AuthenticateContext 使用TLS握手时已验证的客户端证书认证
+func (a *CertAuthenticator) AuthenticateContext(ctx *EnhancedContext) (*User, error) {
+	state := ctx.GetConnectionState().TLS
+	if len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return nil, ErrNoClientCert
+	}
+	return a.mapper(state.PeerCertificates[0])
+}
+
+// HasRole 用户是否有指定角色
+func (u *User) HasRole(role string) bool {
+	return slices.Contains(u.Roles, role)
+}
+
+// HasAnyRole 用户是否有任意一个指定角色
+func (u *User) HasAnyRole(roles ...string) bool {
+	for _, role := range roles {
+		if u.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUser 获取AuthMiddleware认证的用户，未认证时返回nil
+func GetUser(ctx context.Context) *User {
+	if ectx := GetEnhancedContext(ctx); ectx != nil {
+		return ectx.User
+	}
+	return nil
+}
+
+// CheckRoles 检查认证用户是否有任意一个指定角色，未认证返回OpCodeUnauthorized错误，无权限返回OpCodeForbidden错误
+func CheckRoles(ctx context.Context, roles ...string) error {
+	user := GetUser(ctx)
+	if user == nil {
+		return NewError(OpCodeUnauthorized, "authentication required")
+	}
+	if len(roles) > 0 && !user.HasAnyRole(roles...) {
+		return NewError(OpCodeForbidden, "permission denied")
+	}
+	return nil
+}
+
+// RequireRoles 角色检查中间件，要求认证用户有任意一个指定角色，需要在EnhancedServer上配合AuthMiddleware使用
+func RequireRoles(roles ...string) Handler {
+	return func(ctx *Context) {
+		if err := CheckRoles(ctx, roles...); err != nil {
+			writeError(ctx, err)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// WithRoles 为单个数据报处理器增加角色检查，用于只保护部分操作码
+func WithRoles(h Handler, roles ...string) Handler {
+	return func(ctx *Context) {
+		if err := CheckRoles(ctx, roles...); err != nil {
+			writeError(ctx, err)
+			return
+		}
+		h(ctx)
+	}
+}
+
+// WithStreamRoles 为单个流处理器增加角色检查
+func WithStreamRoles(h StreamHandler, roles ...string) StreamHandler {
+	return func(ctx *StreamContext) {
+		var e *Error
+		if errors.As(CheckRoles(ctx, roles...), &e) {
+			_ = ctx.WriteWithOpCode(e.Code, []byte(e.Message))
+			return
+		}
+		h(ctx)
+	}
+}
@@ -0,0 +1,158 @@
+package quic
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ilaziness/gokit/config"
+	"github.com/ilaziness/gokit/jwt"
+)
+
+func TestJWTAuthenticator(t *testing.T) {
+	key := &jwt.Key{ID: "k1", Algorithm: jwt.HS256, Secret: []byte("secret")}
+	verifier := jwt.NewVerifier(key)
+	verifier.Audience = "quic"
+
+	server := NewEnhancedServer(newTestConfig(t))
+	server.AddEnhancedMiddleware(NewAuthMiddleware(NewJWTAuthenticator(verifier)))
+	server.AddHandler(1000, WithRoles(func(ctx *Context) {
+		_ = ctx.Write([]byte(GetUser(ctx).Username))
+	}, "admin"))
+	addr := startTestServer(t, server.Server)
+	client := newTestClient(t, &config.QUICClient{})
+
+	sign := func(claims *jwt.Claims) string {
+		token, err := jwt.Sign(claims, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + token
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name  string
+		token string
+		want  OpCode
+	}{
+		{name: "admin", token: sign(&jwt.Claims{Subject: "1", Name: "alice", Roles: []string{"admin"}, Audience: jwt.Audience{"quic"}, ExpiresAt: exp}), want: OpCodeResOK},
+		{name: "no role", token: sign(&jwt.Claims{Subject: "2", Name: "bob", Roles: []string{"user"}, Audience: jwt.Audience{"quic"}, ExpiresAt: exp}), want: OpCodeForbidden},
+		{name: "wrong audience", token: sign(&jwt.Claims{Subject: "1", Roles: []string{"admin"}, Audience: jwt.Audience{"web"}, ExpiresAt: exp}), want: OpCodeUnauthorized},
+		{name: "expired", token: sign(&jwt.Claims{Subject: "1", Roles: []string{"admin"}, Audience: jwt.Audience{"quic"}, ExpiresAt: time.Now().Add(-time.Hour).Unix()}), want: OpCodeUnauthorized},
+		{name: "missing", token: "", want: OpCodeUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pack := &Pack{Head: PackHead{OpCode: 1000}}
+			if tt.token != "" {
+				pack.Metadata = map[string]string{MetadataAuthorization: tt.token}
+			}
+			resp, err := client.Do(context.Background(), addr, pack)
+			if err != nil {
+				t.Fatalf("Do() error: %v", err)
+			}
+			if OpCode(resp.Head.OpCode) != tt.want {
+				t.Errorf("OpCode = %d, want %d (%s)", resp.Head.OpCode, tt.want, resp.Payload)
+			}
+			if tt.want == OpCodeResOK && string(resp.Payload) != "alice" {
+				t.Errorf("payload = %s, want alice", resp.Payload)
+			}
+		})
+	}
+}
+
+// writeTestClientCert 生成自签名客户端证书，同时作为服务端验证客户端证书的CA
+func writeTestClientCert(t *testing.T, cn string, ou []string) (certFile, keyFile string) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: cn, OrganizationalUnit: ou},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "client.crt")
+	keyFile = filepath.Join(dir, "client.key")
+	if err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestCertAuthenticator(t *testing.T) {
+	certFile, keyFile := writeTestClientCert(t, "service-a", []string{"admin", "ops"})
+
+	cfg := newTestConfig(t)
+	cfg.ClientCAFile = certFile
+	server := NewEnhancedServer(cfg)
+	server.AddEnhancedMiddleware(NewAuthMiddleware(NewCertAuthenticator(nil)))
+	server.AddMiddleware(RequireRoles("ops"))
+	server.AddHandler(1000, func(ctx *Context) {
+		user := GetUser(ctx)
+		_ = ctx.Write([]byte(user.ID + ":" + strconv.FormatBool(user.HasRole("admin"))))
+	})
+	addr := startTestServer(t, server.Server)
+
+	client := newTestClient(t, &config.QUICClient{CertFile: certFile, KeyFile: keyFile})
+	resp, err := client.Request(context.Background(), addr, 1000, nil)
+	if err != nil {
+		t.Fatalf("Request() error: %v", err)
+	}
+	if string(resp.Payload) != "service-a:true" {
+		t.Errorf("payload = %s, want service-a:true", resp.Payload)
+	}
+
+	// 没有客户端证书时握手失败
+	noCert := newTestClient(t, &config.QUICClient{})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err = noCert.Request(ctx, addr, 1000, nil); err == nil {
+		t.Error("Request() without client certificate expected error")
+	}
+}
+
+func TestCheckRoles(t *testing.T) {
+	ectx := &EnhancedContext{User: &User{ID: "1", Roles: []string{"user"}}}
+	ctx := context.WithValue(context.Background(), enhancedContextKey{}, ectx)
+
+	if err := CheckRoles(ctx, "user", "admin"); err != nil {
+		t.Errorf("CheckRoles() error: %v", err)
+	}
+	if err := CheckRoles(ctx, "admin"); err == nil || err.(*Error).Code != OpCodeForbidden {
+		t.Errorf("CheckRoles() error = %v, want forbidden", err)
+	}
+	if err := CheckRoles(context.Background()); err == nil || err.(*Error).Code != OpCodeUnauthorized {
+		t.Errorf("CheckRoles() error = %v, want unauthorized", err)
+	}
+}
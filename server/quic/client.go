@@ -0,0 +1,525 @@
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ilaziness/gokit/config"
+	"github.com/ilaziness/gokit/log"
+	"github.com/ilaziness/gokit/process"
+	"github.com/quic-go/quic-go"
+)
+
+const (
+	defaultClientPoolSize  = 1
+	defaultMaxDatagramSize = 1024
+	defaultRequestTimeout  = 5 * time.Second
+)
+
+var (
+	ErrClientClosed   = errors.New("quic client closed")
+	ErrConnClosed     = errors.New("quic connection closed")
+	ErrSQIDMismatched = errors.New("response sqid mismatched")
+)
+
+// PushHandler 处理服务端在单向流上推送的包，addr为服务端地址，同一单向流上的包按发送顺序处理
+type PushHandler func(addr string, pack *Pack)
+
+// Client QUIC客户端，每个目标地址维护一个连接池
+//
+// 请求包不超过MaxDatagramSize时使用数据报发送，否则在新的流上发送，响应通过SQID与请求对应。
+// 连接因空闲超时、网络切换等原因断开后，下次请求时自动重连。
+// 开启Allow0RTT时使用缓存的会话票据0-RTT恢复连接，0-RTT数据可能被重放，只应用于幂等请求。
+// 服务端发起的流按包的操作码分发给AddStreamHandler和AddPushHandler注册的处理器。
+type Client struct {
+	config     *config.QUICClient
+	tlsConfig  *tls.Config
+	quicConfig *quic.Config
+	packCodec  Codec
+	sqid       atomic.Uint32
+
+	handlerMu      sync.RWMutex
+	streamHandlers map[OpCode]StreamHandler
+	pushHandlers   map[OpCode]PushHandler
+
+	mu     sync.Mutex
+	pools  map[string]*connPool
+	closed bool
+}
+
+// NewClient 创建QUIC客户端
+func NewClient(cfg *config.QUICClient) (*Client, error) {
+	tlsConfig, err := newClientTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		config:     cfg,
+		tlsConfig:  tlsConfig,
+		quicConfig: newClientQUICConfig(cfg),
+		packCodec:  NewPackCodec(),
+		pools:      make(map[string]*connPool),
+
+		streamHandlers: make(map[OpCode]StreamHandler),
+		pushHandlers:   make(map[OpCode]PushHandler),
+	}, nil
+}
+
+// AddStreamHandler 添加服务端发起的双向流的处理函数，与服务端的流处理器相同，流上的每个包按操作码分发
+func (c *Client) AddStreamHandler(oc OpCode, h StreamHandler) {
+	c.handlerMu.Lock()
+	defer c.handlerMu.Unlock()
+	c.streamHandlers[oc] = h
+}
+
+// AddPushHandler 添加服务端单向流推送的处理函数
+func (c *Client) AddPushHandler(oc OpCode, h PushHandler) {
+	c.handlerMu.Lock()
+	defer c.handlerMu.Unlock()
+	c.pushHandlers[oc] = h
+}
+
+func newClientTLSConfig(cfg *config.QUICClient) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec // 由配置决定，仅用于测试
+		NextProtos:         []string{nextProto},
+		MinVersion:         tls.VersionTLS13,
+		// 所有连接共用会话缓存，重连时恢复会话，开启0-RTT时用于发送0-RTT数据
+		ClientSessionCache: tls.NewLRUClientSessionCache(0),
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" && cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func newClientQUICConfig(cfg *config.QUICClient) *quic.Config {
+	idleTimeout := defaultIdleTimeout
+	if cfg.IdleTimeout > 0 {
+		idleTimeout = time.Duration(cfg.IdleTimeout) * time.Second
+	}
+	handshakeTimeout := defaultHandshakeTimeout
+	if cfg.HandshakeTimeout > 0 {
+		handshakeTimeout = time.Duration(cfg.HandshakeTimeout) * time.Second
+	}
+	return &quic.Config{
+		MaxIdleTimeout:       idleTimeout,
+		HandshakeIdleTimeout: handshakeTimeout,
+		KeepAlivePeriod:      time.Duration(cfg.KeepAlive) * time.Second,
+		EnableDatagrams:      true,
+	}
+}
+
+// Request 向addr发送请求并等待响应
+func (c *Client) Request(ctx context.Context, addr string, opcode OpCode, payload []byte) (*Pack, error) {
+	return c.Do(ctx, addr, &Pack{
+		Head: PackHead{
+			OpCode:  uint16(opcode),
+			Version: Version1,
+		},
+		Payload: payload,
+	})
+}
+
+// Do 向addr发送请求包并等待响应，按包大小选择数据报或流发送
+//
+// 请求包的SQID由客户端分配，设置了Metadata时使用v2协议
+func (c *Client) Do(ctx context.Context, addr string, pack *Pack) (*Pack, error) {
+	return c.do(ctx, addr, pack, true)
+}
+
+// DoStream 与Do相同，但总是使用流发送，用于只注册了流处理器的操作码
+func (c *Client) DoStream(ctx context.Context, addr string, pack *Pack) (*Pack, error) {
+	return c.do(ctx, addr, pack, false)
+}
+
+func (c *Client) do(ctx context.Context, addr string, pack *Pack, allowDatagram bool) (*Pack, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout())
+		defer cancel()
+	}
+
+	pack.Head.SQID = c.sqid.Add(1)
+	if pack.Head.Version == 0 {
+		pack.Head.Version = Version1
+	}
+	data, err := c.packCodec.Encode(pack)
+	if err != nil {
+		return nil, err
+	}
+
+	for retried := false; ; retried = true {
+		cc, err := c.getConn(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		resp, sent, err := cc.request(ctx, pack.Head.SQID, data, allowDatagram)
+		// 连接已断开且请求未发出时重连后重试一次
+		if err != nil && !sent && cc.closed() && !retried {
+			log.Debug(ctx, "quic connection to %s closed, reconnecting", addr)
+			continue
+		}
+		return resp, err
+	}
+}
+
+// Close 关闭所有连接
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	for _, pool := range c.pools {
+		pool.close()
+	}
+	c.pools = nil
+	return nil
+}
+
+func (c *Client) requestTimeout() time.Duration {
+	if c.config.RequestTimeout > 0 {
+		return time.Duration(c.config.RequestTimeout) * time.Second
+	}
+	return defaultRequestTimeout
+}
+
+func (c *Client) maxDatagramSize() int {
+	if c.config.MaxDatagramSize != 0 {
+		return c.config.MaxDatagramSize
+	}
+	return defaultMaxDatagramSize
+}
+
+func (c *Client) maxFrameSize() int {
+	if c.config.MaxFrameSize > 0 {
+		return c.config.MaxFrameSize
+	}
+	return DefaultMaxFrameSize
+}
+
+// getConn 从addr的连接池中轮询取一个连接，连接不存在或已断开时重新建立
+func (c *Client) getConn(ctx context.Context, addr string) (*clientConn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClientClosed
+	}
+	pool, ok := c.pools[addr]
+	if !ok {
+		size := c.config.PoolSize
+		if size <= 0 {
+			size = defaultClientPoolSize
+		}
+		pool = &connPool{conns: make([]*clientConn, size)}
+		c.pools[addr] = pool
+	}
+	c.mu.Unlock()
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	i := pool.next % len(pool.conns)
+	pool.next++
+	if cc := pool.conns[i]; cc != nil && !cc.closed() {
+		return cc, nil
+	}
+
+	cc, err := c.dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	pool.conns[i] = cc
+	return cc, nil
+}
+
+// dial 建立连接，开启0-RTT时连接在握手完成前即可发送请求
+func (c *Client) dial(ctx context.Context, addr string) (*clientConn, error) {
+	var (
+		conn quic.Connection
+		err  error
+	)
+	if c.config.Allow0RTT {
+		conn, err = quic.DialAddrEarly(ctx, addr, c.tlsConfig, c.quicConfig)
+	} else {
+		conn, err = quic.DialAddr(ctx, addr, c.tlsConfig, c.quicConfig)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cc := &clientConn{
+		conn:            conn,
+		packCodec:       c.packCodec,
+		maxDatagramSize: c.maxDatagramSize(),
+		maxFrameSize:    c.maxFrameSize(),
+		pending:         make(map[uint32]chan *Pack),
+	}
+	process.SafeGo(cc.receiveDatagrams)
+	process.SafeGo(func() {
+		c.acceptStreams(cc)
+	})
+	process.SafeGo(func() {
+		c.acceptUniStreams(cc, addr)
+	})
+	return cc, nil
+}
+
+// acceptStreams 接收服务端发起的双向流直到连接断开
+func (c *Client) acceptStreams(cc *clientConn) {
+	ctx := cc.conn.Context()
+	for {
+		stream, err := cc.conn.AcceptStream(ctx)
+		if err != nil {
+			return
+		}
+		process.SafeGo(func() {
+			c.serveStream(cc, stream)
+		})
+	}
+}
+
+// serveStream 处理服务端发起的双向流，流上的每个包按操作码交给流处理器，未注册的操作码返回OpCodeNotFound
+func (c *Client) serveStream(cc *clientConn, stream quic.Stream) {
+	defer stream.Close()
+
+	ctx := stream.Context()
+	streamCtx := &StreamContext{}
+	streamCtx.Reset(cc.conn, stream, c.packCodec)
+	streamCtx.maxFrameSize = cc.maxFrameSize
+	for {
+		pack, err := streamCtx.Recv()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Warn(ctx, "read server stream error: %s", err)
+				stream.CancelRead(0)
+			}
+			return
+		}
+		streamCtx.SetData(pack)
+
+		c.handlerMu.RLock()
+		h := c.streamHandlers[streamCtx.OpCode]
+		c.handlerMu.RUnlock()
+		if h == nil {
+			_ = streamCtx.WriteNotFound()
+			continue
+		}
+		h(streamCtx)
+	}
+}
+
+// acceptUniStreams 接收服务端发起的单向流直到连接断开
+func (c *Client) acceptUniStreams(cc *clientConn, addr string) {
+	ctx := cc.conn.Context()
+	for {
+		stream, err := cc.conn.AcceptUniStream(ctx)
+		if err != nil {
+			return
+		}
+		process.SafeGo(func() {
+			c.servePushStream(cc, addr, stream)
+		})
+	}
+}
+
+// servePushStream 按顺序将单向流上的包交给推送处理器，未注册的操作码丢弃
+func (c *Client) servePushStream(cc *clientConn, addr string, stream quic.ReceiveStream) {
+	ctx := cc.conn.Context()
+	for {
+		data, err := ReadFrame(stream, cc.maxFrameSize)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Warn(ctx, "read push stream error: %s", err)
+				stream.CancelRead(0)
+			}
+			return
+		}
+		pack, err := c.packCodec.Decode(data)
+		if err != nil {
+			log.Warn(ctx, "decode push packet error: %s", err)
+			stream.CancelRead(0)
+			return
+		}
+
+		c.handlerMu.RLock()
+		h := c.pushHandlers[OpCode(pack.Head.OpCode)]
+		c.handlerMu.RUnlock()
+		if h == nil {
+			log.Warn(ctx, "no push handler for opcode %d from %s", pack.Head.OpCode, addr)
+			continue
+		}
+		h(addr, pack)
+	}
+}
+
+// connPool 同一目标地址的连接池
+type connPool struct {
+	mu    sync.Mutex
+	conns []*clientConn
+	next  int
+}
+
+func (p *connPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, cc := range p.conns {
+		if cc != nil {
+			_ = cc.conn.CloseWithError(0, "client closed")
+		}
+	}
+}
+
+// clientConn 客户端连接，数据报响应按SQID分发给等待的请求
+type clientConn struct {
+	conn            quic.Connection
+	packCodec       Codec
+	maxDatagramSize int
+	maxFrameSize    int
+
+	mu      sync.Mutex
+	pending map[uint32]chan *Pack
+}
+
+func (cc *clientConn) closed() bool {
+	return cc.conn.Context().Err() != nil
+}
+
+// request 发送请求并等待响应，sent表示请求是否已发出
+func (cc *clientConn) request(ctx context.Context, sqid uint32, data []byte, allowDatagram bool) (resp *Pack, sent bool, err error) {
+	if allowDatagram && len(data) <= cc.maxDatagramSize && cc.conn.ConnectionState().SupportsDatagrams && cc.handshakeComplete() {
+		resp, sent, err = cc.requestDatagram(ctx, sqid, data)
+		var tooLarge *quic.DatagramTooLargeError
+		if !errors.As(err, &tooLarge) {
+			return resp, sent, err
+		}
+	}
+	resp, sent, err = cc.requestStream(ctx, sqid, data)
+	if errors.Is(err, quic.Err0RTTRejected) {
+		// 服务端拒绝0-RTT时请求未被处理，关闭连接后使用新的会话票据重连
+		_ = cc.conn.CloseWithError(0, "0-RTT rejected")
+		sent = false
+	}
+	return resp, sent, err
+}
+
+// handshakeComplete 握手是否已完成，0-RTT阶段的数据报可能被服务端丢弃，握手完成前使用流发送
+func (cc *clientConn) handshakeComplete() bool {
+	ec, ok := cc.conn.(quic.EarlyConnection)
+	if !ok {
+		return true
+	}
+	select {
+	case <-ec.HandshakeComplete():
+		return true
+	default:
+		return false
+	}
+}
+
+func (cc *clientConn) requestDatagram(ctx context.Context, sqid uint32, data []byte) (*Pack, bool, error) {
+	ch := make(chan *Pack, 1)
+	cc.mu.Lock()
+	cc.pending[sqid] = ch
+	cc.mu.Unlock()
+	defer func() {
+		cc.mu.Lock()
+		delete(cc.pending, sqid)
+		cc.mu.Unlock()
+	}()
+
+	if err := cc.conn.SendDatagram(data); err != nil {
+		return nil, false, err
+	}
+
+	select {
+	case resp := <-ch:
+		return resp, true, nil
+	case <-ctx.Done():
+		return nil, true, ctx.Err()
+	case <-cc.conn.Context().Done():
+		return nil, true, ErrConnClosed
+	}
+}
+
+func (cc *clientConn) requestStream(ctx context.Context, sqid uint32, data []byte) (*Pack, bool, error) {
+	stream, err := cc.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer stream.CancelRead(0)
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetDeadline(deadline)
+	}
+	if _, err = stream.Write(data); err != nil {
+		return nil, true, err
+	}
+	// 关闭发送方向，服务端处理完当前请求后关闭流
+	if err = stream.Close(); err != nil {
+		return nil, true, err
+	}
+
+	frame, err := ReadFrame(stream, cc.maxFrameSize)
+	if err != nil {
+		return nil, true, err
+	}
+	resp, err := cc.packCodec.Decode(frame)
+	if err != nil {
+		return nil, true, err
+	}
+	if resp.Head.SQID != sqid {
+		return nil, true, ErrSQIDMismatched
+	}
+	return resp, true, nil
+}
+
+// receiveDatagrams 接收数据报响应直到连接断开
+func (cc *clientConn) receiveDatagrams() {
+	ctx := cc.conn.Context()
+	for {
+		data, err := cc.conn.ReceiveDatagram(ctx)
+		if err != nil {
+			return
+		}
+		pack, err := cc.packCodec.Decode(data)
+		if err != nil {
+			log.Warn(ctx, "decode datagram response error: %s", err)
+			continue
+		}
+		cc.mu.Lock()
+		ch, ok := cc.pending[pack.Head.SQID]
+		cc.mu.Unlock()
+		if ok {
+			select {
+			case ch <- pack:
+			default:
+			}
+		}
+	}
+}
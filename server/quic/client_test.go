@@ -0,0 +1,155 @@
+package quic
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ilaziness/gokit/config"
+)
+
+// startEchoServer 启动回显服务，响应payload前缀标明请求来自数据报还是流
+func startEchoServer(t *testing.T, cfg *config.QUICServer) string {
+	server := NewQUIC(cfg)
+	server.AddHandler(1000, func(ctx *Context) {
+		transport := "datagram"
+		if ctx.IsStream() {
+			transport = "stream"
+		}
+		_ = ctx.Write(append([]byte(transport+":"), ctx.Payload...))
+	})
+	return startTestServer(t, server)
+}
+
+func newTestClient(t *testing.T, cfg *config.QUICClient) *Client {
+	cfg.InsecureSkipVerify = true
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient() error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return client
+}
+
+func TestClient_Request(t *testing.T) {
+	addr := startEchoServer(t, newTestConfig(t))
+	client := newTestClient(t, &config.QUICClient{})
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		transport string
+	}{
+		{name: "small", payload: []byte("hello"), transport: "datagram"},
+		{name: "large", payload: bytes.Repeat([]byte("a"), 64*1024), transport: "stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Request(context.Background(), addr, 1000, tt.payload)
+			if err != nil {
+				t.Fatalf("Request() error: %v", err)
+			}
+			want := append([]byte(tt.transport+":"), tt.payload...)
+			if !bytes.Equal(resp.Payload, want) {
+				t.Errorf("response = %.20q..., want %.20q...", resp.Payload, want)
+			}
+		})
+	}
+}
+
+func TestClient_ConcurrentRequests(t *testing.T) {
+	addr := startEchoServer(t, newTestConfig(t))
+	client := newTestClient(t, &config.QUICClient{PoolSize: 2})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			payload := []byte(fmt.Sprintf("request-%d", i))
+			if i%5 == 0 {
+				payload = bytes.Repeat(payload, 500)
+			}
+			resp, err := client.Request(context.Background(), addr, 1000, payload)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !bytes.HasSuffix(resp.Payload, payload) {
+				errs <- fmt.Errorf("request %d got mismatched response", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if n := len(client.pools[addr].conns); n != 2 {
+		t.Errorf("pool size = %d, want 2", n)
+	}
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Allow0RTT = true
+	addr := startEchoServer(t, cfg)
+	client := newTestClient(t, &config.QUICClient{Allow0RTT: true})
+
+	if _, err := client.Request(context.Background(), addr, 1000, []byte("first")); err != nil {
+		t.Fatalf("Request() error: %v", err)
+	}
+	first := client.pools[addr].conns[0]
+	_ = first.conn.CloseWithError(0, "test")
+
+	// 连接断开后自动重连，使用会话票据0-RTT恢复，握手完成前使用流发送
+	resp, err := client.Request(context.Background(), addr, 1000, []byte("second"))
+	if err != nil {
+		t.Fatalf("Request() after close error: %v", err)
+	}
+	if !bytes.HasSuffix(resp.Payload, []byte(":second")) {
+		t.Errorf("response = %s, want suffix :second", resp.Payload)
+	}
+	second := client.pools[addr].conns[0]
+	if second == first {
+		t.Fatal("connection not re-established")
+	}
+	if !second.conn.ConnectionState().Used0RTT {
+		t.Error("reconnection did not use 0-RTT")
+	}
+}
+
+func TestClient_Closed(t *testing.T) {
+	client := newTestClient(t, &config.QUICClient{})
+	_ = client.Close()
+	if _, err := client.Request(context.Background(), "localhost:1", 1000, nil); err != ErrClientClosed {
+		t.Errorf("Request() after Close error = %v, want %v", err, ErrClientClosed)
+	}
+}
+
+func TestClient_DoStream(t *testing.T) {
+	server := NewQUIC(newTestConfig(t))
+	server.AddStreamHandler(2000, func(ctx *StreamContext) {
+		_ = ctx.Write(ctx.Payload)
+	})
+	addr := startTestServer(t, server)
+	client := newTestClient(t, &config.QUICClient{})
+
+	resp, err := client.DoStream(context.Background(), addr, &Pack{
+		Head:     PackHead{OpCode: 2000},
+		Payload:  []byte("hello"),
+		Metadata: map[string]string{MetadataRequestID: "1"},
+	})
+	if err != nil {
+		t.Fatalf("DoStream() error: %v", err)
+	}
+	if string(resp.Payload) != "hello" {
+		t.Errorf("response = %s, want hello", resp.Payload)
+	}
+}
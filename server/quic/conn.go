@@ -0,0 +1,229 @@
+package quic
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/quic-go/quic-go"
+)
+
+// Conn 服务端连接句柄，用于向客户端主动发起流
+//
+// 服务端发起的流与客户端发起的流使用相同的分帧格式，客户端按包的操作码分发：
+// 双向流交给Client.AddStreamHandler注册的处理器，单向流上的每个包交给Client.AddPushHandler注册的处理器
+type Conn struct {
+	id           uint64
+	conn         quic.Connection
+	packCodec    Codec
+	maxFrameSize int
+	sqid         atomic.Uint32
+}
+
+func newConn(conn quic.Connection, pc Codec, maxFrameSize int) *Conn {
+	id, _ := conn.Context().Value(quic.ConnectionTracingKey).(quic.ConnectionTracingID)
+	return &Conn{
+		id:           uint64(id),
+		conn:         conn,
+		packCodec:    pc,
+		maxFrameSize: maxFrameSize,
+	}
+}
+
+// ID 连接ID，进程内唯一
+func (c *Conn) ID() uint64 {
+	return c.id
+}
+
+// RemoteAddr 获取客户端地址
+func (c *Conn) RemoteAddr() string {
+	return c.conn.RemoteAddr().String()
+}
+
+// Context 连接关闭时结束
+func (c *Conn) Context() context.Context {
+	return c.conn.Context()
+}
+
+// Close 关闭连接
+func (c *Conn) Close() error {
+	return c.conn.CloseWithError(0, "closed by server")
+}
+
+// OpenStream 打开双向流，流上的包使用同一个SQID，客户端按第一个包的操作码选择流处理器
+func (c *Conn) OpenStream(ctx context.Context) (*Stream, error) {
+	stream, err := c.conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Stream{
+		SendStream: SendStream{
+			stream:    stream,
+			packCodec: c.packCodec,
+			sqid:      c.sqid.Add(1),
+		},
+		stream:       stream,
+		maxFrameSize: c.maxFrameSize,
+	}, nil
+}
+
+// OpenUniStream 打开单向流，用于推送大文件、事件流等，流上的包按顺序交给客户端的推送处理器
+func (c *Conn) OpenUniStream(ctx context.Context) (*SendStream, error) {
+	stream, err := c.conn.OpenUniStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &SendStream{
+		stream:    stream,
+		packCodec: c.packCodec,
+		sqid:      c.sqid.Add(1),
+	}, nil
+}
+
+// Push 在新的单向流上推送一个包
+func (c *Conn) Push(ctx context.Context, opcode OpCode, data []byte) error {
+	stream, err := c.OpenUniStream(ctx)
+	if err != nil {
+		return err
+	}
+	if err = stream.Send(opcode, data); err != nil {
+		stream.Cancel(0)
+		return err
+	}
+	return stream.Close()
+}
+
+// SendStream 服务端发起的单向流
+type SendStream struct {
+	stream    quic.SendStream
+	packCodec Codec
+	sqid      uint32
+}
+
+// Send 发送一个包
+func (s *SendStream) Send(opcode OpCode, data []byte) error {
+	return s.SendPack(&Pack{
+		Head:    PackHead{OpCode: uint16(opcode)},
+		Payload: data,
+	})
+}
+
+// SendPack 发送一个包，SQID使用流的SQID，可以设置Metadata
+func (s *SendStream) SendPack(pack *Pack) error {
+	pack.Head.SQID = s.sqid
+	if pack.Head.Version == 0 {
+		pack.Head.Version = Version1
+	}
+	data, err := s.packCodec.Encode(pack)
+	if err != nil {
+		return err
+	}
+	_, err = s.stream.Write(data)
+	return err
+}
+
+// Close 关闭流的写方向，客户端读完已发送的数据后流结束
+func (s *SendStream) Close() error {
+	return s.stream.Close()
+}
+
+// Cancel 中止发送，未发送的数据被丢弃
+func (s *SendStream) Cancel(code quic.StreamErrorCode) {
+	s.stream.CancelWrite(code)
+}
+
+// SQID 流上的包使用的SQID
+func (s *SendStream) SQID() uint32 {
+	return s.sqid
+}
+
+// Stream 服务端发起的双向流
+type Stream struct {
+	SendStream
+
+	stream       quic.Stream
+	maxFrameSize int
+}
+
+// Recv 读取客户端发送的下一个包，客户端关闭写方向后返回io.EOF
+func (s *Stream) Recv() (*Pack, error) {
+	data, err := ReadFrame(s.stream, s.maxFrameSize)
+	if err != nil {
+		return nil, err
+	}
+	return s.packCodec.Decode(data)
+}
+
+// Request 发送一个包并等待客户端响应
+func (s *Stream) Request(opcode OpCode, data []byte) (*Pack, error) {
+	if err := s.Send(opcode, data); err != nil {
+		return nil, err
+	}
+	resp, err := s.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if resp.Head.SQID != s.sqid {
+		return nil, ErrSQIDMismatched
+	}
+	return resp, nil
+}
+
+// Cancel 中止流的读写两个方向
+func (s *Stream) Cancel(code quic.StreamErrorCode) {
+	s.stream.CancelRead(code)
+	s.stream.CancelWrite(code)
+}
+
+// ConnRegistry 连接注册表，按连接ID保存服务端的活跃连接，并发安全
+type ConnRegistry struct {
+	mu    sync.RWMutex
+	conns map[uint64]*Conn
+}
+
+func newConnRegistry() *ConnRegistry {
+	return &ConnRegistry{conns: make(map[uint64]*Conn)}
+}
+
+func (r *ConnRegistry) add(c *Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.conns[c.id] = c
+}
+
+func (r *ConnRegistry) remove(c *Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.conns, c.id)
+}
+
+// Get 按ID获取连接
+func (r *ConnRegistry) Get(id uint64) (*Conn, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.conns[id]
+	return c, ok
+}
+
+// Range 遍历所有连接，fn返回false时停止，遍历的是调用时的快照
+func (r *ConnRegistry) Range(fn func(c *Conn) bool) {
+	r.mu.RLock()
+	conns := make([]*Conn, 0, len(r.conns))
+	for _, c := range r.conns {
+		conns = append(conns, c)
+	}
+	r.mu.RUnlock()
+
+	for _, c := range conns {
+		if !fn(c) {
+			return
+		}
+	}
+}
+
+// Len 连接数量
+func (r *ConnRegistry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.conns)
+}
@@ -0,0 +1,108 @@
+package quic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ilaziness/gokit/config"
+)
+
+func TestConn_ServerInitiatedStreams(t *testing.T) {
+	server := NewQUIC(newTestConfig(t))
+	connIDs := make(chan uint64, 1)
+	server.AddHandler(1000, func(ctx *Context) {
+		connIDs <- ctx.Conn().ID()
+		_ = ctx.Write(nil)
+	})
+	addr := startTestServer(t, server)
+
+	client := newTestClient(t, &config.QUICClient{})
+	pushed := make(chan string, 10)
+	client.AddPushHandler(3000, func(_ string, pack *Pack) {
+		pushed <- string(pack.Payload)
+	})
+	client.AddStreamHandler(3001, func(ctx *StreamContext) {
+		_ = ctx.Write(append([]byte("echo:"), ctx.Payload...))
+	})
+
+	// 客户端先建立连接，服务端通过注册表找到连接
+	if _, err := client.Request(context.Background(), addr, 1000, nil); err != nil {
+		t.Fatalf("Request() error: %v", err)
+	}
+	id := <-connIDs
+	conn, ok := server.Conns().Get(id)
+	if !ok {
+		t.Fatalf("connection %d not registered", id)
+	}
+	if n := server.Conns().Len(); n != 1 {
+		t.Errorf("Conns().Len() = %d, want 1", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 单向流推送，同一流上的包按顺序处理
+	if err := conn.Push(ctx, 3000, []byte("event-1")); err != nil {
+		t.Fatalf("Push() error: %v", err)
+	}
+	if got := <-pushed; got != "event-1" {
+		t.Errorf("pushed = %q, want event-1", got)
+	}
+	uni, err := conn.OpenUniStream(ctx)
+	if err != nil {
+		t.Fatalf("OpenUniStream() error: %v", err)
+	}
+	for _, msg := range []string{"chunk-1", "chunk-2", "chunk-3"} {
+		if err = uni.Send(3000, []byte(msg)); err != nil {
+			t.Fatalf("Send() error: %v", err)
+		}
+	}
+	_ = uni.Close()
+	for _, want := range []string{"chunk-1", "chunk-2", "chunk-3"} {
+		select {
+		case got := <-pushed:
+			if got != want {
+				t.Errorf("pushed = %q, want %q", got, want)
+			}
+		case <-ctx.Done():
+			t.Fatal("push timeout")
+		}
+	}
+
+	// 双向流请求客户端处理器
+	stream, err := conn.OpenStream(ctx)
+	if err != nil {
+		t.Fatalf("OpenStream() error: %v", err)
+	}
+	resp, err := stream.Request(3001, []byte("hi"))
+	if err != nil {
+		t.Fatalf("Request() error: %v", err)
+	}
+	if string(resp.Payload) != "echo:hi" {
+		t.Errorf("payload = %q, want echo:hi", resp.Payload)
+	}
+	resp, err = stream.Request(3999, nil)
+	if err != nil {
+		t.Fatalf("Request() error: %v", err)
+	}
+	if OpCode(resp.Head.OpCode) != OpCodeNotFound {
+		t.Errorf("OpCode = %d, want %d", resp.Head.OpCode, OpCodeNotFound)
+	}
+	_ = stream.Close()
+	if _, err = stream.Recv(); !errors.Is(err, io.EOF) {
+		t.Errorf("Recv() after close error = %v, want EOF", err)
+	}
+
+	// 客户端断开后连接从注册表移除
+	_ = client.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for server.Conns().Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok = server.Conns().Get(id); ok {
+		t.Error("connection not removed after client closed")
+	}
+}
@@ -15,6 +15,8 @@ type Context struct {
 	handler   []Handler
 	packCodec Codec
 	conn      quic.Connection
+	handle    *Conn
+	stream    quic.Stream // 流请求时不为nil，响应写入流
 	Pack      *Pack
 	SQID      uint32
 	OpCode    OpCode
@@ -23,13 +25,23 @@ type Context struct {
 
 // Reset 重置上下文
 func (c *Context) Reset(conn quic.Connection, pc Codec) {
+	c.Context = context.Background()
 	c.index = -1
 	c.isAbort = false
 	c.handler = nil
 	c.conn = conn
+	c.handle = nil
+	c.stream = nil
 	c.packCodec = pc
 }
 
+// ResetForStream 为流请求重置上下文，响应写入流
+func (c *Context) ResetForStream(conn quic.Connection, stream quic.Stream, pc Codec) {
+	c.Reset(conn, pc)
+	c.stream = stream
+	c.Context = stream.Context()
+}
+
 // Next 运行中间件
 func (c *Context) Next() {
 	c.index++
@@ -60,7 +72,7 @@ func (c *Context) Write(data []byte) error {
 		},
 		Payload: data,
 	}
-	return c.sendDatagram(pack)
+	return c.send(pack)
 }
 
 // WriteWithOpCode 写入指定操作码响应数据
@@ -73,7 +85,7 @@ func (c *Context) WriteWithOpCode(opcode OpCode, data []byte) error {
 		},
 		Payload: data,
 	}
-	return c.sendDatagram(pack)
+	return c.send(pack)
 }
 
 // ServerErr 写入服务错误响应
@@ -91,22 +103,36 @@ func (c *Context) Abort() {
 	c.isAbort = true
 }
 
+// IsStream 是否为流请求
+func (c *Context) IsStream() bool {
+	return c.stream != nil
+}
+
 // GetRemoteAddr 获取客户端地址
 func (c *Context) GetRemoteAddr() string {
 	return c.conn.RemoteAddr().String()
 }
 
+// Conn 获取连接句柄，用于向客户端主动发起流
+func (c *Context) Conn() *Conn {
+	return c.handle
+}
+
 // GetConnectionState 获取连接状态
 func (c *Context) GetConnectionState() quic.ConnectionState {
 	return c.conn.ConnectionState()
 }
 
-// sendDatagram 发送数据报
-func (c *Context) sendDatagram(pack *Pack) error {
+// send 发送响应，流请求写入流，否则发送数据报
+func (c *Context) send(pack *Pack) error {
 	data, err := c.packCodec.Encode(pack)
 	if err != nil {
 		return err
 	}
+	if c.stream != nil {
+		_, err = c.stream.Write(data)
+		return err
+	}
 	// 使用 quic-go 的 SendDatagram 方法替代 SendMessage
 	return c.conn.SendDatagram(data)
 }
@@ -115,20 +141,28 @@ func (c *Context) sendDatagram(pack *Pack) error {
 type StreamContext struct {
 	context.Context
 
-	packCodec Codec
-	conn      quic.Connection
-	stream    quic.Stream
-	Pack      *Pack
-	SQID      uint32
-	OpCode    OpCode
-	Payload   []byte
+	packCodec    Codec
+	conn         quic.Connection
+	handle       *Conn
+	stream       quic.Stream
+	maxFrameSize int
+	Pack         *Pack
+	SQID         uint32
+	OpCode       OpCode
+	Payload      []byte
 }
 
-// Reset 重置流上下文
+// Reset 重置流上下文，上下文在流的写方向关闭或被对端取消时结束
 func (sc *StreamContext) Reset(conn quic.Connection, stream quic.Stream, pc Codec) {
 	sc.conn = conn
+	sc.handle = nil
 	sc.stream = stream
 	sc.packCodec = pc
+	sc.maxFrameSize = DefaultMaxFrameSize
+	sc.Context = context.Background()
+	if stream != nil {
+		sc.Context = stream.Context()
+	}
 }
 
 // SetData 设置数据
@@ -164,6 +198,32 @@ func (sc *StreamContext) WriteWithOpCode(opcode OpCode, data []byte) error {
 	return sc.sendStream(pack)
 }
 
+// Recv 读取流上的下一个包，用于上传等客户端持续发送的场景
+// 客户端关闭写方向（半关闭）后返回io.EOF
+func (sc *StreamContext) Recv() (*Pack, error) {
+	data, err := ReadFrame(sc.stream, sc.maxFrameSize)
+	if err != nil {
+		return nil, err
+	}
+	return sc.packCodec.Decode(data)
+}
+
+// Send 向流发送一个包，SQID沿用当前请求，可多次调用实现下载、订阅等持续响应
+func (sc *StreamContext) Send(data []byte) error {
+	return sc.Write(data)
+}
+
+// CloseSend 关闭流的写方向（半关闭），客户端读完已发送的数据后收到io.EOF
+func (sc *StreamContext) CloseSend() error {
+	return sc.stream.Close()
+}
+
+// Cancel 中止流的读写两个方向
+func (sc *StreamContext) Cancel(code quic.StreamErrorCode) {
+	sc.stream.CancelRead(code)
+	sc.stream.CancelWrite(code)
+}
+
 // ServerErr 写入服务错误响应
 func (sc *StreamContext) ServerErr() error {
 	return sc.WriteWithOpCode(OpCodeServerErr, nil)
@@ -184,6 +244,11 @@ func (sc *StreamContext) GetStreamID() quic.StreamID {
 	return sc.stream.StreamID()
 }
 
+// Conn 获取连接句柄，用于向客户端主动发起流，客户端的流处理器中为nil
+func (sc *StreamContext) Conn() *Conn {
+	return sc.handle
+}
+
 // GetConnectionState 获取连接状态
 func (sc *StreamContext) GetConnectionState() quic.ConnectionState {
 	return sc.conn.ConnectionState()
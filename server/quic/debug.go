@@ -0,0 +1,84 @@
+package quic
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ilaziness/gokit/log"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/logging"
+	"github.com/quic-go/quic-go/qlog"
+)
+
+// initDebug 开启qlog和TLS密钥日志，只在Debug模式下生效
+func (s *Server) initDebug() error {
+	if s.config.QlogDir == "" && s.config.KeyLogFile == "" {
+		return nil
+	}
+	if !s.config.Debug {
+		log.Warn(context.Background(), "qlog_dir and key_log_file are ignored when debug is false")
+		return nil
+	}
+
+	if s.config.QlogDir != "" {
+		tracer, err := newQlogTracer(s.config.QlogDir)
+		if err != nil {
+			return err
+		}
+		s.quicConfig.Tracer = tracer
+		log.Warn(context.Background(), "qlog enabled, traces are written to %s", s.config.QlogDir)
+	}
+
+	if s.config.KeyLogFile != "" {
+		f, err := os.OpenFile(s.config.KeyLogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("open key log file error: %w", err)
+		}
+		s.keyLog = f
+		s.tlsConfig.KeyLogWriter = f
+		log.Warn(context.Background(), "TLS key log enabled, session keys are written to %s", s.config.KeyLogFile)
+	}
+	return nil
+}
+
+// closeDebug 关闭TLS密钥日志文件
+func (s *Server) closeDebug() {
+	if s.keyLog != nil {
+		_ = s.keyLog.Close()
+		s.keyLog = nil
+	}
+}
+
+// newQlogTracer 创建qlog追踪器，每个连接写入dir下的<连接ID>_<odcid>_server.sqlog文件，连接ID与Conn.ID相同
+func newQlogTracer(dir string) (func(context.Context, logging.Perspective, quic.ConnectionID) *logging.ConnectionTracer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create qlog dir error: %w", err)
+	}
+	return func(ctx context.Context, p logging.Perspective, odcid quic.ConnectionID) *logging.ConnectionTracer {
+		id, _ := ctx.Value(quic.ConnectionTracingKey).(quic.ConnectionTracingID)
+		path := filepath.Join(dir, fmt.Sprintf("%d_%s_server.sqlog", id, odcid))
+		f, err := os.Create(path)
+		if err != nil {
+			log.Warn(ctx, "create qlog file error: %s", err)
+			return nil
+		}
+		return qlog.NewConnectionTracer(&bufferedFile{Writer: bufio.NewWriter(f), f: f}, p, odcid)
+	}, nil
+}
+
+// bufferedFile 带缓冲的文件，关闭时写入缓冲数据
+type bufferedFile struct {
+	*bufio.Writer
+	f *os.File
+}
+
+func (b *bufferedFile) Close() error {
+	if err := b.Writer.Flush(); err != nil {
+		_ = b.f.Close()
+		return err
+	}
+	return b.f.Close()
+}
@@ -0,0 +1,66 @@
+package quic
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilaziness/gokit/config"
+)
+
+func TestServer_DebugTracing(t *testing.T) {
+	tests := []struct {
+		name  string
+		debug bool
+	}{
+		{name: "debug", debug: true},
+		{name: "release", debug: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfg := newTestConfig(t)
+			cfg.Debug = tt.debug
+			cfg.QlogDir = filepath.Join(dir, "qlog")
+			cfg.KeyLogFile = filepath.Join(dir, "keys.log")
+
+			server := NewQUIC(cfg)
+			server.AddHandler(1000, func(ctx *Context) {
+				_ = ctx.Write(nil)
+			})
+			addr := startTestServer(t, server)
+			t.Cleanup(server.closeDebug)
+
+			client, err := NewClient(&config.QUICClient{InsecureSkipVerify: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = client.Request(context.Background(), addr, 1000, nil); err != nil {
+				t.Fatalf("Request() error: %v", err)
+			}
+			_ = client.Close()
+
+			keys, _ := os.ReadFile(cfg.KeyLogFile)
+			if got := strings.Contains(string(keys), "CLIENT_TRAFFIC_SECRET_0"); got != tt.debug {
+				t.Errorf("key log written = %v, want %v", got, tt.debug)
+			}
+
+			// qlog在连接关闭时写入
+			var traces []string
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				traces, _ = filepath.Glob(filepath.Join(cfg.QlogDir, "*_server.sqlog"))
+				if len(traces) > 0 || !tt.debug {
+					break
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+			if got := len(traces) > 0; got != tt.debug {
+				t.Errorf("qlog written = %v, want %v", got, tt.debug)
+			}
+		})
+	}
+}
@@ -2,14 +2,23 @@ package quic
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/ilaziness/gokit/config"
+	"github.com/ilaziness/gokit/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
 )
 
 // EnhancedServer 增强版QUIC服务器，更符合主流架构
@@ -17,14 +26,18 @@ type EnhancedServer struct {
 	*Server // 嵌入基础服务器
 
 	// 服务发现
-	registry ServiceRegistry
+	registry       ServiceRegistry
+	service        *ServiceInfo // 注册的服务实例，启动时补全地址和ID
+	stopHealth     context.CancelFunc
+	serviceMu      sync.Mutex
+	serviceHealthy bool // 健康检查失败时注销，恢复后重新注册
 
 	// 可观测性
 	tracer  trace.Tracer
 	metrics *ServerMetrics
 
 	// 协议支持
-	codecs map[string]EnhancedCodec
+	codecs *MultiCodec
 
 	// 中间件增强
 	middlewareChain MiddlewareChain
@@ -81,6 +94,13 @@ type EnhancedMiddleware interface {
 	Handle(ctx *EnhancedContext) error
 }
 
+// 元数据键
+const (
+	MetadataContentType   = "content-type"
+	MetadataAuthorization = "authorization"
+	MetadataRequestID     = "request-id"
+)
+
 // EnhancedContext 增强上下文
 type EnhancedContext struct {
 	*Context
@@ -98,8 +118,42 @@ type EnhancedContext struct {
 	// 元数据
 	Metadata map[string]string
 
+	// 认证用户，由AuthMiddleware设置
+	User *User
+
 	// 指标
 	startTime time.Time
+
+	// 请求结束时执行的函数，参数为中间件返回的错误
+	finishers []func(err error)
+}
+
+type enhancedContextKey struct{}
+
+// GetEnhancedContext 从请求上下文中获取增强上下文，未经过EnhancedServer处理时返回nil
+func GetEnhancedContext(ctx context.Context) *EnhancedContext {
+	ectx, _ := ctx.Value(enhancedContextKey{}).(*EnhancedContext)
+	return ectx
+}
+
+// OnFinish 注册请求结束时执行的函数，按注册的相反顺序执行
+func (ctx *EnhancedContext) OnFinish(fn func(err error)) {
+	ctx.finishers = append(ctx.finishers, fn)
+}
+
+// Error 请求错误，中间件返回该错误时使用Code作为响应操作码，Message作为响应数据
+type Error struct {
+	Code    OpCode
+	Message string
+}
+
+// NewError 创建请求错误
+func NewError(code OpCode, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
 }
 
 // EnhancedCodec 增强编解码器接口
@@ -109,10 +163,11 @@ type EnhancedCodec interface {
 	ContentType() string
 }
 
-// MultiCodec 多协议编解码器
+// MultiCodec 多协议编解码器，按内容类型选择编解码器
 type MultiCodec struct {
-	codecs map[string]EnhancedCodec
-	mu     sync.RWMutex
+	codecs      map[string]EnhancedCodec
+	defaultType string
+	mu          sync.RWMutex
 }
 
 // JSONCodec JSON编解码器
@@ -127,23 +182,33 @@ func (c *JSONCodec) Decode(data []byte, v interface{}) error {
 }
 
 func (c *JSONCodec) ContentType() string {
-	return "application/json"
+	return ContentTypeJSON
 }
 
-// ProtobufCodec Protobuf编解码器（示例）
+// ErrNotProtoMessage 值未实现proto.Message
+var ErrNotProtoMessage = errors.New("value does not implement proto.Message")
+
+// ProtobufCodec Protobuf编解码器，值必须实现proto.Message
 type ProtobufCodec struct{}
 
 func (c *ProtobufCodec) Encode(v interface{}) ([]byte, error) {
-	// 实际实现需要protobuf支持
-	return nil, fmt.Errorf("protobuf codec not implemented")
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+	return proto.Marshal(m)
 }
 
 func (c *ProtobufCodec) Decode(data []byte, v interface{}) error {
-	return fmt.Errorf("protobuf codec not implemented")
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotProtoMessage, v)
+	}
+	return proto.Unmarshal(data, m)
 }
 
 func (c *ProtobufCodec) ContentType() string {
-	return "application/protobuf"
+	return ContentTypeProtobuf
 }
 
 // NewEnhancedServer 创建增强版服务器
@@ -152,27 +217,160 @@ func NewEnhancedServer(config *config.QUICServer) *EnhancedServer {
 
 	enhanced := &EnhancedServer{
 		Server: baseServer,
-		codecs: make(map[string]EnhancedCodec),
+		codecs: NewMultiCodec(ContentTypeJSON),
 		middlewareChain: MiddlewareChain{
 			middlewares: make([]EnhancedMiddleware, 0),
 		},
 	}
 
 	// 注册默认编解码器
-	enhanced.RegisterCodec("application/json", &JSONCodec{})
-	enhanced.RegisterCodec("application/protobuf", &ProtobufCodec{})
+	enhanced.RegisterCodec(ContentTypeJSON, &JSONCodec{})
+	enhanced.RegisterCodec(ContentTypeProtobuf, &ProtobufCodec{})
+	enhanced.RegisterCodec("application/x-protobuf", &ProtobufCodec{})
+	enhanced.RegisterCodec(ContentTypeMsgpack, &MsgpackCodec{})
+	enhanced.RegisterCodec("application/x-msgpack", &MsgpackCodec{})
+	enhanced.RegisterCodec(ContentTypeCBOR, &CBORCodec{})
+
+	// 所有数据报和流请求先经过增强中间件链
+	baseServer.enhancer = enhanced.handleEnhanced
+	baseServer.onStart = enhanced.registerService
+	baseServer.onStop = enhanced.deregisterService
 
 	return enhanced
 }
 
+// handleEnhanced 执行增强中间件链，出错时返回错误响应并中断处理，否则继续执行后续中间件和处理器
+func (s *EnhancedServer) handleEnhanced(ctx *Context) {
+	ectx, err := s.newEnhancedContext(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		ctx.Abort()
+		return
+	}
+	ctx.Context = context.WithValue(ctx.Context, enhancedContextKey{}, ectx)
+
+	defer func() {
+		for i := len(ectx.finishers) - 1; i >= 0; i-- {
+			ectx.finishers[i](err)
+		}
+	}()
+
+	if err = s.middlewareChain.Execute(ectx); err != nil {
+		log.Warn(ctx, "quic request %s rejected: %s", ectx.RequestID, err)
+		writeError(ctx, err)
+		ctx.Abort()
+		return
+	}
+	ctx.Next()
+}
+
+// newEnhancedContext 根据请求包的元数据创建增强上下文，内容类型不支持时返回错误
+func (s *EnhancedServer) newEnhancedContext(ctx *Context) (*EnhancedContext, error) {
+	metadata := make(map[string]string, len(ctx.Pack.Metadata)+1)
+	for k, v := range ctx.Pack.Metadata {
+		metadata[k] = v
+	}
+
+	requestID := metadata[MetadataRequestID]
+	if requestID == "" {
+		requestID = newRequestID()
+		metadata[MetadataRequestID] = requestID
+	}
+
+	codec, err := s.codecs.Negotiate(metadata[MetadataContentType])
+	if err != nil {
+		return nil, NewError(OpCodeUnsupportedMediaType, err.Error())
+	}
+
+	protocol := "quic-datagram"
+	if ctx.IsStream() {
+		protocol = "quic-stream"
+	}
+
+	return &EnhancedContext{
+		Context:       ctx,
+		RequestID:     requestID,
+		Protocol:      protocol,
+		EnhancedCodec: codec,
+		Metadata:      metadata,
+		startTime:     time.Now(),
+	}, nil
+}
+
+// Bind 使用协商的编解码器解码请求数据
+func (ctx *EnhancedContext) Bind(v any) error {
+	if err := ctx.EnhancedCodec.Decode(ctx.Payload, v); err != nil {
+		return NewError(OpCodeBadRequest, err.Error())
+	}
+	return nil
+}
+
+// Reply 使用协商的编解码器编码响应数据并写入，响应元数据携带content-type和request-id
+func (ctx *EnhancedContext) Reply(v any) error {
+	return ctx.ReplyWithOpCode(OpCodeResOK, v)
+}
+
+// ReplyWithOpCode 使用协商的编解码器编码响应数据，以指定操作码写入
+func (ctx *EnhancedContext) ReplyWithOpCode(opcode OpCode, v any) error {
+	data, err := ctx.EnhancedCodec.Encode(v)
+	if err != nil {
+		return err
+	}
+	return ctx.Context.send(&Pack{
+		Head: PackHead{
+			OpCode:  uint16(opcode),
+			SQID:    ctx.SQID,
+			Version: ctx.Pack.Head.Version,
+		},
+		Payload: data,
+		Metadata: map[string]string{
+			MetadataContentType: ctx.EnhancedCodec.ContentType(),
+			MetadataRequestID:   ctx.RequestID,
+		},
+	})
+}
+
+// ReplyError 写入错误响应，*Error使用其Code作为操作码，其他错误返回服务器错误
+func (ctx *EnhancedContext) ReplyError(err error) {
+	writeError(ctx.Context, err)
+}
+
+// writeError 将中间件错误转换为错误响应
+func writeError(ctx *Context, err error) {
+	var e *Error
+	if errors.As(err, &e) {
+		_ = ctx.WriteWithOpCode(e.Code, []byte(e.Message))
+		return
+	}
+	_ = ctx.ServerErr()
+}
+
+// newRequestID 生成请求ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 // RegisterCodec 注册编解码器
 func (s *EnhancedServer) RegisterCodec(contentType string, codec EnhancedCodec) {
-	s.codecs[contentType] = codec
+	s.codecs.Register(contentType, codec)
 }
 
-// WithServiceRegistry 设置服务注册中心
-func (s *EnhancedServer) WithServiceRegistry(registry ServiceRegistry) *EnhancedServer {
+// SetDefaultContentType 设置请求未指定content-type时使用的内容类型，默认为JSON
+func (s *EnhancedServer) SetDefaultContentType(contentType string) {
+	s.codecs.SetDefault(contentType)
+}
+
+// WithServiceRegistry 设置服务注册中心和注册的服务实例，启动时注册，退出时注销
+//
+// service的Address、Port为空时使用监听地址，ID为空时使用 名称-地址:端口
+func (s *EnhancedServer) WithServiceRegistry(registry ServiceRegistry, service *ServiceInfo) *EnhancedServer {
+	if service == nil {
+		service = &ServiceInfo{}
+	}
 	s.registry = registry
+	s.service = copyServiceInfo(service)
 	return s
 }
 
@@ -248,14 +446,26 @@ func (m *TracingMiddleware) Priority() int {
 
 func (m *TracingMiddleware) Handle(ctx *EnhancedContext) error {
 	if m.tracer != nil {
-		_, span := m.tracer.Start(ctx.Context, "quic.request")
+		// 从元数据中提取上游的链路信息
+		parent := otel.GetTextMapPropagator().Extract(ctx.Context.Context, propagation.MapCarrier(ctx.Metadata))
+		spanCtx, span := m.tracer.Start(parent, "quic.request", trace.WithSpanKind(trace.SpanKindServer))
 		ctx.Span = span
+		ctx.Context.Context = spanCtx
 
 		// 设置span属性
-		// span.SetAttributes(
-		// 	attribute.String("quic.opcode", fmt.Sprintf("%d", ctx.OpCode)),
-		// 	attribute.String("quic.remote_addr", ctx.GetRemoteAddr()),
-		// )
+		span.SetAttributes(
+			attribute.Int("quic.opcode", int(ctx.OpCode)),
+			attribute.String("quic.remote_addr", ctx.GetRemoteAddr()),
+			attribute.String("quic.protocol", ctx.Protocol),
+			attribute.String("quic.request_id", ctx.RequestID),
+		)
+		ctx.OnFinish(func(err error) {
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		})
 	}
 	return nil
 }
@@ -286,6 +496,17 @@ func (m *MetricsMiddleware) Handle(ctx *EnhancedContext) error {
 
 		// 记录字节数
 		m.metrics.BytesReceived.Add(ctx.Context, int64(len(ctx.Payload)))
+
+		attrs := metric.WithAttributes(
+			attribute.Int("quic.opcode", int(ctx.OpCode)),
+			attribute.String("quic.protocol", ctx.Protocol),
+		)
+		ctx.OnFinish(func(err error) {
+			m.metrics.RequestDuration.Record(ctx.Context, time.Since(ctx.startTime).Seconds(), attrs)
+			if err != nil {
+				m.metrics.ErrorCount.Add(ctx.Context, 1, attrs)
+			}
+		})
 	}
 	return nil
 }
@@ -293,6 +514,7 @@ func (m *MetricsMiddleware) Handle(ctx *EnhancedContext) error {
 // RateLimitMiddleware 限流中间件
 type RateLimitMiddleware struct {
 	limiter RateLimiterInterface
+	keyFunc RateLimitKeyFunc
 }
 
 // RateLimiterInterface 限流器接口
@@ -301,8 +523,15 @@ type RateLimiterInterface interface {
 	Reset(key string)
 }
 
+// NewRateLimitMiddleware 创建限流中间件，默认以客户端IP作为限流键
 func NewRateLimitMiddleware(limiter RateLimiterInterface) *RateLimitMiddleware {
-	return &RateLimitMiddleware{limiter: limiter}
+	return &RateLimitMiddleware{limiter: limiter, keyFunc: ClientIPKey}
+}
+
+// WithKeyFunc 设置限流键函数，如按认证用户限流
+func (m *RateLimitMiddleware) WithKeyFunc(keyFunc RateLimitKeyFunc) *RateLimitMiddleware {
+	m.keyFunc = keyFunc
+	return m
 }
 
 func (m *RateLimitMiddleware) Name() string {
@@ -315,9 +544,9 @@ func (m *RateLimitMiddleware) Priority() int {
 
 func (m *RateLimitMiddleware) Handle(ctx *EnhancedContext) error {
 	if m.limiter != nil {
-		clientAddr := ctx.GetRemoteAddr()
-		if !m.limiter.Allow(clientAddr) {
-			return fmt.Errorf("rate limit exceeded for %s", clientAddr)
+		key := m.keyFunc(ctx.Context)
+		if key != "" && !m.limiter.Allow(key) {
+			return NewError(OpCodeTooManyRequests, fmt.Sprintf("rate limit exceeded for %s", key))
 		}
 	}
 	return nil
@@ -328,10 +557,16 @@ type AuthMiddleware struct {
 	authenticator Authenticator
 }
 
+// Authenticator 令牌认证器，令牌取自请求元数据authorization
 type Authenticator interface {
 	Authenticate(token string) (*User, error)
 }
 
+// ContextAuthenticator 使用请求上下文认证，如TLS客户端证书，AuthMiddleware优先使用该接口
+type ContextAuthenticator interface {
+	AuthenticateContext(ctx *EnhancedContext) (*User, error)
+}
+
 type User struct {
 	ID       string
 	Username string
@@ -351,21 +586,32 @@ func (m *AuthMiddleware) Priority() int {
 }
 
 func (m *AuthMiddleware) Handle(ctx *EnhancedContext) error {
-	if m.authenticator != nil {
-		token := ctx.Metadata["authorization"]
-		if token == "" {
-			return fmt.Errorf("missing authorization token")
-		}
+	if m.authenticator == nil {
+		return nil
+	}
 
-		user, err := m.authenticator.Authenticate(token)
-		if err != nil {
-			return fmt.Errorf("authentication failed: %w", err)
+	var (
+		user *User
+		err  error
+	)
+	if ca, ok := m.authenticator.(ContextAuthenticator); ok {
+		user, err = ca.AuthenticateContext(ctx)
+	} else {
+		token := ctx.Metadata[MetadataAuthorization]
+		if token == "" {
+			return NewError(OpCodeUnauthorized, "missing authorization token")
 		}
-
-		// 将用户信息存储到上下文
-		ctx.Metadata["user_id"] = user.ID
-		ctx.Metadata["username"] = user.Username
+		user, err = m.authenticator.Authenticate(token)
 	}
+	if err != nil {
+		log.Debug(ctx, "authentication failed: %s", err)
+		return NewError(OpCodeUnauthorized, "authentication failed")
+	}
+
+	// 将用户信息存储到上下文
+	ctx.User = user
+	ctx.Metadata["user_id"] = user.ID
+	ctx.Metadata["username"] = user.Username
 	return nil
 }
 
@@ -386,7 +632,7 @@ func ExampleEnhancedServer() {
 	// server.AddEnhancedMiddleware(NewAuthMiddleware(authenticator))
 
 	// 设置服务注册
-	// server.WithServiceRegistry(consulRegistry)
+	// server.WithServiceRegistry(NewMemoryRegistry(), &ServiceInfo{Name: "demo"})
 
 	// 添加处理器
 	server.AddHandler(1000, func(ctx *Context) {
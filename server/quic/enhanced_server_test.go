@@ -0,0 +1,230 @@
+package quic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+type testMiddleware struct {
+	name     string
+	priority int
+	err      error
+	calls    *[]string
+}
+
+func (m *testMiddleware) Name() string {
+	return m.name
+}
+
+func (m *testMiddleware) Priority() int {
+	return m.priority
+}
+
+func (m *testMiddleware) Handle(ctx *EnhancedContext) error {
+	*m.calls = append(*m.calls, m.name)
+	return m.err
+}
+
+func TestMiddlewareChain_Priority(t *testing.T) {
+	var calls []string
+	chain := MiddlewareChain{}
+	chain.Add(&testMiddleware{name: "low", priority: 1, calls: &calls})
+	chain.Add(&testMiddleware{name: "high", priority: 100, calls: &calls})
+	chain.Add(&testMiddleware{name: "mid", priority: 50, calls: &calls})
+
+	if err := chain.Execute(&EnhancedContext{}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	want := []string{"high", "mid", "low"}
+	for i, name := range want {
+		if calls[i] != name {
+			t.Errorf("calls = %v, want %v", calls, want)
+			break
+		}
+	}
+}
+
+type testAuthenticator struct{}
+
+func (a *testAuthenticator) Authenticate(token string) (*User, error) {
+	if token == "valid" {
+		return &User{ID: "1", Username: "test"}, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+func TestEnhancedServer_RequestPath(t *testing.T) {
+	server := NewEnhancedServer(newTestConfig(t))
+	server.AddEnhancedMiddleware(NewAuthMiddleware(&testAuthenticator{}))
+
+	handled := make(chan *EnhancedContext, 2)
+	server.AddHandler(1000, func(ctx *Context) {
+		ectx := GetEnhancedContext(ctx)
+		handled <- ectx
+		_ = ctx.Write([]byte(ectx.Metadata["username"]))
+	})
+	server.AddStreamHandler(2000, func(ctx *StreamContext) {
+		ectx := GetEnhancedContext(ctx)
+		handled <- ectx
+		_ = ctx.Write([]byte(ectx.Metadata["username"]))
+	})
+
+	conn := dialTestServer(t, startTestServer(t, server.Server))
+	codec := NewPackCodec()
+
+	sendDatagram := func(t *testing.T, metadata map[string]string) *Pack {
+		data, err := codec.Encode(&Pack{Head: PackHead{SQID: 1, OpCode: 1000, Version: Version1}, Metadata: metadata})
+		if err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+		if err = conn.SendDatagram(data); err != nil {
+			t.Fatalf("send datagram error: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		resp, err := conn.ReceiveDatagram(ctx)
+		if err != nil {
+			t.Fatalf("receive datagram error: %v", err)
+		}
+		pack, err := codec.Decode(resp)
+		if err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		return pack
+	}
+
+	t.Run("DatagramRejected", func(t *testing.T) {
+		pack := sendDatagram(t, nil)
+		if OpCode(pack.Head.OpCode) != OpCodeUnauthorized {
+			t.Errorf("OpCode = %d, want %d", pack.Head.OpCode, OpCodeUnauthorized)
+		}
+		select {
+		case <-handled:
+			t.Error("handler should not be called for rejected request")
+		default:
+		}
+	})
+
+	t.Run("DatagramAccepted", func(t *testing.T) {
+		pack := sendDatagram(t, map[string]string{
+			MetadataAuthorization: "valid",
+			MetadataRequestID:     "req-1",
+		})
+		if OpCode(pack.Head.OpCode) != OpCodeResOK || string(pack.Payload) != "test" {
+			t.Errorf("response = %d %s, want %d test", pack.Head.OpCode, pack.Payload, OpCodeResOK)
+		}
+		ectx := <-handled
+		if ectx.RequestID != "req-1" {
+			t.Errorf("RequestID = %s, want req-1", ectx.RequestID)
+		}
+		if ectx.EnhancedCodec == nil || ectx.EnhancedCodec.ContentType() != "application/json" {
+			t.Error("expected default JSON codec")
+		}
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		stream, err := conn.OpenStreamSync(context.Background())
+		if err != nil {
+			t.Fatalf("open stream error: %v", err)
+		}
+		defer stream.Close()
+
+		request := func(metadata map[string]string) *Pack {
+			data, err := codec.Encode(&Pack{Head: PackHead{SQID: 2, OpCode: 2000, Version: Version1}, Metadata: metadata})
+			if err != nil {
+				t.Fatalf("encode error: %v", err)
+			}
+			if _, err = stream.Write(data); err != nil {
+				t.Fatalf("write stream error: %v", err)
+			}
+			return readTestFrame(t, stream)
+		}
+
+		if pack := request(nil); OpCode(pack.Head.OpCode) != OpCodeUnauthorized {
+			t.Errorf("OpCode = %d, want %d", pack.Head.OpCode, OpCodeUnauthorized)
+		}
+		pack := request(map[string]string{MetadataAuthorization: "valid"})
+		if string(pack.Payload) != "test" {
+			t.Errorf("Payload = %s, want test", pack.Payload)
+		}
+		if ectx := <-handled; ectx.Protocol != "quic-stream" || ectx.RequestID == "" {
+			t.Errorf("unexpected enhanced context: %s %s", ectx.Protocol, ectx.RequestID)
+		}
+	})
+}
+
+// readTestFrame 从流中读取并解码一个包
+func readTestFrame(t *testing.T, stream quic.Stream) *Pack {
+	t.Helper()
+	data, err := ReadFrame(stream, DefaultMaxFrameSize)
+	if err != nil {
+		t.Fatalf("read frame error: %v", err)
+	}
+	pack, err := NewPackCodec().Decode(data)
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	return pack
+}
+
+func TestEnhancedServer_ContentNegotiation(t *testing.T) {
+	server := NewEnhancedServer(newTestConfig(t))
+	server.AddStreamHandler(2000, func(ctx *StreamContext) {
+		ectx := GetEnhancedContext(ctx)
+		var req codecTestMessage
+		if err := ectx.Bind(&req); err != nil {
+			ectx.ReplyError(err)
+			return
+		}
+		req.Count++
+		_ = ectx.Reply(req)
+	})
+
+	conn := dialTestServer(t, startTestServer(t, server.Server))
+	stream, err := conn.OpenStreamSync(context.Background())
+	if err != nil {
+		t.Fatalf("open stream error: %v", err)
+	}
+	defer stream.Close()
+
+	request := func(contentType string, payload []byte) *Pack {
+		data, err := NewPackCodec().Encode(&Pack{
+			Head:     PackHead{SQID: 1, OpCode: 2000, Version: Version2},
+			Payload:  payload,
+			Metadata: map[string]string{MetadataContentType: contentType},
+		})
+		if err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+		if _, err = stream.Write(data); err != nil {
+			t.Fatalf("write stream error: %v", err)
+		}
+		return readTestFrame(t, stream)
+	}
+
+	for _, c := range []EnhancedCodec{&MsgpackCodec{}, &CBORCodec{}, &JSONCodec{}} {
+		payload, err := c.Encode(codecTestMessage{Name: "n", Count: 1})
+		if err != nil {
+			t.Fatalf("encode payload error: %v", err)
+		}
+		pack := request(c.ContentType(), payload)
+		if pack.Metadata[MetadataContentType] != c.ContentType() {
+			t.Errorf("response content-type = %s, want %s", pack.Metadata[MetadataContentType], c.ContentType())
+		}
+		var resp codecTestMessage
+		if err = c.Decode(pack.Payload, &resp); err != nil || resp.Count != 2 {
+			t.Errorf("response = %+v, err = %v", resp, err)
+		}
+	}
+
+	if pack := request("text/xml", []byte("<a/>")); OpCode(pack.Head.OpCode) != OpCodeUnsupportedMediaType {
+		t.Errorf("OpCode = %d, want %d", pack.Head.OpCode, OpCodeUnsupportedMediaType)
+	}
+	if pack := request(ContentTypeJSON, []byte("{bad")); OpCode(pack.Head.OpCode) != OpCodeBadRequest {
+		t.Errorf("OpCode = %d, want %d", pack.Head.OpCode, OpCodeBadRequest)
+	}
+}
@@ -8,18 +8,34 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"net"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/ilaziness/gokit/config"
+	"github.com/ilaziness/gokit/log"
 	"github.com/quic-go/quic-go"
 )
 
+// TestMain 日志写入临时目录，不在包目录下生成日志文件
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gokit-log")
+	if err != nil {
+		panic(err)
+	}
+	log.SetFile(filepath.Join(dir, "app.log"))
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 // TestQUICServerIntegration 集成测试
 func TestQUICServerIntegration(t *testing.T) {
 	// 生成测试证书
@@ -369,3 +385,149 @@ func generateTestCerts(t *testing.T) (certFile, keyFile string, cleanup func())
 
 	return certFile, keyFile, cleanup
 }
+
+// startTestServer 使用测试证书在随机端口启动服务器，返回监听地址
+func startTestServer(t *testing.T, server *Server) string {
+	t.Helper()
+
+	if err := server.initConfigs(); err != nil {
+		t.Fatalf("init configs error: %v", err)
+	}
+	listener, err := quic.ListenAddr("localhost:0", server.tlsConfig, server.quicConfig)
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	server.listener = listener
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go server.handleConnections(ctx)
+	t.Cleanup(func() {
+		cancel()
+		_ = listener.Close()
+		server.closeCert()
+	})
+
+	return listener.Addr().String()
+}
+
+// dialTestServer 连接测试服务器
+func dialTestServer(t *testing.T, addr string) quic.Connection {
+	t.Helper()
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"quic-server"},
+	}
+	conn, err := quic.DialAddr(context.Background(), addr, tlsConfig, &quic.Config{EnableDatagrams: true})
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.CloseWithError(0, "test complete")
+	})
+	return conn
+}
+
+// newTestConfig 创建使用测试证书的服务器配置
+func newTestConfig(t *testing.T) *config.QUICServer {
+	certFile, keyFile, cleanup := generateTestCerts(t)
+	t.Cleanup(cleanup)
+	return &config.QUICServer{
+		Address:   "localhost:0",
+		WorkerNum: 100,
+		CertFile:  certFile,
+		KeyFile:   keyFile,
+	}
+}
+
+// TestQUICServerStreaming 测试流上的多请求、大包和双向流式收发
+func TestQUICServerStreaming(t *testing.T) {
+	server := NewQUIC(newTestConfig(t))
+
+	// 普通请求：返回payload长度
+	server.AddStreamHandler(2000, func(ctx *StreamContext) {
+		_ = ctx.Write([]byte(fmt.Sprintf("%d", len(ctx.Payload))))
+	})
+	// 上传：持续接收直到客户端半关闭，返回收到的总字节数
+	server.AddStreamHandler(2001, func(ctx *StreamContext) {
+		total := len(ctx.Payload)
+		for {
+			pack, err := ctx.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				_ = ctx.ServerErr()
+				return
+			}
+			total += len(pack.Payload)
+		}
+		_ = ctx.Send([]byte(fmt.Sprintf("%d", total)))
+		_ = ctx.CloseSend()
+	})
+
+	conn := dialTestServer(t, startTestServer(t, server))
+	codec := NewPackCodec()
+
+	send := func(t *testing.T, stream quic.Stream, sqid uint32, opcode uint16, payload []byte) {
+		data, err := codec.Encode(&Pack{Head: PackHead{SQID: sqid, OpCode: opcode, Version: 1}, Payload: payload})
+		if err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+		if _, err = stream.Write(data); err != nil {
+			t.Fatalf("write stream error: %v", err)
+		}
+	}
+	recv := func(t *testing.T, stream quic.Stream) *Pack {
+		data, err := ReadFrame(stream, DefaultMaxFrameSize)
+		if err != nil {
+			t.Fatalf("read frame error: %v", err)
+		}
+		pack, err := codec.Decode(data)
+		if err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		return pack
+	}
+
+	t.Run("MultipleRequests", func(t *testing.T) {
+		stream, err := conn.OpenStreamSync(context.Background())
+		if err != nil {
+			t.Fatalf("open stream error: %v", err)
+		}
+		defer stream.Close()
+
+		sizes := []int{10, 200 * 1024, 0}
+		for i, size := range sizes {
+			send(t, stream, uint32(i+1), 2000, make([]byte, size))
+			pack := recv(t, stream)
+			if pack.Head.SQID != uint32(i+1) {
+				t.Errorf("SQID = %d, want %d", pack.Head.SQID, i+1)
+			}
+			if string(pack.Payload) != fmt.Sprintf("%d", size) {
+				t.Errorf("response = %s, want %d", pack.Payload, size)
+			}
+		}
+	})
+
+	t.Run("Upload", func(t *testing.T) {
+		stream, err := conn.OpenStreamSync(context.Background())
+		if err != nil {
+			t.Fatalf("open stream error: %v", err)
+		}
+
+		for i := 0; i < 5; i++ {
+			send(t, stream, 10, 2001, make([]byte, 32*1024))
+		}
+		// 半关闭，服务端Recv返回io.EOF
+		_ = stream.Close()
+
+		pack := recv(t, stream)
+		if string(pack.Payload) != fmt.Sprintf("%d", 5*32*1024) {
+			t.Errorf("upload total = %s, want %d", pack.Payload, 5*32*1024)
+		}
+		if _, err = ReadFrame(stream, DefaultMaxFrameSize); !errors.Is(err, io.EOF) {
+			t.Errorf("read after CloseSend error = %v, want %v", err, io.EOF)
+		}
+	})
+}
@@ -1,6 +1,8 @@
 package quic
 
 import (
+	"time"
+
 	"github.com/ilaziness/gokit/log"
 )
 
@@ -15,26 +17,9 @@ func Ping(ctx *Context) {
 	ctx.Abort()
 }
 
-// RateLimiter 速率限制中间件
+// RateLimiter 速率限制中间件，每个客户端IP在windowSize秒内最多maxRequests个请求
 func RateLimiter(maxRequests int, windowSize int64) Handler {
-	// 简单的内存速率限制器实现
-	// 生产环境建议使用更复杂的限流算法，如令牌桶或滑动窗口
-	requestCounts := make(map[string]int)
-
-	return func(ctx *Context) {
-		clientAddr := ctx.GetRemoteAddr()
-
-		// 简单计数，实际应该考虑时间窗口
-		if requestCounts[clientAddr] >= maxRequests {
-			log.Warn(ctx, "rate limit exceeded for client: %s", clientAddr)
-			_ = ctx.ServerErr()
-			ctx.Abort()
-			return
-		}
-
-		requestCounts[clientAddr]++
-		ctx.Next()
-	}
+	return RateLimit(NewSlidingWindowLimiter(maxRequests, time.Duration(windowSize)*time.Second), ClientIPKey)
 }
 
 // Logger 日志中间件
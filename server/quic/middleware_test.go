@@ -0,0 +1,93 @@
+package quic
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilaziness/gokit/config"
+)
+
+func TestMiddleware_Stream(t *testing.T) {
+	var datagrams, streams atomic.Int32
+	server := NewDefaultQUIC(newTestConfig(t))
+	server.AddMiddleware(func(ctx *Context) {
+		if ctx.IsStream() {
+			streams.Add(1)
+		} else {
+			datagrams.Add(1)
+		}
+		ctx.Next()
+	})
+	server.AddMiddleware(RateLimit(NewSlidingWindowLimiter(100, time.Minute), func(ctx *Context) string {
+		if ctx.OpCode == 2001 {
+			return "limited"
+		}
+		return ""
+	}))
+	server.AddHandler(1000, func(ctx *Context) {
+		_ = ctx.Write(ctx.Payload)
+	})
+	server.AddStreamHandler(2000, func(ctx *StreamContext) {
+		_ = ctx.Write(ctx.Payload)
+	})
+	server.AddStreamHandler(2001, func(ctx *StreamContext) {
+		_ = ctx.Write(ctx.Payload)
+	})
+	server.AddStreamHandler(2002, func(ctx *StreamContext) {
+		panic("stream handler panic")
+	})
+	addr := startTestServer(t, server)
+	client := newTestClient(t, &config.QUICClient{})
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		opcode OpCode
+		want   OpCode
+	}{
+		{name: "ping", opcode: OpCodePing, want: OpCodePong},
+		{name: "stream handler", opcode: 2000, want: OpCodeResOK},
+		{name: "datagram handler", opcode: 1000, want: OpCodeResOK},
+		{name: "not found", opcode: 3000, want: OpCodeNotFound},
+		{name: "panic", opcode: 2002, want: OpCodeServerErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.DoStream(ctx, addr, &Pack{Head: PackHead{OpCode: uint16(tt.opcode)}, Payload: []byte("hi")})
+			if err != nil {
+				t.Fatalf("DoStream() error: %v", err)
+			}
+			if OpCode(resp.Head.OpCode) != tt.want {
+				t.Errorf("OpCode = %d, want %d", resp.Head.OpCode, tt.want)
+			}
+		})
+	}
+	if n := streams.Load(); n != 4 {
+		t.Errorf("middleware ran for %d stream requests, want 4", n)
+	}
+
+	// 流请求同样受限流中间件限制
+	limited := 0
+	for range 110 {
+		resp, err := client.DoStream(ctx, addr, &Pack{Head: PackHead{OpCode: 2001}})
+		if err != nil {
+			t.Fatalf("DoStream() error: %v", err)
+		}
+		if OpCode(resp.Head.OpCode) == OpCodeTooManyRequests {
+			limited++
+		}
+	}
+	if limited != 10 {
+		t.Errorf("limited = %d, want 10", limited)
+	}
+
+	// 服务在流处理器panic后仍可用
+	if _, err := client.Request(ctx, addr, 1000, []byte("hi")); err != nil {
+		t.Errorf("Request() after panic error: %v", err)
+	}
+	if datagrams.Load() == 0 {
+		t.Error("middleware did not run for datagram requests")
+	}
+}
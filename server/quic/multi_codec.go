@@ -0,0 +1,136 @@
+package quic
+
+import (
+	"fmt"
+	"mime"
+	"reflect"
+	"sort"
+	"strings"
+
+	ugcodec "github.com/ugorji/go/codec"
+)
+
+// 内容类型
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/protobuf"
+	ContentTypeMsgpack  = "application/msgpack"
+	ContentTypeCBOR     = "application/cbor"
+)
+
+// NewMultiCodec 创建多协议编解码器，defaultContentType为请求未指定内容类型时使用的编解码器
+func NewMultiCodec(defaultContentType string) *MultiCodec {
+	return &MultiCodec{
+		codecs:      make(map[string]EnhancedCodec),
+		defaultType: defaultContentType,
+	}
+}
+
+// Register 注册内容类型对应的编解码器，同一编解码器可以注册多个别名
+func (m *MultiCodec) Register(contentType string, codec EnhancedCodec) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.codecs[normalizeContentType(contentType)] = codec
+}
+
+// SetDefault 设置默认内容类型
+func (m *MultiCodec) SetDefault(contentType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.defaultType = normalizeContentType(contentType)
+}
+
+// Get 获取内容类型对应的编解码器，忽略参数和大小写，如 application/json; charset=utf-8
+func (m *MultiCodec) Get(contentType string) (EnhancedCodec, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	codec, ok := m.codecs[normalizeContentType(contentType)]
+	return codec, ok
+}
+
+// Negotiate 根据请求的内容类型选择编解码器，未指定时使用默认编解码器
+func (m *MultiCodec) Negotiate(contentType string) (EnhancedCodec, error) {
+	if contentType == "" {
+		m.mu.RLock()
+		contentType = m.defaultType
+		m.mu.RUnlock()
+	}
+	codec, ok := m.Get(contentType)
+	if !ok {
+		return nil, fmt.Errorf("unsupported content type %q, supported: %s",
+			contentType, strings.Join(m.ContentTypes(), ", "))
+	}
+	return codec, nil
+}
+
+// ContentTypes 已注册的内容类型
+func (m *MultiCodec) ContentTypes() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	types := make([]string, 0, len(m.codecs))
+	for ct := range m.codecs {
+		types = append(types, ct)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func normalizeContentType(contentType string) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+var (
+	msgpackHandle = newMsgpackHandle()
+	cborHandle    = newCBORHandle()
+)
+
+func newMsgpackHandle() *ugcodec.MsgpackHandle {
+	h := &ugcodec.MsgpackHandle{}
+	h.WriteExt = true
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]any(nil))
+	return h
+}
+
+func newCBORHandle() *ugcodec.CborHandle {
+	h := &ugcodec.CborHandle{}
+	h.MapType = reflect.TypeOf(map[string]any(nil))
+	return h
+}
+
+// MsgpackCodec MessagePack编解码器，结构体字段使用codec或json标签
+type MsgpackCodec struct{}
+
+func (c *MsgpackCodec) Encode(v interface{}) ([]byte, error) {
+	var data []byte
+	err := ugcodec.NewEncoderBytes(&data, msgpackHandle).Encode(v)
+	return data, err
+}
+
+func (c *MsgpackCodec) Decode(data []byte, v interface{}) error {
+	return ugcodec.NewDecoderBytes(data, msgpackHandle).Decode(v)
+}
+
+func (c *MsgpackCodec) ContentType() string {
+	return ContentTypeMsgpack
+}
+
+// CBORCodec CBOR编解码器，结构体字段使用codec或json标签
+type CBORCodec struct{}
+
+func (c *CBORCodec) Encode(v interface{}) ([]byte, error) {
+	var data []byte
+	err := ugcodec.NewEncoderBytes(&data, cborHandle).Encode(v)
+	return data, err
+}
+
+func (c *CBORCodec) Decode(data []byte, v interface{}) error {
+	return ugcodec.NewDecoderBytes(data, cborHandle).Decode(v)
+}
+
+func (c *CBORCodec) ContentType() string {
+	return ContentTypeCBOR
+}
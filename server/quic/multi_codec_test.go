@@ -0,0 +1,102 @@
+package quic
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type codecTestMessage struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Tags  []string `json:"tags"`
+}
+
+func TestEnhancedCodecs_RoundTrip(t *testing.T) {
+	codecs := []EnhancedCodec{&JSONCodec{}, &MsgpackCodec{}, &CBORCodec{}}
+	original := codecTestMessage{Name: "test", Count: 3, Tags: []string{"a", "b"}}
+
+	for _, c := range codecs {
+		t.Run(c.ContentType(), func(t *testing.T) {
+			data, err := c.Encode(original)
+			if err != nil {
+				t.Fatalf("encode failed: %v", err)
+			}
+			var decoded codecTestMessage
+			if err = c.Decode(data, &decoded); err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if decoded.Name != original.Name || decoded.Count != original.Count || len(decoded.Tags) != 2 {
+				t.Errorf("decoded = %+v, want %+v", decoded, original)
+			}
+
+			// 解码到map时键为字符串
+			var m map[string]any
+			if err = c.Decode(data, &m); err != nil {
+				t.Fatalf("decode to map failed: %v", err)
+			}
+			if m["name"] != "test" {
+				t.Errorf("map name = %v, want test", m["name"])
+			}
+		})
+	}
+}
+
+func TestProtobufCodec(t *testing.T) {
+	c := &ProtobufCodec{}
+
+	data, err := c.Encode(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	var decoded wrapperspb.StringValue
+	if err = c.Decode(data, &decoded); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if decoded.GetValue() != "hello" {
+		t.Errorf("decoded = %s, want hello", decoded.GetValue())
+	}
+
+	if _, err = c.Encode(codecTestMessage{}); !errors.Is(err, ErrNotProtoMessage) {
+		t.Errorf("encode non-proto error = %v, want %v", err, ErrNotProtoMessage)
+	}
+	if err = c.Decode(data, &codecTestMessage{}); !errors.Is(err, ErrNotProtoMessage) {
+		t.Errorf("decode non-proto error = %v, want %v", err, ErrNotProtoMessage)
+	}
+}
+
+func TestMultiCodec_Negotiate(t *testing.T) {
+	m := NewMultiCodec(ContentTypeJSON)
+	m.Register(ContentTypeJSON, &JSONCodec{})
+	m.Register(ContentTypeMsgpack, &MsgpackCodec{})
+
+	tests := []struct {
+		name        string
+		contentType string
+		want        string
+		wantErr     bool
+	}{
+		{name: "default", contentType: "", want: ContentTypeJSON},
+		{name: "exact", contentType: ContentTypeMsgpack, want: ContentTypeMsgpack},
+		{name: "with params", contentType: "Application/JSON; charset=utf-8", want: ContentTypeJSON},
+		{name: "unsupported", contentType: "text/xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := m.Negotiate(tt.contentType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Negotiate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && c.ContentType() != tt.want {
+				t.Errorf("Negotiate() = %s, want %s", c.ContentType(), tt.want)
+			}
+		})
+	}
+
+	m.SetDefault(ContentTypeMsgpack)
+	if c, _ := m.Negotiate(""); c.ContentType() != ContentTypeMsgpack {
+		t.Errorf("default after SetDefault = %s, want %s", c.ContentType(), ContentTypeMsgpack)
+	}
+}
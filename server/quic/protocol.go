@@ -2,8 +2,10 @@ package quic
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 )
 
 // Codec 编解码器接口
@@ -15,23 +17,36 @@ type Codec interface {
 var (
 	ErrPayloadLenErr  = fmt.Errorf("payload length error")
 	ErrPacketTooSmall = fmt.Errorf("packet too small")
+	ErrFrameTooLarge  = fmt.Errorf("frame too large")
+	ErrMetadataErr    = fmt.Errorf("metadata format error")
 )
 
 const (
-	packHeadLen        = 12 // 包头长度
-	Version1    uint16 = 1  // 协议版本v1
+	packHeadLen                = 12               // 包头长度
+	Version1            uint16 = 1                // 协议版本v1
+	Version2            uint16 = 2                // 协议版本v2，包头后携带元数据
+	DefaultMaxFrameSize        = 16 * 1024 * 1024 // 流上单个包的默认最大长度
 
 	OpCodeResOK     OpCode = 0 // 请求成功
 	OpCodeServerErr OpCode = 1 // 服务端错误
 	OpCodePing      OpCode = 2 // ping
 	OpCodePong      OpCode = 3 // pong
 	OpCodeNotFound  OpCode = 4 // 请求handler未找到
+
+	OpCodeBadRequest      OpCode = 5 // 请求错误
+	OpCodeUnauthorized    OpCode = 6 // 未认证
+	OpCodeForbidden       OpCode = 7 // 无权限
+	OpCodeTooManyRequests OpCode = 8 // 请求过多
+
+	OpCodeUnsupportedMediaType OpCode = 9 // 不支持的内容类型
 )
 
 // Pack 包结构
 type Pack struct {
 	Head    PackHead
 	Payload []byte
+	// Metadata 元数据，如content-type、authorization，仅v2及以上版本编码
+	Metadata map[string]string
 }
 
 // PackHead 包头，固定长度packHeadLen
@@ -50,7 +65,9 @@ func NewPackCodec() *PackCodec {
 	return &PackCodec{}
 }
 
-// Decode 解码包
+// Decode 解码包，v2及以上版本的包头后为元数据：
+//
+//	数量(2字节) + [键长度(2字节) + 键 + 值长度(2字节) + 值]...
 func (p *PackCodec) Decode(data []byte) (*Pack, error) {
 	if len(data) < packHeadLen {
 		return nil, ErrPacketTooSmall
@@ -67,8 +84,19 @@ func (p *PackCodec) Decode(data []byte) (*Pack, error) {
 		return nil, fmt.Errorf("packet length mismatch: expected %d, got %d", pl, len(data))
 	}
 
+	// 解析元数据
+	offset := packHeadLen
+	var metadata map[string]string
+	if version >= Version2 {
+		var err error
+		metadata, offset, err = decodeMetadata(data, offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// 计算 payload 长度
-	payloadLen := int(pl) - packHeadLen
+	payloadLen := int(pl) - offset
 	if payloadLen < 0 {
 		return nil, ErrPayloadLenErr
 	}
@@ -76,11 +104,11 @@ func (p *PackCodec) Decode(data []byte) (*Pack, error) {
 	// 提取 payload 数据
 	var payload []byte
 	if payloadLen > 0 {
-		if len(data) < packHeadLen+payloadLen {
+		if len(data) < offset+payloadLen {
 			return nil, io.ErrShortBuffer
 		}
 		payload = make([]byte, payloadLen)
-		copy(payload, data[packHeadLen:packHeadLen+payloadLen])
+		copy(payload, data[offset:offset+payloadLen])
 	}
 
 	// 构造 Pack 对象并返回
@@ -91,15 +119,28 @@ func (p *PackCodec) Decode(data []byte) (*Pack, error) {
 			OpCode:  opCode,
 			Version: version,
 		},
-		Payload: payload,
+		Payload:  payload,
+		Metadata: metadata,
 	}
 	return pack, nil
 }
 
 // Encode 编码包
 func (p *PackCodec) Encode(pack *Pack) ([]byte, error) {
+	// 携带元数据时使用v2版本
+	if len(pack.Metadata) > 0 && pack.Head.Version < Version2 {
+		pack.Head.Version = Version2
+	}
+	metaLen := 0
+	if pack.Head.Version >= Version2 {
+		var err error
+		if metaLen, err = metadataLen(pack.Metadata); err != nil {
+			return nil, err
+		}
+	}
+
 	// 计算总长度
-	totalLen := packHeadLen + len(pack.Payload)
+	totalLen := packHeadLen + metaLen + len(pack.Payload)
 	pack.Head.Len = uint32(totalLen)
 
 	// 设置默认操作码
@@ -116,10 +157,113 @@ func (p *PackCodec) Encode(pack *Pack) ([]byte, error) {
 	binary.BigEndian.PutUint16(data[8:10], pack.Head.OpCode)
 	binary.BigEndian.PutUint16(data[10:12], pack.Head.Version)
 
+	// 编码元数据
+	offset := packHeadLen
+	if pack.Head.Version >= Version2 {
+		offset = encodeMetadata(data, offset, pack.Metadata)
+	}
+
 	// 复制 payload
 	if len(pack.Payload) > 0 {
-		copy(data[packHeadLen:], pack.Payload)
+		copy(data[offset:], pack.Payload)
+	}
+
+	return data, nil
+}
+
+// metadataLen 计算元数据编码后的长度
+func metadataLen(metadata map[string]string) (int, error) {
+	if len(metadata) > math.MaxUint16 {
+		return 0, ErrMetadataErr
+	}
+	l := 2
+	for k, v := range metadata {
+		if len(k) > math.MaxUint16 || len(v) > math.MaxUint16 {
+			return 0, ErrMetadataErr
+		}
+		l += 4 + len(k) + len(v)
+	}
+	return l, nil
+}
+
+// encodeMetadata 从offset开始写入元数据，返回写入后的偏移
+func encodeMetadata(data []byte, offset int, metadata map[string]string) int {
+	binary.BigEndian.PutUint16(data[offset:], uint16(len(metadata)))
+	offset += 2
+	for k, v := range metadata {
+		binary.BigEndian.PutUint16(data[offset:], uint16(len(k)))
+		offset += 2
+		offset += copy(data[offset:], k)
+		binary.BigEndian.PutUint16(data[offset:], uint16(len(v)))
+		offset += 2
+		offset += copy(data[offset:], v)
+	}
+	return offset
+}
+
+// decodeMetadata 从offset开始解析元数据，返回元数据和解析后的偏移
+func decodeMetadata(data []byte, offset int) (map[string]string, int, error) {
+	readString := func() (string, error) {
+		if len(data) < offset+2 {
+			return "", ErrMetadataErr
+		}
+		l := int(binary.BigEndian.Uint16(data[offset:]))
+		offset += 2
+		if len(data) < offset+l {
+			return "", ErrMetadataErr
+		}
+		str := string(data[offset : offset+l])
+		offset += l
+		return str, nil
 	}
 
+	if len(data) < offset+2 {
+		return nil, offset, ErrMetadataErr
+	}
+	count := int(binary.BigEndian.Uint16(data[offset:]))
+	offset += 2
+	if count == 0 {
+		return nil, offset, nil
+	}
+
+	metadata := make(map[string]string, count)
+	for i := 0; i < count; i++ {
+		k, err := readString()
+		if err != nil {
+			return nil, offset, err
+		}
+		v, err := readString()
+		if err != nil {
+			return nil, offset, err
+		}
+		metadata[k] = v
+	}
+	return metadata, offset, nil
+}
+
+// ReadFrame 从流中读取一个完整的包，包头的Len字段作为帧长度
+// 在包边界读到流结束时返回io.EOF，包不完整时返回io.ErrUnexpectedEOF
+func ReadFrame(r io.Reader, maxSize int) ([]byte, error) {
+	var head [packHeadLen]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+
+	pl := binary.BigEndian.Uint32(head[0:4])
+	if pl < packHeadLen {
+		return nil, ErrPayloadLenErr
+	}
+	if maxSize > 0 && int(pl) > maxSize {
+		return nil, fmt.Errorf("%w: %d exceeds %d", ErrFrameTooLarge, pl, maxSize)
+	}
+
+	data := make([]byte, pl)
+	copy(data, head[:])
+	if _, err := io.ReadFull(r, data[packHeadLen:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
 	return data, nil
 }
@@ -2,6 +2,8 @@ package quic
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"testing"
 )
 
@@ -203,3 +205,96 @@ func TestPackCodec_EncodeDecodeRoundTrip(t *testing.T) {
 		t.Errorf("Round trip payload mismatch: got %v, want %v", decoded.Payload, original.Payload)
 	}
 }
+
+func TestReadFrame(t *testing.T) {
+	codec := NewPackCodec()
+
+	first, err := codec.Encode(&Pack{Head: PackHead{SQID: 1, OpCode: 1000, Version: 1}, Payload: []byte("first")})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	second, err := codec.Encode(&Pack{Head: PackHead{SQID: 2, OpCode: 1000, Version: 1}, Payload: make([]byte, 100*1024)})
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	r := bytes.NewReader(append(append([]byte{}, first...), second...))
+	for _, want := range [][]byte{first, second} {
+		got, err := ReadFrame(r, DefaultMaxFrameSize)
+		if err != nil {
+			t.Fatalf("ReadFrame failed: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("ReadFrame got %d bytes, want %d bytes", len(got), len(want))
+		}
+	}
+	if _, err = ReadFrame(r, DefaultMaxFrameSize); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadFrame at end error = %v, want %v", err, io.EOF)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		maxSize int
+		wantErr error
+	}{
+		{name: "truncated payload", data: first[:len(first)-1], maxSize: DefaultMaxFrameSize, wantErr: io.ErrUnexpectedEOF},
+		{name: "truncated head", data: first[:4], maxSize: DefaultMaxFrameSize, wantErr: io.ErrUnexpectedEOF},
+		{name: "frame too large", data: second, maxSize: 1024, wantErr: ErrFrameTooLarge},
+		{name: "invalid length", data: make([]byte, packHeadLen), maxSize: DefaultMaxFrameSize, wantErr: ErrPayloadLenErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadFrame(bytes.NewReader(tt.data), tt.maxSize)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ReadFrame() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPackCodec_Metadata(t *testing.T) {
+	codec := NewPackCodec()
+
+	original := &Pack{
+		Head: PackHead{
+			SQID:    1,
+			OpCode:  1000,
+			Version: Version1,
+		},
+		Payload: []byte("payload"),
+		Metadata: map[string]string{
+			"content-type":  "application/json",
+			"authorization": "token",
+		},
+	}
+
+	data, err := codec.Encode(original)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if original.Head.Version != Version2 {
+		t.Errorf("Version = %d, want %d", original.Head.Version, Version2)
+	}
+
+	decoded, err := codec.Decode(data)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if string(decoded.Payload) != "payload" {
+		t.Errorf("Payload = %s, want payload", decoded.Payload)
+	}
+	if len(decoded.Metadata) != 2 || decoded.Metadata["authorization"] != "token" ||
+		decoded.Metadata["content-type"] != "application/json" {
+		t.Errorf("Metadata = %v, want %v", decoded.Metadata, original.Metadata)
+	}
+
+	// 截断元数据
+	broken := make([]byte, packHeadLen+3)
+	copy(broken, data[:packHeadLen+3])
+	broken[3] = byte(len(broken))
+	if _, err = codec.Decode(broken); !errors.Is(err, ErrMetadataErr) {
+		t.Errorf("decode truncated metadata error = %v, want %v", err, ErrMetadataErr)
+	}
+}
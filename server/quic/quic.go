@@ -3,18 +3,22 @@ package quic
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/ilaziness/gokit/config"
 	"github.com/ilaziness/gokit/hook"
 	"github.com/ilaziness/gokit/log"
+	gnet "github.com/ilaziness/gokit/net"
 	"github.com/ilaziness/gokit/process"
 	"github.com/quic-go/quic-go"
 )
@@ -25,6 +29,10 @@ const (
 	defaultIdleTimeout      = 30 * time.Second
 	defaultKeepAlive        = 15 * time.Second
 	defaultHandshakeTimeout = 10 * time.Second
+	defaultShutdownTimeout  = 5 * time.Second
+	connFlushDelay          = 200 * time.Millisecond // 关闭连接前等待已写入的数据发送
+
+	nextProto = "quic-server" // TLS应用层协议
 )
 
 type (
@@ -43,12 +51,28 @@ type Server struct {
 	handlers       map[OpCode]Handler
 	streamHandlers map[OpCode]StreamHandler
 	middlewares    []Handler
+	enhancer       Handler                         // 在中间件之前执行，数据报和流请求都会经过，由EnhancedServer设置
+	onStart        func(ctx context.Context) error // 监听成功后执行，返回错误时启动失败，由EnhancedServer设置
+	onStop         func()                          // 收到退出信号后、关闭监听前执行，由EnhancedServer设置
+	streamRecovery bool                            // 恢复流处理器的panic，默认开启
+	conns          *ConnRegistry
+	keyLog         *os.File           // TLS密钥日志文件，Debug模式下配置key_log_file时打开
+	cert           *gnet.CertReloader // 证书文件变化后自动重新加载
 	ctxPool        sync.Pool
 	streamCtxPool  sync.Pool
 	packCodec      Codec
 	listener       *quic.Listener
 	tlsConfig      *tls.Config
 	quicConfig     *quic.Config
+	healthCert     *tls.Certificate // 开启mTLS时自身健康检查使用的客户端证书
+
+	mu       sync.Mutex         // 保护listener、stop
+	stop     context.CancelFunc // 结束Serve
+	closing  atomic.Bool        // Shutdown后拒绝新连接
+	inflight process.Tracker    // 处理中的请求
+	// streams 流处理器context的父context，Shutdown等待处理中的请求之前取消，结束订阅等长时间运行的流处理器
+	streams       context.Context
+	cancelStreams context.CancelFunc
 }
 
 // NewQUIC 创建一个QUIC服务，不含任何中间件
@@ -69,13 +93,18 @@ func newBaseQUIC(config *config.QUICServer) *Server {
 		workerNum = config.WorkerNum
 	}
 
+	streams, cancelStreams := context.WithCancel(context.Background())
 	return &Server{
 		config:         config,
+		streams:        streams,
+		cancelStreams:  cancelStreams,
 		workerSem:      make(chan struct{}, workerNum),
 		handlers:       make(map[OpCode]Handler),
 		streamHandlers: make(map[OpCode]StreamHandler),
 		packCodec:      NewPackCodec(),
 		middlewares:    []Handler{},
+		streamRecovery: true,
+		conns:          newConnRegistry(),
 		ctxPool: sync.Pool{
 			New: func() any {
 				return &Context{
@@ -104,17 +133,55 @@ func (s *Server) AddStreamHandler(oc OpCode, h StreamHandler) {
 	s.streamHandlers[oc] = h
 }
 
-// AddMiddleware 添加中间件
+// Conns 获取活跃连接注册表，用于向指定连接主动发起流
+func (s *Server) Conns() *ConnRegistry {
+	return s.conns
+}
+
+// AddMiddleware 添加中间件，数据报和流请求都会经过，中间件中可通过ctx.IsStream区分
 func (s *Server) AddMiddleware(ms ...Handler) {
 	s.middlewares = append(s.middlewares, ms...)
 }
 
+// SetStreamRecovery 设置是否恢复流处理器的panic，默认开启，panic时返回OpCodeServerErr并关闭流
+func (s *Server) SetStreamRecovery(enabled bool) {
+	s.streamRecovery = enabled
+}
+
 func (s *Server) setDefaultMiddleware() {
 	s.AddMiddleware(Ping)
 }
 
-// Start 启动QUIC服务器
+// Start 启动QUIC服务器并阻塞直到收到退出信号，同时运行多个服务时使用server.App
 func (s *Server) Start() {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Serve()
+	}()
+
+	// 等待退出信号
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errc:
+		if err != nil {
+			panic(err)
+		}
+	case <-quit:
+	}
+
+	log.Logger.Infoln("Shutdown Server ...")
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		log.Warn(ctx, "shutdown error: %s", err)
+	}
+	hook.Exit.Trigger()
+	log.Logger.Infoln("Server Shutdown")
+}
+
+// Serve 监听并处理连接，阻塞直到Shutdown，初始化配置或监听失败时返回错误
+func (s *Server) Serve() error {
 	if s.config.Debug {
 		log.SetLevel(log.ModeDebug)
 	} else {
@@ -122,41 +189,87 @@ func (s *Server) Start() {
 	}
 
 	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
 
+	s.mu.Lock()
+	if s.closing.Load() {
+		s.mu.Unlock()
+		return nil
+	}
 	// 初始化TLS和QUIC配置
 	if err := s.initConfigs(); err != nil {
-		panic(fmt.Sprintf("init configs error: %v", err))
+		s.mu.Unlock()
+		s.closeDebug()
+		s.closeCert()
+		return fmt.Errorf("init configs error: %w", err)
 	}
 
 	// 创建QUIC监听器
-	var err error
-	s.listener, err = quic.ListenAddr(s.config.Address, s.tlsConfig, s.quicConfig)
+	listener, err := quic.ListenAddr(s.config.Address, s.tlsConfig, s.quicConfig)
 	if err != nil {
-		panic(fmt.Sprintf("listen QUIC error: %v", err))
+		s.mu.Unlock()
+		s.closeDebug()
+		s.closeCert()
+		return fmt.Errorf("listen QUIC error: %w", err)
+	}
+	s.listener = listener
+	s.stop = stop
+	s.mu.Unlock()
+
+	log.Info(ctx, "QUIC server start at: %s", listener.Addr())
+
+	if s.onStart != nil {
+		if err = s.onStart(ctx); err != nil {
+			_ = listener.Close()
+			s.closeDebug()
+			s.closeCert()
+			return fmt.Errorf("start QUIC server error: %w", err)
+		}
 	}
-
-	log.Info(ctx, "QUIC server start at: %s", s.listener.Addr())
 
 	// 启动连接处理协程
 	process.SafeGo(func() {
 		s.handleConnections(ctx)
 	})
 
-	// 等待退出信号
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	<-ctx.Done()
+	return nil
+}
+
+// Shutdown 拒绝新连接并等待处理中的请求完成，然后关闭监听器和所有连接，ctx结束时不再等待
+//
+// 等待之前取消流处理器的context，长时间运行的流处理器需要在ctx.Done()后返回
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing.Store(true)
+	listener, stop := s.listener, s.stop
+	s.mu.Unlock()
+	if listener == nil {
+		return nil
+	}
+
+	if s.onStop != nil {
+		s.onStop()
+	}
+	s.cancelStreams()
+	err := s.inflight.Wait(ctx)
+	if err == nil && s.conns.Len() > 0 {
+		// CloseWithError会丢弃未发送的流数据，关闭连接前等待响应发送完成
+		select {
+		case <-ctx.Done():
+		case <-time.After(connFlushDelay):
+		}
+	}
 	stop()
-	hook.Exit.Trigger()
 
 	// 关闭监听器
-	if err = s.listener.Close(); err != nil {
-		log.Warn(ctx, "close QUIC listener error: %s", err)
+	if closeErr := listener.Close(); closeErr != nil {
+		log.Warn(ctx, "close QUIC listener error: %s", closeErr)
+		err = errors.Join(err, closeErr)
 	}
-
-	log.Logger.Infoln("Shutdown Server ...")
-	time.Sleep(time.Second * 2)
-	log.Logger.Infoln("Server Shutdown")
+	s.closeDebug()
+	s.closeCert()
+	return err
 }
 
 // initConfigs 初始化TLS和QUIC配置
@@ -169,7 +282,7 @@ func (s *Server) initConfigs() error {
 
 	// 初始化QUIC配置
 	s.quicConfig = s.createQUICConfig()
-	return nil
+	return s.initDebug()
 }
 
 // createTLSConfig 创建TLS配置
@@ -179,16 +292,17 @@ func (s *Server) createTLSConfig() *tls.Config {
 		return nil
 	}
 
-	cert, err := tls.LoadX509KeyPair(s.config.CertFile, s.config.KeyFile)
+	cert, err := gnet.NewCertReloader(s.config.CertFile, s.config.KeyFile)
 	if err != nil {
 		log.Error(context.Background(), "load TLS config error: %s", err)
 		return nil
 	}
+	s.cert = cert
 
-	return &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		NextProtos:   []string{"quic-server"},
-		MinVersion:   tls.VersionTLS13, // QUIC requires TLS 1.3
+	tlsConfig := &tls.Config{
+		GetCertificate: cert.GetCertificate,
+		NextProtos:     []string{nextProto},
+		MinVersion:     tls.VersionTLS13, // QUIC requires TLS 1.3
 		CurvePreferences: []tls.CurveID{
 			tls.X25519MLKEM768,
 			tls.CurveP256,
@@ -200,6 +314,39 @@ func (s *Server) createTLSConfig() *tls.Config {
 			tls.TLS_CHACHA20_POLY1305_SHA256,
 		},
 	}
+
+	if s.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(s.config.ClientCAFile)
+		if err != nil {
+			log.Error(context.Background(), "load client CA error: %s", err)
+			s.closeCert()
+			return nil
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			log.Error(context.Background(), "no certificate found in %s", s.config.ClientCAFile)
+			s.closeCert()
+			return nil
+		}
+		// 信任只在内存中的健康检查证书，自身健康检查不需要配置客户端证书
+		if s.healthCert, err = newHealthCert(); err != nil {
+			log.Error(context.Background(), "create health check certificate error: %s", err)
+			s.closeCert()
+			return nil
+		}
+		pool.AddCert(s.healthCert.Leaf)
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig
+}
+
+// closeCert 停止监听证书文件
+func (s *Server) closeCert() {
+	if s.cert != nil {
+		_ = s.cert.Close()
+		s.cert = nil
+	}
 }
 
 // createQUICConfig 创建QUIC配置
@@ -255,6 +402,10 @@ func (s *Server) handleConnections(ctx context.Context) {
 				continue
 			}
 
+			if s.closing.Load() {
+				_ = conn.CloseWithError(0, "server shutting down")
+				continue
+			}
 			log.Debug(ctx, "accepted QUIC connection from: %s", conn.RemoteAddr())
 
 			// 为每个连接启动处理协程
@@ -267,7 +418,10 @@ func (s *Server) handleConnections(ctx context.Context) {
 
 // handleConnection 处理单个QUIC连接
 func (s *Server) handleConnection(ctx context.Context, conn quic.Connection) {
+	c := newConn(conn, s.packCodec, s.maxFrameSize())
+	s.conns.add(c)
 	defer func() {
+		s.conns.remove(c)
 		if err := conn.CloseWithError(0, "server shutdown"); err != nil {
 			log.Debug(ctx, "close connection error: %s", err)
 		}
@@ -275,7 +429,7 @@ func (s *Server) handleConnection(ctx context.Context, conn quic.Connection) {
 
 	// 启动数据报处理协程
 	process.SafeGo(func() {
-		s.handleDatagrams(ctx, conn)
+		s.handleDatagrams(ctx, c)
 	})
 
 	// 处理流连接
@@ -300,14 +454,15 @@ func (s *Server) handleConnection(ctx context.Context, conn quic.Connection) {
 
 			// 为每个流启动处理协程
 			process.SafeGo(func() {
-				s.handleStream(ctx, conn, stream)
+				s.handleStream(ctx, c, stream)
 			})
 		}
 	}
 }
 
 // handleDatagrams 处理数据报
-func (s *Server) handleDatagrams(ctx context.Context, conn quic.Connection) {
+func (s *Server) handleDatagrams(ctx context.Context, c *Conn) {
+	conn := c.conn
 	for {
 		select {
 		case <-ctx.Done():
@@ -327,16 +482,18 @@ func (s *Server) handleDatagrams(ctx context.Context, conn quic.Connection) {
 
 			log.Debug(ctx, "received datagram from: %s, size: %d", conn.RemoteAddr(), len(data))
 
-			// 异步处理数据报
+			// 异步处理数据报，启动协程前计数，避免Shutdown等待时漏掉刚收到的数据报
+			s.inflight.Add()
 			process.SafeGo(func() {
-				s.handleDatagram(ctx, conn, data)
+				defer s.inflight.Done()
+				s.handleDatagram(ctx, c, data)
 			})
 		}
 	}
 }
 
-// handleDatagram 处理单个数据报
-func (s *Server) handleDatagram(ctx context.Context, conn quic.Connection, data []byte) {
+// handleDatagram 处理单个数据报，调用方负责inflight计数
+func (s *Server) handleDatagram(ctx context.Context, c *Conn, data []byte) {
 	s.workerSem <- struct{}{}
 	defer func() {
 		<-s.workerSem
@@ -353,71 +510,144 @@ func (s *Server) handleDatagram(ctx context.Context, conn quic.Connection, data
 	ctxObj := s.ctxPool.Get().(*Context)
 	defer s.ctxPool.Put(ctxObj)
 
-	ctxObj.Reset(conn, s.packCodec)
+	ctxObj.Reset(c.conn, s.packCodec)
+	ctxObj.handle = c
 	ctxObj.SetData(pack)
 
 	// 设置中间件和处理器
-	ctxObj.handler = s.middlewares
 	handler := s.handlers[ctxObj.OpCode]
 	if handler == nil {
-		ctxObj.handler = append(ctxObj.handler, func(ctx *Context) {
+		handler = func(ctx *Context) {
 			_ = ctx.WriteNotFound()
-		})
-	} else {
-		ctxObj.handler = append(ctxObj.handler, handler)
+		}
 	}
+	ctxObj.handler = s.buildChain(handler)
 
 	// 执行处理链
 	ctxObj.Next()
 	log.Debug(ctx, "processed datagram: %s", pack.Payload)
 }
 
-// handleStream 处理流
-func (s *Server) handleStream(ctx context.Context, conn quic.Connection, stream quic.Stream) {
+// handleStream 处理流，流上可以依次发送多个请求，每个请求为一个完整的包
+func (s *Server) handleStream(ctx context.Context, c *Conn, stream quic.Stream) {
+	conn := c.conn
 	defer stream.Close()
 
+	// 获取流上下文对象
+	streamCtx := s.streamCtxPool.Get().(*StreamContext)
+	defer s.streamCtxPool.Put(streamCtx)
+
+	streamCtx.Reset(conn, stream, s.packCodec)
+	streamCtx.handle = c
+	streamCtx.maxFrameSize = s.maxFrameSize()
+
+	for {
+		// 读取一个完整的包
+		data, err := ReadFrame(stream, streamCtx.maxFrameSize)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Warn(ctx, "read stream error: %s", err)
+				stream.CancelRead(0)
+			}
+			return
+		}
+
+		log.Debug(ctx, "received stream data from: %s, size: %d", conn.RemoteAddr(), len(data))
+
+		// 解码数据包
+		pack, err := s.packCodec.Decode(data)
+		if err != nil {
+			log.Warn(ctx, "decode stream packet error: %s", err)
+			stream.CancelRead(0)
+			return
+		}
+
+		streamCtx.SetData(pack)
+		if !s.serveStreamRequest(streamCtx) {
+			stream.CancelRead(0)
+			return
+		}
+		log.Debug(ctx, "processed stream: %s", pack.Payload)
+	}
+}
+
+// serveStreamRequest 执行流上的单个请求，与数据报一样经过enhancer和中间件，响应写入流
+// 流处理器panic时返回false，流上的数据状态未知，不再处理后续请求
+func (s *Server) serveStreamRequest(streamCtx *StreamContext) bool {
+	s.inflight.Add()
+	defer s.inflight.Done()
 	s.workerSem <- struct{}{}
 	defer func() {
 		<-s.workerSem
 	}()
 
-	// 读取流数据
-	buffer := make([]byte, defaultBufferSize)
-	n, err := stream.Read(buffer)
-	if err != nil && !errors.Is(err, io.EOF) {
-		log.Warn(ctx, "read stream error: %s", err)
-		return
-	}
-
-	if n == 0 {
-		return
-	}
+	ctxObj := s.ctxPool.Get().(*Context)
+	defer s.ctxPool.Put(ctxObj)
 
-	data := buffer[:n]
-	log.Debug(ctx, "received stream data from: %s, size: %d", conn.RemoteAddr(), n)
+	ctxObj.ResetForStream(streamCtx.conn, streamCtx.stream, s.packCodec)
+	ctxObj.handle = streamCtx.handle
+	ctxObj.SetData(streamCtx.Pack)
 
-	// 解码数据包
-	pack, err := s.packCodec.Decode(data)
-	if err != nil {
-		log.Warn(ctx, "decode stream packet error: %s", err)
-		return
+	// 查找流处理器，未注册时使用同一操作码的数据报处理器，客户端可按包大小选择数据报或流发送
+	ok := true
+	if handler := s.streamHandlers[streamCtx.OpCode]; handler != nil {
+		ctxObj.handler = s.buildChain(func(ctx *Context) {
+			ok = s.callStreamHandler(ctx, streamCtx, handler)
+		})
+	} else if h := s.handlers[streamCtx.OpCode]; h != nil {
+		ctxObj.handler = s.buildChain(h)
+	} else {
+		ctxObj.handler = s.buildChain(func(ctx *Context) {
+			_ = ctx.WriteNotFound()
+		})
 	}
+	ctxObj.Next()
+	return ok
+}
 
-	// 获取流上下文对象
-	streamCtx := s.streamCtxPool.Get().(*StreamContext)
-	defer s.streamCtxPool.Put(streamCtx)
+// callStreamHandler 执行流处理器，流处理器使用中间件处理后的上下文，处理完成后恢复，流上的后续请求不受影响
+func (s *Server) callStreamHandler(ctx *Context, streamCtx *StreamContext, handler StreamHandler) (ok bool) {
+	defer func(prev context.Context) {
+		streamCtx.Context = prev
+	}(streamCtx.Context)
+	// 流关闭或Shutdown时取消
+	hctx, cancel := context.WithCancel(ctx.Context)
+	stop := context.AfterFunc(s.streams, cancel)
+	defer func() {
+		stop()
+		cancel()
+	}()
+	streamCtx.Context = hctx
 
-	streamCtx.Reset(conn, stream, s.packCodec)
-	streamCtx.SetData(pack)
+	if !s.streamRecovery {
+		handler(streamCtx)
+		return true
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error(ctx, "stream handler panic recovered: %v\n%s", err, debug.Stack())
+			_ = streamCtx.ServerErr()
+			ok = false
+		}
+	}()
+	handler(streamCtx)
+	return true
+}
 
-	// 查找流处理器
-	handler := s.streamHandlers[streamCtx.OpCode]
-	if handler == nil {
-		_ = streamCtx.WriteNotFound()
-		return
+// buildChain 组装处理链
+func (s *Server) buildChain(handler Handler) []Handler {
+	chain := make([]Handler, 0, len(s.middlewares)+2)
+	if s.enhancer != nil {
+		chain = append(chain, s.enhancer)
 	}
+	chain = append(chain, s.middlewares...)
+	return append(chain, handler)
+}
 
-	// 执行流处理器
-	handler(streamCtx)
-	log.Debug(ctx, "processed stream: %s", pack.Payload)
+// maxFrameSize 流上单个包的最大长度
+func (s *Server) maxFrameSize() int {
+	if s.config.MaxFrameSize > 0 {
+		return s.config.MaxFrameSize
+	}
+	return DefaultMaxFrameSize
 }
@@ -0,0 +1,248 @@
+package quic
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ilaziness/gokit/log"
+	redisLib "github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitKeyFunc 限流键函数，返回空字符串时不限流
+type RateLimitKeyFunc func(ctx *Context) string
+
+// ClientIPKey 以客户端IP作为限流键，同一客户端的多个连接共用配额
+func ClientIPKey(ctx *Context) string {
+	addr := ctx.GetRemoteAddr()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// RateLimit 限流中间件，超出限制时返回OpCodeTooManyRequests，keyFunc为nil时使用ClientIPKey
+func RateLimit(limiter RateLimiterInterface, keyFunc RateLimitKeyFunc) Handler {
+	if keyFunc == nil {
+		keyFunc = ClientIPKey
+	}
+	return func(ctx *Context) {
+		key := keyFunc(ctx)
+		if key != "" && !limiter.Allow(key) {
+			log.Warn(ctx, "rate limit exceeded for client: %s", key)
+			_ = ctx.WriteWithOpCode(OpCodeTooManyRequests, nil)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// keyEntries 按键保存限流状态，超过ttl未访问的键在访问时清理
+type keyEntries[T any] struct {
+	mu        sync.Mutex
+	entries   map[string]*keyEntry[T]
+	ttl       time.Duration
+	lastSweep time.Time
+}
+
+type keyEntry[T any] struct {
+	state    T
+	lastSeen time.Time
+}
+
+func newKeyEntries[T any](ttl time.Duration) *keyEntries[T] {
+	return &keyEntries[T]{
+		entries:   make(map[string]*keyEntry[T]),
+		ttl:       ttl,
+		lastSweep: time.Now(),
+	}
+}
+
+// get 获取键的状态，不存在时使用newState创建，调用方需持有锁
+func (k *keyEntries[T]) get(key string, now time.Time, newState func() T) *keyEntry[T] {
+	if now.Sub(k.lastSweep) > k.ttl {
+		for name, e := range k.entries {
+			if now.Sub(e.lastSeen) > k.ttl {
+				delete(k.entries, name)
+			}
+		}
+		k.lastSweep = now
+	}
+	e, ok := k.entries[key]
+	if !ok {
+		e = &keyEntry[T]{state: newState()}
+		k.entries[key] = e
+	}
+	e.lastSeen = now
+	return e
+}
+
+func (k *keyEntries[T]) reset(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.entries, key)
+}
+
+func (k *keyEntries[T]) len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.entries)
+}
+
+// TokenBucketLimiter 令牌桶限流器，每个键每秒补充r个令牌，最多积累burst个
+type TokenBucketLimiter struct {
+	limit rate.Limit
+	burst int
+	keys  *keyEntries[*rate.Limiter]
+}
+
+// NewTokenBucketLimiter 创建令牌桶限流器，令牌桶装满后空闲的键会被清理
+func NewTokenBucketLimiter(r float64, burst int) *TokenBucketLimiter {
+	// 令牌桶从空到满的时间，空闲超过该时间的键与新建的键状态相同，可以清理
+	ttl := time.Minute
+	if r > 0 {
+		ttl = max(time.Duration(float64(burst)/r*float64(time.Second)), time.Second)
+	}
+	return &TokenBucketLimiter{
+		limit: rate.Limit(r),
+		burst: burst,
+		keys:  newKeyEntries[*rate.Limiter](ttl),
+	}
+}
+
+// Allow 消耗一个令牌，没有令牌时返回false
+func (l *TokenBucketLimiter) Allow(key string) bool {
+	now := time.Now()
+	l.keys.mu.Lock()
+	e := l.keys.get(key, now, func() *rate.Limiter {
+		return rate.NewLimiter(l.limit, l.burst)
+	})
+	l.keys.mu.Unlock()
+	return e.state.AllowN(now, 1)
+}
+
+// Reset 重置键的令牌桶
+func (l *TokenBucketLimiter) Reset(key string) {
+	l.keys.reset(key)
+}
+
+// SlidingWindowLimiter 滑动窗口限流器，每个键在任意window时间内最多limit个请求
+//
+// 使用滑动窗口计数：上一个窗口的计数按与当前时间的重叠比例折算，加上当前窗口的计数
+type SlidingWindowLimiter struct {
+	limit  int
+	window time.Duration
+	keys   *keyEntries[*slidingWindow]
+}
+
+type slidingWindow struct {
+	start time.Time // 当前窗口的开始时间
+	prev  int
+	curr  int
+}
+
+// NewSlidingWindowLimiter 创建滑动窗口限流器，空闲超过两个窗口的键会被清理
+func NewSlidingWindowLimiter(limit int, window time.Duration) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{
+		limit:  limit,
+		window: window,
+		keys:   newKeyEntries[*slidingWindow](2 * window),
+	}
+}
+
+// Allow 记录一次请求，窗口内请求数已达上限时返回false
+func (l *SlidingWindowLimiter) Allow(key string) bool {
+	now := time.Now()
+	l.keys.mu.Lock()
+	defer l.keys.mu.Unlock()
+
+	w := l.keys.get(key, now, func() *slidingWindow {
+		return &slidingWindow{start: now.Truncate(l.window)}
+	}).state
+
+	if elapsed := now.Sub(w.start); elapsed >= l.window {
+		if elapsed >= 2*l.window {
+			w.prev = 0
+		} else {
+			w.prev = w.curr
+		}
+		w.curr = 0
+		w.start = now.Truncate(l.window)
+	}
+
+	weight := 1 - float64(now.Sub(w.start))/float64(l.window)
+	if float64(w.prev)*weight+float64(w.curr) >= float64(l.limit) {
+		return false
+	}
+	w.curr++
+	return true
+}
+
+// Reset 重置键的窗口计数
+func (l *SlidingWindowLimiter) Reset(key string) {
+	l.keys.reset(key)
+}
+
+// redisSlidingWindowScript 使用有序集合记录窗口内的请求时间，KEYS[1]为限流键，ARGV为当前时间(微秒)、窗口(微秒)、上限、请求成员
+var redisSlidingWindowScript = redisLib.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], 0, now - window)
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], now, ARGV[4])
+redis.call('PEXPIRE', KEYS[1], math.ceil(window / 1000))
+return 1
+`)
+
+// RedisRateLimiter 基于redis的分布式滑动窗口限流器，多个服务实例共享配额
+type RedisRateLimiter struct {
+	client  redisLib.UniversalClient
+	prefix  string
+	limit   int
+	window  time.Duration
+	timeout time.Duration
+}
+
+// NewRedisRateLimiter 创建redis限流器，client通常为storage/redis.Client，prefix为限流键前缀
+//
+// redis不可用时放行请求，避免限流器故障导致服务不可用
+func NewRedisRateLimiter(client redisLib.UniversalClient, prefix string, limit int, window time.Duration) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		client:  client,
+		prefix:  prefix,
+		limit:   limit,
+		window:  window,
+		timeout: time.Second,
+	}
+}
+
+// Allow 记录一次请求，窗口内请求数已达上限时返回false
+func (l *RedisRateLimiter) Allow(key string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+
+	now := time.Now().UnixMicro()
+	member := strconv.FormatInt(now, 10) + "-" + newRequestID()[:8]
+	allowed, err := redisSlidingWindowScript.Run(ctx, l.client, []string{l.prefix + key},
+		now, l.window.Microseconds(), l.limit, member).Int()
+	if err != nil {
+		log.Warn(ctx, "redis rate limiter error: %s", err)
+		return true
+	}
+	return allowed == 1
+}
+
+// Reset 删除键的窗口记录
+func (l *RedisRateLimiter) Reset(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+	if err := l.client.Del(ctx, l.prefix+key).Err(); err != nil {
+		log.Warn(ctx, "redis rate limiter reset %s error: %s", key, err)
+	}
+}
@@ -0,0 +1,140 @@
+package quic
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilaziness/gokit/config"
+	redisLib "github.com/redis/go-redis/v9"
+)
+
+func TestTokenBucketLimiter(t *testing.T) {
+	limiter := NewTokenBucketLimiter(0.001, 3)
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("a") {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	if limiter.Allow("a") {
+		t.Error("request over burst should be denied")
+	}
+	if !limiter.Allow("b") {
+		t.Error("other key should be allowed")
+	}
+
+	limiter.Reset("a")
+	if !limiter.Allow("a") {
+		t.Error("request after reset should be allowed")
+	}
+}
+
+func TestSlidingWindowLimiter(t *testing.T) {
+	window := 100 * time.Millisecond
+	limiter := NewSlidingWindowLimiter(3, window)
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("a") {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	if limiter.Allow("a") {
+		t.Error("request over limit should be denied")
+	}
+
+	// 两个窗口后计数清零，客户端不会被永久限制
+	time.Sleep(2 * window)
+	if !limiter.Allow("a") {
+		t.Error("request in new window should be allowed")
+	}
+
+	limiter.Reset("a")
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("a") {
+			t.Fatalf("request %d after reset should be allowed", i)
+		}
+	}
+}
+
+func TestSlidingWindowLimiter_Concurrent(t *testing.T) {
+	limiter := NewSlidingWindowLimiter(50, time.Minute)
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if limiter.Allow("a") {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := allowed.Load(); n != 50 {
+		t.Errorf("allowed = %d, want 50", n)
+	}
+}
+
+func TestRateLimiter_Eviction(t *testing.T) {
+	limiter := NewSlidingWindowLimiter(10, 10*time.Millisecond)
+	for i := 0; i < 100; i++ {
+		limiter.Allow(fmt.Sprintf("client-%d", i))
+	}
+	time.Sleep(30 * time.Millisecond)
+	limiter.Allow("new")
+
+	if n := limiter.keys.len(); n != 1 {
+		t.Errorf("keys after eviction = %d, want 1", n)
+	}
+}
+
+func TestRateLimit_Middleware(t *testing.T) {
+	server := NewQUIC(newTestConfig(t))
+	server.AddMiddleware(RateLimiter(2, 60))
+	server.AddHandler(1000, func(ctx *Context) {
+		_ = ctx.Write([]byte("ok"))
+	})
+	addr := startTestServer(t, server)
+	client := newTestClient(t, &config.QUICClient{})
+
+	for i, want := range []OpCode{OpCodeResOK, OpCodeResOK, OpCodeTooManyRequests} {
+		resp, err := client.Request(context.Background(), addr, 1000, nil)
+		if err != nil {
+			t.Fatalf("request %d error: %v", i, err)
+		}
+		if OpCode(resp.Head.OpCode) != want {
+			t.Errorf("request %d OpCode = %d, want %d", i, resp.Head.OpCode, want)
+		}
+	}
+}
+
+func TestRedisRateLimiter(t *testing.T) {
+	client := redisLib.NewClient(&redisLib.Options{Addr: "127.0.0.1:6379"})
+	defer client.Close()
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("redis not available: %v", err)
+	}
+
+	limiter := NewRedisRateLimiter(client, "test:quic:ratelimit:", 3, time.Second)
+	limiter.Reset("a")
+	defer limiter.Reset("a")
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("a") {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	if limiter.Allow("a") {
+		t.Error("request over limit should be denied")
+	}
+	limiter.Reset("a")
+	if !limiter.Allow("a") {
+		t.Error("request after reset should be allowed")
+	}
+}
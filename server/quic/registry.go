@@ -0,0 +1,538 @@
+package quic
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ilaziness/gokit/log"
+	gnet "github.com/ilaziness/gokit/net"
+	"github.com/ilaziness/gokit/process"
+	"github.com/quic-go/quic-go"
+)
+
+const defaultFilePollInterval = time.Second
+
+var ErrServiceNotFound = errors.New("service not found")
+
+// MemoryRegistry 内存服务注册中心，只在当前进程内有效，用于本地开发和测试
+type MemoryRegistry struct {
+	mu       sync.RWMutex
+	services map[string]*ServiceInfo
+	watchers map[string][]chan []*ServiceInfo
+}
+
+// NewMemoryRegistry 创建内存服务注册中心
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		services: make(map[string]*ServiceInfo),
+		watchers: make(map[string][]chan []*ServiceInfo),
+	}
+}
+
+// Register 注册服务实例，ID相同时覆盖
+func (r *MemoryRegistry) Register(_ context.Context, service *ServiceInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.services[service.ID] = copyServiceInfo(service)
+	r.notify(service.Name)
+	return nil
+}
+
+// Deregister 注销服务实例
+func (r *MemoryRegistry) Deregister(_ context.Context, serviceID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	service, ok := r.services[serviceID]
+	if !ok {
+		return ErrServiceNotFound
+	}
+	delete(r.services, serviceID)
+	r.notify(service.Name)
+	return nil
+}
+
+// Discover 查询服务的所有实例
+func (r *MemoryRegistry) Discover(_ context.Context, serviceName string) ([]*ServiceInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.list(serviceName), nil
+}
+
+// Watch 监听服务实例变化，立即推送当前实例列表，之后每次变化推送最新列表，ctx结束时关闭通道
+func (r *MemoryRegistry) Watch(ctx context.Context, serviceName string) (<-chan []*ServiceInfo, error) {
+	ch := make(chan []*ServiceInfo, 1)
+
+	r.mu.Lock()
+	r.watchers[serviceName] = append(r.watchers[serviceName], ch)
+	ch <- r.list(serviceName)
+	r.mu.Unlock()
+
+	process.SafeGo(func() {
+		<-ctx.Done()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		watchers := r.watchers[serviceName]
+		for i, w := range watchers {
+			if w == ch {
+				r.watchers[serviceName] = append(watchers[:i], watchers[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	})
+	return ch, nil
+}
+
+// list 返回服务实例列表，调用方需持有锁
+func (r *MemoryRegistry) list(serviceName string) []*ServiceInfo {
+	var services []*ServiceInfo
+	for _, s := range r.services {
+		if s.Name == serviceName {
+			services = append(services, copyServiceInfo(s))
+		}
+	}
+	sortServices(services)
+	return services
+}
+
+// notify 通知服务的监听者，调用方需持有锁
+func (r *MemoryRegistry) notify(serviceName string) {
+	services := r.list(serviceName)
+	for _, ch := range r.watchers[serviceName] {
+		pushLatest(ch, services)
+	}
+}
+
+// FileRegistry 文件服务注册中心，实例以JSON保存在文件中，用于本地开发
+//
+// Unix上注册和注销时对path.lock加flock，可在同一台机器的多个进程间共享
+type FileRegistry struct {
+	mu           sync.Mutex
+	path         string
+	pollInterval time.Duration
+}
+
+// NewFileRegistry 创建文件服务注册中心，path为保存服务实例的JSON文件路径
+func NewFileRegistry(path string) *FileRegistry {
+	return &FileRegistry{
+		path:         path,
+		pollInterval: defaultFilePollInterval,
+	}
+}
+
+// Register 注册服务实例，ID相同时覆盖
+func (r *FileRegistry) Register(_ context.Context, service *ServiceInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	unlock, err := lockFile(r.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	services, err := r.load()
+	if err != nil {
+		return err
+	}
+	for i, s := range services {
+		if s.ID == service.ID {
+			services = append(services[:i], services[i+1:]...)
+			break
+		}
+	}
+	return r.save(append(services, service))
+}
+
+// Deregister 注销服务实例
+func (r *FileRegistry) Deregister(_ context.Context, serviceID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	unlock, err := lockFile(r.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	services, err := r.load()
+	if err != nil {
+		return err
+	}
+	for i, s := range services {
+		if s.ID == serviceID {
+			return r.save(append(services[:i], services[i+1:]...))
+		}
+	}
+	return ErrServiceNotFound
+}
+
+// Discover 查询服务的所有实例
+func (r *FileRegistry) Discover(_ context.Context, serviceName string) ([]*ServiceInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	services, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	return filterServices(services, serviceName), nil
+}
+
+// Watch 轮询文件监听服务实例变化，立即推送当前实例列表，之后每次变化推送最新列表，ctx结束时关闭通道
+func (r *FileRegistry) Watch(ctx context.Context, serviceName string) (<-chan []*ServiceInfo, error) {
+	last, err := r.Discover(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan []*ServiceInfo, 1)
+	ch <- last
+
+	process.SafeGo(func() {
+		defer close(ch)
+		ticker := time.NewTicker(r.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			services, err := r.Discover(ctx, serviceName)
+			if err != nil {
+				log.Warn(ctx, "watch file registry %s error: %s", r.path, err)
+				continue
+			}
+			if !reflect.DeepEqual(services, last) {
+				last = services
+				pushLatest(ch, services)
+			}
+		}
+	})
+	return ch, nil
+}
+
+func (r *FileRegistry) load() ([]*ServiceInfo, error) {
+	data, err := os.ReadFile(r.path)
+	if errors.Is(err, os.ErrNotExist) || len(data) == 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var services []*ServiceInfo
+	if err = json.Unmarshal(data, &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// save 先写临时文件再重命名，避免其他进程读到写了一半的文件
+func (r *FileRegistry) save(services []*ServiceInfo) error {
+	data, err := json.MarshalIndent(services, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+func filterServices(services []*ServiceInfo, serviceName string) []*ServiceInfo {
+	var result []*ServiceInfo
+	for _, s := range services {
+		if s.Name == serviceName {
+			result = append(result, s)
+		}
+	}
+	sortServices(result)
+	return result
+}
+
+func sortServices(services []*ServiceInfo) {
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].ID < services[j].ID
+	})
+}
+
+func copyServiceInfo(service *ServiceInfo) *ServiceInfo {
+	c := *service
+	c.Tags = append([]string(nil), service.Tags...)
+	if service.Meta != nil {
+		c.Meta = make(map[string]string, len(service.Meta))
+		for k, v := range service.Meta {
+			c.Meta[k] = v
+		}
+	}
+	return &c
+}
+
+// pushLatest 推送最新列表，监听者未及时读取时丢弃旧列表，调用方需保证同一通道只有一个发送者
+func pushLatest(ch chan []*ServiceInfo, services []*ServiceInfo) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- services
+}
+
+const (
+	defaultHealthInterval = 10 * time.Second
+	defaultHealthTimeout  = 3 * time.Second
+)
+
+// registerService 补全服务实例信息并注册，开启健康检查时启动检查协程
+func (s *EnhancedServer) registerService(ctx context.Context) error {
+	if s.registry == nil {
+		return nil
+	}
+	if s.service.Name == "" {
+		return errors.New("service name is required")
+	}
+	if err := s.completeServiceInfo(); err != nil {
+		return err
+	}
+	if err := s.registry.Register(ctx, s.service); err != nil {
+		return fmt.Errorf("register service: %w", err)
+	}
+	s.serviceMu.Lock()
+	s.serviceHealthy = true
+	s.serviceMu.Unlock()
+	log.Info(ctx, "service %s registered: %s %s:%d", s.service.Name, s.service.ID, s.service.Address, s.service.Port)
+
+	if s.service.Health.Enabled {
+		healthCtx, cancel := context.WithCancel(context.Background())
+		s.stopHealth = cancel
+		process.SafeGo(func() {
+			s.runHealthCheck(healthCtx)
+		})
+	}
+	return nil
+}
+
+// deregisterService 停止健康检查并注销服务实例
+func (s *EnhancedServer) deregisterService() {
+	if s.registry == nil {
+		return
+	}
+	if s.stopHealth != nil {
+		s.stopHealth()
+	}
+
+	s.serviceMu.Lock()
+	defer s.serviceMu.Unlock()
+	if !s.serviceHealthy {
+		return
+	}
+	s.serviceHealthy = false
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultHealthTimeout)
+	defer cancel()
+	if err := s.registry.Deregister(ctx, s.service.ID); err != nil {
+		log.Warn(ctx, "deregister service %s error: %s", s.service.ID, err)
+		return
+	}
+	log.Info(ctx, "service %s deregistered: %s", s.service.Name, s.service.ID)
+}
+
+// completeServiceInfo 使用监听地址补全服务地址、端口和ID
+func (s *EnhancedServer) completeServiceInfo() error {
+	addr, ok := s.listener.Addr().(*net.UDPAddr)
+	if !ok {
+		return fmt.Errorf("unexpected listener address %s", s.listener.Addr())
+	}
+	if s.service.Port == 0 {
+		s.service.Port = addr.Port
+	}
+	if s.service.Address == "" {
+		if addr.IP.IsUnspecified() {
+			ip, err := gnet.GetInternalIP()
+			if err != nil {
+				ip = "127.0.0.1"
+			}
+			s.service.Address = ip
+		} else {
+			s.service.Address = addr.IP.String()
+		}
+	}
+	if s.service.ID == "" {
+		s.service.ID = fmt.Sprintf("%s-%s", s.service.Name, net.JoinHostPort(s.service.Address, strconv.Itoa(s.service.Port)))
+	}
+	if s.service.Weight <= 0 {
+		s.service.Weight = 1
+	}
+	return nil
+}
+
+// runHealthCheck 按间隔执行健康检查，失败时从注册中心注销，恢复后重新注册
+func (s *EnhancedServer) runHealthCheck(ctx context.Context) {
+	interval := s.service.Health.Interval
+	if interval <= 0 {
+		interval = defaultHealthInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := s.checkHealth(ctx)
+		s.serviceMu.Lock()
+		// 加锁后再检查，deregisterService可能在检查期间注销了服务，此时不能重新注册
+		if ctx.Err() != nil {
+			s.serviceMu.Unlock()
+			return
+		}
+		switch {
+		case err != nil && s.serviceHealthy:
+			log.Warn(ctx, "service %s health check failed: %s", s.service.ID, err)
+			if err = s.registry.Deregister(ctx, s.service.ID); err != nil {
+				log.Warn(ctx, "deregister unhealthy service %s error: %s", s.service.ID, err)
+			} else {
+				s.serviceHealthy = false
+			}
+		case err == nil && !s.serviceHealthy:
+			if err = s.registry.Register(ctx, s.service); err != nil {
+				log.Warn(ctx, "register recovered service %s error: %s", s.service.ID, err)
+			} else {
+				s.serviceHealthy = true
+				log.Info(ctx, "service %s recovered", s.service.ID)
+			}
+		}
+		s.serviceMu.Unlock()
+	}
+}
+
+// checkHealth 执行一次健康检查
+//
+// Health.Path为空时与自身监听地址完成一次QUIC握手，开启mTLS时使用healthCert作为客户端证书并发送ping请求，
+// 否则将Path作为HTTP地址发起GET请求，返回2xx视为健康
+func (s *EnhancedServer) checkHealth(ctx context.Context) error {
+	timeout := s.service.Health.Timeout
+	if timeout <= 0 {
+		timeout = defaultHealthTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if s.service.Health.Path != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.service.Health.Path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("health check status %d", resp.StatusCode)
+		}
+		return nil
+	}
+
+	addr := *s.listener.Addr().(*net.UDPAddr)
+	if addr.IP.IsUnspecified() {
+		addr.IP = net.IPv4(127, 0, 0, 1)
+	}
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: true, //nolint:gosec // 只检查自身监听是否可用
+		NextProtos:         s.tlsConfig.NextProtos,
+	}
+	if s.healthCert != nil {
+		tlsConfig.Certificates = []tls.Certificate{*s.healthCert}
+	}
+	conn, err := quic.DialAddr(ctx, addr.String(), tlsConfig, &quic.Config{EnableDatagrams: true})
+	if err != nil {
+		return err
+	}
+	defer conn.CloseWithError(0, "health check") //nolint:errcheck
+	if s.healthCert == nil {
+		return nil
+	}
+	// TLS 1.3中服务端在客户端握手完成后才验证客户端证书，需要收到响应才能确认证书被接受
+	return s.pingSelf(ctx, conn)
+}
+
+// pingSelf 在新的流上发送ping请求，收到任意响应视为健康
+func (s *EnhancedServer) pingSelf(ctx context.Context, conn quic.Connection) error {
+	data, err := s.packCodec.Encode(&Pack{Head: PackHead{OpCode: uint16(OpCodePing), SQID: 1, Version: Version1}})
+	if err != nil {
+		return err
+	}
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetDeadline(deadline)
+	}
+	if _, err = stream.Write(data); err != nil {
+		return err
+	}
+	if err = stream.Close(); err != nil {
+		return err
+	}
+	_, err = ReadFrame(stream, s.maxFrameSize())
+	return err
+}
+
+// newHealthCert 生成自签名的健康检查客户端证书，私钥只保存在内存中
+func newHealthCert() (*tls.Certificate, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "quic-health-check"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(100, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv, Leaf: leaf}, nil
+}
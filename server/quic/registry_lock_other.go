@@ -0,0 +1,8 @@
+//go:build !unix
+
+package quic
+
+// lockFile 非Unix系统不支持flock，只有进程内的锁，FileRegistry不能在多个进程间共享
+func lockFile(string) (func(), error) {
+	return func() {}, nil
+}
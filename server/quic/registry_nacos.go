@@ -0,0 +1,206 @@
+package quic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ilaziness/gokit/config"
+	"github.com/ilaziness/gokit/process"
+	"github.com/nacos-group/nacos-sdk-go/v2/clients"
+	"github.com/nacos-group/nacos-sdk-go/v2/clients/naming_client"
+	"github.com/nacos-group/nacos-sdk-go/v2/model"
+	"github.com/nacos-group/nacos-sdk-go/v2/vo"
+)
+
+// nacos实例元数据中保存ServiceInfo字段的键
+const (
+	nacosMetaID   = "id"
+	nacosMetaTags = "tags"
+)
+
+// NacosRegistry nacos服务注册中心，注册临时实例，由nacos客户端维持心跳
+type NacosRegistry struct {
+	client naming_client.INamingClient
+	group  string
+
+	mu        sync.Mutex
+	instances map[string]*ServiceInfo // 已注册的实例，注销时需要IP和端口
+}
+
+// NewNacosRegistry 使用nacos配置创建服务注册中心，服务分组使用cfg.Group
+func NewNacosRegistry(cfg *config.Nacos) (*NacosRegistry, error) {
+	param, err := cfg.ClientParam()
+	if err != nil {
+		return nil, err
+	}
+	client, err := clients.NewNamingClient(param)
+	if err != nil {
+		return nil, err
+	}
+	return &NacosRegistry{
+		client:    client,
+		group:     cfg.Group,
+		instances: make(map[string]*ServiceInfo),
+	}, nil
+}
+
+// Register 注册服务实例
+func (r *NacosRegistry) Register(_ context.Context, service *ServiceInfo) error {
+	metadata := make(map[string]string, len(service.Meta)+2)
+	for k, v := range service.Meta {
+		metadata[k] = v
+	}
+	metadata[nacosMetaID] = service.ID
+	if len(service.Tags) > 0 {
+		metadata[nacosMetaTags] = strings.Join(service.Tags, ",")
+	}
+
+	ok, err := r.client.RegisterInstance(vo.RegisterInstanceParam{
+		Ip:          service.Address,
+		Port:        uint64(service.Port),
+		Weight:      float64(service.Weight),
+		Enable:      true,
+		Healthy:     true,
+		Metadata:    metadata,
+		ServiceName: service.Name,
+		GroupName:   r.group,
+		Ephemeral:   true,
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("register nacos instance %s failed", service.ID)
+	}
+
+	r.mu.Lock()
+	r.instances[service.ID] = copyServiceInfo(service)
+	r.mu.Unlock()
+	return nil
+}
+
+// Deregister 注销通过当前注册中心注册的服务实例
+func (r *NacosRegistry) Deregister(_ context.Context, serviceID string) error {
+	r.mu.Lock()
+	service, ok := r.instances[serviceID]
+	r.mu.Unlock()
+	if !ok {
+		return ErrServiceNotFound
+	}
+
+	ok, err := r.client.DeregisterInstance(vo.DeregisterInstanceParam{
+		Ip:          service.Address,
+		Port:        uint64(service.Port),
+		ServiceName: service.Name,
+		GroupName:   r.group,
+		Ephemeral:   true,
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("deregister nacos instance %s failed", serviceID)
+	}
+
+	r.mu.Lock()
+	delete(r.instances, serviceID)
+	r.mu.Unlock()
+	return nil
+}
+
+// Discover 查询服务的健康实例
+func (r *NacosRegistry) Discover(_ context.Context, serviceName string) ([]*ServiceInfo, error) {
+	instances, err := r.client.SelectInstances(vo.SelectInstancesParam{
+		ServiceName: serviceName,
+		GroupName:   r.group,
+		HealthyOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toServiceInfos(serviceName, instances), nil
+}
+
+// Watch 订阅服务实例变化，立即推送当前实例列表，ctx结束时取消订阅并关闭通道
+func (r *NacosRegistry) Watch(ctx context.Context, serviceName string) (<-chan []*ServiceInfo, error) {
+	services, err := r.Discover(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan []*ServiceInfo, 1)
+	ch <- services
+
+	var mu sync.Mutex
+	closed := false
+	param := &vo.SubscribeParam{
+		ServiceName: serviceName,
+		GroupName:   r.group,
+		SubscribeCallback: func(instances []model.Instance, err error) {
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if !closed {
+				pushLatest(ch, toServiceInfos(serviceName, healthyInstances(instances)))
+			}
+		},
+	}
+	if err = r.client.Subscribe(param); err != nil {
+		return nil, err
+	}
+
+	process.SafeGo(func() {
+		<-ctx.Done()
+		_ = r.client.Unsubscribe(param)
+		mu.Lock()
+		closed = true
+		close(ch)
+		mu.Unlock()
+	})
+	return ch, nil
+}
+
+// Close 关闭nacos客户端
+func (r *NacosRegistry) Close() {
+	r.client.CloseClient()
+}
+
+func healthyInstances(instances []model.Instance) []model.Instance {
+	var result []model.Instance
+	for _, ins := range instances {
+		if ins.Healthy && ins.Enable {
+			result = append(result, ins)
+		}
+	}
+	return result
+}
+
+func toServiceInfos(serviceName string, instances []model.Instance) []*ServiceInfo {
+	services := make([]*ServiceInfo, 0, len(instances))
+	for _, ins := range instances {
+		service := &ServiceInfo{
+			ID:      ins.InstanceId,
+			Name:    serviceName,
+			Address: ins.Ip,
+			Port:    int(ins.Port),
+			Weight:  int(ins.Weight),
+			Meta:    make(map[string]string, len(ins.Metadata)),
+		}
+		for k, v := range ins.Metadata {
+			switch k {
+			case nacosMetaID:
+				service.ID = v
+			case nacosMetaTags:
+				service.Tags = strings.Split(v, ",")
+			default:
+				service.Meta[k] = v
+			}
+		}
+		services = append(services, service)
+	}
+	sortServices(services)
+	return services
+}
@@ -0,0 +1,193 @@
+package quic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testRegistry(t *testing.T, registry ServiceRegistry) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watch, err := registry.Watch(ctx, "echo")
+	if err != nil {
+		t.Fatalf("Watch() error: %v", err)
+	}
+	if services := recvServices(t, watch); len(services) != 0 {
+		t.Fatalf("initial services = %d, want 0", len(services))
+	}
+
+	services := []*ServiceInfo{
+		{ID: "echo-1", Name: "echo", Address: "127.0.0.1", Port: 9001, Tags: []string{"v1"}},
+		{ID: "echo-2", Name: "echo", Address: "127.0.0.1", Port: 9002},
+		{ID: "other-1", Name: "other", Address: "127.0.0.1", Port: 9003},
+	}
+	for _, s := range services {
+		if err = registry.Register(ctx, s); err != nil {
+			t.Fatalf("Register(%s) error: %v", s.ID, err)
+		}
+	}
+
+	found, err := registry.Discover(ctx, "echo")
+	if err != nil {
+		t.Fatalf("Discover() error: %v", err)
+	}
+	if len(found) != 2 || found[0].ID != "echo-1" || found[0].Tags[0] != "v1" || found[1].Port != 9002 {
+		t.Errorf("Discover() = %+v", found)
+	}
+	if got := waitServices(t, watch, 2); got[1].ID != "echo-2" {
+		t.Errorf("watched services = %+v", got)
+	}
+
+	if err = registry.Deregister(ctx, "echo-1"); err != nil {
+		t.Fatalf("Deregister() error: %v", err)
+	}
+	if got := waitServices(t, watch, 1); got[0].ID != "echo-2" {
+		t.Errorf("watched services after deregister = %+v", got)
+	}
+	if err = registry.Deregister(ctx, "echo-1"); !errors.Is(err, ErrServiceNotFound) {
+		t.Errorf("Deregister() twice error = %v, want %v", err, ErrServiceNotFound)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-watch:
+		if ok {
+			// 可能还有未读取的列表
+			if _, ok = <-watch; ok {
+				t.Error("watch channel not closed after ctx done")
+			}
+		}
+	case <-time.After(time.Second):
+		t.Error("watch channel not closed after ctx done")
+	}
+}
+
+func recvServices(t *testing.T, ch <-chan []*ServiceInfo) []*ServiceInfo {
+	t.Helper()
+	select {
+	case services := <-ch:
+		return services
+	case <-time.After(2 * time.Second):
+		t.Fatal("watch timeout")
+		return nil
+	}
+}
+
+// waitServices 等待推送的实例数量达到n
+func waitServices(t *testing.T, ch <-chan []*ServiceInfo, n int) []*ServiceInfo {
+	t.Helper()
+	for {
+		if services := recvServices(t, ch); len(services) == n {
+			return services
+		}
+	}
+}
+
+func TestMemoryRegistry(t *testing.T) {
+	testRegistry(t, NewMemoryRegistry())
+}
+
+func TestFileRegistry(t *testing.T) {
+	registry := NewFileRegistry(filepath.Join(t.TempDir(), "services.json"))
+	registry.pollInterval = 20 * time.Millisecond
+	testRegistry(t, registry)
+
+	// 其他进程使用同一文件时可以发现已注册的实例
+	other := NewFileRegistry(registry.path)
+	services, err := other.Discover(context.Background(), "other")
+	if err != nil || len(services) != 1 {
+		t.Errorf("Discover() from another registry = %v, %v", services, err)
+	}
+}
+
+func TestFileRegistry_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.json")
+	// 每个注册中心模拟一个进程，并发注册不会相互覆盖
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			service := &ServiceInfo{ID: fmt.Sprintf("echo-%d", i), Name: "echo"}
+			if err := NewFileRegistry(path).Register(context.Background(), service); err != nil {
+				t.Errorf("Register() error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	services, err := NewFileRegistry(path).Discover(context.Background(), "echo")
+	if err != nil || len(services) != 20 {
+		t.Errorf("Discover() = %d services, %v, want 20", len(services), err)
+	}
+}
+
+func TestEnhancedServer_ServiceRegistry(t *testing.T) {
+	registry := NewMemoryRegistry()
+	server := NewEnhancedServer(newTestConfig(t))
+	server.WithServiceRegistry(registry, &ServiceInfo{
+		Name:   "echo",
+		Health: HealthCheck{Enabled: true, Interval: 50 * time.Millisecond, Timeout: time.Second},
+	})
+	startTestServer(t, server.Server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watch, err := registry.Watch(ctx, "echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recvServices(t, watch)
+
+	if err = server.registerService(ctx); err != nil {
+		t.Fatalf("registerService() error: %v", err)
+	}
+	services := waitServices(t, watch, 1)
+	if services[0].Port == 0 || services[0].Address == "" || services[0].ID == "" || services[0].Weight != 1 {
+		t.Errorf("registered service = %+v", services[0])
+	}
+
+	// 健康检查失败时注销
+	_ = server.listener.Close()
+	waitServices(t, watch, 0)
+
+	server.deregisterService()
+	if services, _ = registry.Discover(ctx, "echo"); len(services) != 0 {
+		t.Errorf("services after deregister = %d, want 0", len(services))
+	}
+}
+
+func TestEnhancedServer_ServiceRegistryRequiresName(t *testing.T) {
+	server := NewEnhancedServer(newTestConfig(t))
+	server.WithServiceRegistry(NewMemoryRegistry(), nil)
+	if err := server.registerService(context.Background()); err == nil {
+		t.Error("registerService() without name expected error")
+	}
+}
+
+func TestEnhancedServer_CheckHealthMTLS(t *testing.T) {
+	certFile, _ := writeTestClientCert(t, "client", nil)
+	cfg := newTestConfig(t)
+	cfg.ClientCAFile = certFile
+	server := NewEnhancedServer(cfg)
+	server.WithServiceRegistry(NewMemoryRegistry(), &ServiceInfo{Name: "echo"})
+	startTestServer(t, server.Server)
+
+	if err := server.checkHealth(context.Background()); err != nil {
+		t.Errorf("checkHealth() with mTLS error: %v", err)
+	}
+	// 不受信任的客户端证书被服务端拒绝
+	untrusted, err := newHealthCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.healthCert = untrusted
+	if err = server.checkHealth(context.Background()); err == nil {
+		t.Error("checkHealth() with untrusted certificate expected error")
+	}
+}
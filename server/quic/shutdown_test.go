@@ -0,0 +1,107 @@
+package quic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ilaziness/gokit/config"
+)
+
+// TestServer_Shutdown 关闭时等待处理中的请求完成，之后Serve返回
+func TestServer_Shutdown(t *testing.T) {
+	server := NewQUIC(newTestConfig(t))
+	handling := make(chan struct{})
+	server.AddStreamHandler(2000, func(ctx *StreamContext) {
+		close(handling)
+		time.Sleep(100 * time.Millisecond)
+		_ = ctx.Write([]byte("done"))
+	})
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.Serve()
+	}()
+	var addr string
+	for deadline := time.Now().Add(time.Second); addr == "" && time.Now().Before(deadline); {
+		server.mu.Lock()
+		if server.listener != nil {
+			addr = server.listener.Addr().String()
+		}
+		server.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if addr == "" {
+		t.Fatal("server not started")
+	}
+
+	client := newTestClient(t, &config.QUICClient{})
+	resp := make(chan *Pack, 1)
+	go func() {
+		pack, err := client.DoStream(context.Background(), addr, &Pack{Head: PackHead{OpCode: 2000, Version: Version1}})
+		if err != nil {
+			t.Errorf("DoStream() error: %v", err)
+		}
+		resp <- pack
+	}()
+	<-handling
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() error: %v", err)
+	}
+	if pack := <-resp; pack == nil || string(pack.Payload) != "done" {
+		t.Errorf("response = %+v, want done", pack)
+	}
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("Serve() error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Serve() not returned after Shutdown")
+	}
+}
+
+// TestServer_ShutdownCancelsStreams 关闭时取消长时间运行的流处理器，不等待到ctx超时
+func TestServer_ShutdownCancelsStreams(t *testing.T) {
+	server := NewQUIC(newTestConfig(t))
+	handling := make(chan struct{})
+	server.AddStreamHandler(2000, func(ctx *StreamContext) {
+		close(handling)
+		<-ctx.Done()
+	})
+
+	go func() {
+		_ = server.Serve()
+	}()
+	var addr string
+	for deadline := time.Now().Add(time.Second); addr == "" && time.Now().Before(deadline); {
+		server.mu.Lock()
+		if server.listener != nil {
+			addr = server.listener.Addr().String()
+		}
+		server.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if addr == "" {
+		t.Fatal("server not started")
+	}
+
+	client := newTestClient(t, &config.QUICClient{})
+	go func() {
+		_, _ = client.DoStream(context.Background(), addr, &Pack{Head: PackHead{OpCode: 2000, Version: Version1}})
+	}()
+	<-handling
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() error: %v", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("Shutdown() took %s, stream handler not canceled", d)
+	}
+}
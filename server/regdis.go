@@ -7,10 +7,8 @@ import (
 	"github.com/ilaziness/gokit/hook"
 	"github.com/ilaziness/gokit/log"
 	"github.com/ilaziness/gokit/net"
-	"github.com/jinzhu/copier"
 	"github.com/nacos-group/nacos-sdk-go/v2/clients"
 	"github.com/nacos-group/nacos-sdk-go/v2/clients/naming_client"
-	"github.com/nacos-group/nacos-sdk-go/v2/common/constant"
 	"github.com/nacos-group/nacos-sdk-go/v2/vo"
 )
 
@@ -47,27 +45,11 @@ func Registration(cfg *config.Nacos, appCfg *config.App) {
 		group:  cfg.Group,
 	}
 
-	cc := constant.ClientConfig{}
-	if err := copier.Copy(&cc, cfg.Client); err != nil {
+	param, err := cfg.ClientParam()
+	if err != nil {
 		panic(err)
 	}
-	cc.NamespaceId = cfg.Client.NamespaceID
-
-	var err error
-	var sc []constant.ServerConfig
-	for _, v := range cfg.Server {
-		sc = append(sc, constant.ServerConfig{
-			IpAddr: v.IP,
-			Port:   uint64(v.Port),
-		})
-	}
-
-	nacos.nacosClient, err = clients.NewNamingClient(
-		vo.NacosClientParam{
-			ClientConfig:  &cc,
-			ServerConfigs: sc,
-		},
-	)
+	nacos.nacosClient, err = clients.NewNamingClient(param)
 	if err != nil {
 		panic(err)
 	}
@@ -79,6 +61,14 @@ func Registration(cfg *config.Nacos, appCfg *config.App) {
 	})
 }
 
+// NamingClient 获取nacos命名服务客户端，未注册服务时返回nil
+func NamingClient() naming_client.INamingClient {
+	if defaultNameService == nil {
+		return nil
+	}
+	return defaultNameService.nacosClient
+}
+
 // GetInstance 获取服务得地址和端口
 func GetInstance(name string) (string, error) {
 	instance, err := defaultNameService.nacosClient.SelectOneHealthyInstance(vo.SelectOneHealthInstanceParam{
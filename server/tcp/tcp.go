@@ -10,17 +10,20 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/ilaziness/gokit/config"
 	"github.com/ilaziness/gokit/hook"
 	"github.com/ilaziness/gokit/log"
+	gnet "github.com/ilaziness/gokit/net"
 	"github.com/ilaziness/gokit/process"
 )
 
 const (
-	defaultWorkerNum = 100000
+	defaultWorkerNum       = 100000
+	defaultShutdownTimeout = 5 * time.Second
 )
 
 type (
@@ -37,6 +40,13 @@ type Server struct {
 	middlewares []Handler
 	ctxPool     sync.Pool
 	packCodec   Codec
+	cert        *gnet.CertReloader // 证书文件变化后自动重新加载
+
+	mu          sync.Mutex // 保护ln、conns
+	ln          net.Listener
+	conns       map[net.Conn]struct{}
+	closing     atomic.Bool
+	connTracker process.Tracker
 }
 
 // NewTCP 创建一个tcp服务，不含任何中间件
@@ -62,6 +72,7 @@ func newBaseTCP(config *config.TCPServer) *Server {
 		handlers:    make(map[OpCode]Handler),
 		packCodec:   NewPackCodec(),
 		middlewares: []Handler{},
+		conns:       make(map[net.Conn]struct{}),
 		ctxPool: sync.Pool{
 			New: func() any {
 				return &Context{
@@ -87,18 +98,44 @@ func (t *Server) setDefaultMiddleware() {
 	t.AddMiddleware(Ping)
 }
 
+// Start 启动服务并阻塞直到收到退出信号，同时运行多个服务时使用server.App
 func (t *Server) Start() {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- t.Serve()
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errc:
+		if err != nil {
+			panic(err)
+		}
+	case <-quit:
+	}
+
+	log.Logger.Infoln("Shutdown Server ...")
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	if err := t.Shutdown(ctx); err != nil {
+		log.Warn(ctx, "shutdown error: %s", err)
+	}
+	hook.Exit.Trigger()
+	log.Logger.Infoln("Server Shutdown")
+}
+
+// Serve 监听并处理连接，阻塞直到Shutdown，监听失败时返回错误
+func (t *Server) Serve() error {
 	if t.config.Debug {
 		log.SetLevel(log.ModeDebug)
 	} else {
 		log.SetLevel(log.ModeRelease)
 	}
-	// otel.InitTracer("tcp-server", &config.Otel{})
+	ctx := context.Background()
 
 	var ln net.Listener
 	var err error
-	ctx, stop := context.WithCancel(context.Background())
-
 	// 如果提供了TLS配置，则使用TLS监听
 	tlsConfig := t.loadTLSConfig()
 	if tlsConfig != nil {
@@ -107,40 +144,92 @@ func (t *Server) Start() {
 	} else {
 		ln, err = net.Listen("tcp", t.config.Address)
 	}
-
 	if err != nil {
-		panic(err)
+		t.closeCert()
+		return err
 	}
-	process.SafeGo(func() {
-		for {
-			conn, err := ln.Accept()
-			if err != nil && errors.Is(err, net.ErrClosed) {
-				return
-			}
-			if err != nil {
-				log.Error(ctx, "accept error: %s", err)
-				continue
-			}
-			log.Debug(ctx, "accept new conn: %s", conn.RemoteAddr())
-			process.SafeGo(func() {
-				t.handleConn(conn)
-			})
-		}
-	})
+
+	t.mu.Lock()
+	if t.closing.Load() {
+		t.mu.Unlock()
+		_ = ln.Close()
+		return nil
+	}
+	t.ln = ln
+	t.mu.Unlock()
+
 	log.Info(ctx, "tcp server start at: %s", ln.Addr())
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	stop()
-	hook.Exit.Trigger()
+	for {
+		conn, err := ln.Accept()
+		if err != nil && errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			log.Error(ctx, "accept error: %s", err)
+			continue
+		}
+		log.Debug(ctx, "accept new conn: %s", conn.RemoteAddr())
+		if !t.trackConn(conn) {
+			_ = conn.Close()
+			continue
+		}
+		process.SafeGo(func() {
+			defer t.untrackConn(conn)
+			t.handleConn(conn)
+		})
+	}
+}
 
-	if err = ln.Close(); err != nil {
-		log.Warn(ctx, "close listener error: %s", err)
+// Shutdown 关闭监听，停止读取新请求，等待处理中的请求完成后关闭连接，ctx结束时强制关闭所有连接
+func (t *Server) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	t.closing.Store(true)
+	var err error
+	if t.ln != nil {
+		err = t.ln.Close()
+	}
+	// 唤醒阻塞在读取上的连接，连接处理完已读取的请求后关闭
+	for conn := range t.conns {
+		_ = conn.SetReadDeadline(time.Now())
 	}
+	t.mu.Unlock()
 
-	log.Logger.Infoln("Shutdown Server ...")
-	time.Sleep(time.Second * 2)
-	log.Logger.Infoln("Server Shutdown")
+	if waitErr := t.connTracker.Wait(ctx); waitErr != nil {
+		t.mu.Lock()
+		for conn := range t.conns {
+			_ = conn.Close()
+		}
+		t.mu.Unlock()
+		err = errors.Join(err, waitErr)
+	}
+	t.closeCert()
+	return err
+}
+
+func (t *Server) trackConn(conn net.Conn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closing.Load() {
+		return false
+	}
+	t.conns[conn] = struct{}{}
+	t.connTracker.Add()
+	return true
+}
+
+func (t *Server) untrackConn(conn net.Conn) {
+	t.mu.Lock()
+	delete(t.conns, conn)
+	t.mu.Unlock()
+	t.connTracker.Done()
+}
+
+// closeCert 停止监听证书文件
+func (t *Server) closeCert() {
+	if t.cert != nil {
+		_ = t.cert.Close()
+		t.cert = nil
+	}
 }
 
 // 加载TLS配置
@@ -148,13 +237,14 @@ func (t *Server) loadTLSConfig() *tls.Config {
 	if t.config.CertFile == "" || t.config.KeyFile == "" {
 		return nil
 	}
-	cert, err := tls.LoadX509KeyPair(t.config.CertFile, t.config.KeyFile)
+	cert, err := gnet.NewCertReloader(t.config.CertFile, t.config.KeyFile)
 	if err != nil {
 		log.Error(context.Background(), "load tls config error: %s", err)
 		return nil
 	}
+	t.cert = cert
 	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{cert},
+		GetCertificate: cert.GetCertificate,
 		// 推荐的最低 TLS 版本
 		MinVersion: tls.VersionTLS12,
 		// 密钥交互算法列表
@@ -177,16 +267,23 @@ func (t *Server) loadTLSConfig() *tls.Config {
 }
 
 func (t *Server) handleConn(conn net.Conn) {
+	// 连接上已读取的请求处理完成后再关闭连接
+	var pending sync.WaitGroup
 	defer func(conn net.Conn) {
+		pending.Wait()
 		log.Debug(context.Background(), "connection closed: %s", conn.RemoteAddr())
 		err := conn.Close()
-		if err != nil {
+		if err != nil && !errors.Is(err, net.ErrClosed) {
 			slog.Warn("conn close error", "err", err.Error())
 		}
 	}(conn)
 	handleMessage := func() (ct bool) {
 		// 设置读取超时
 		_ = conn.SetDeadline(time.Now().Add(300 * time.Second))
+		// 在设置超时之后检查，避免覆盖Shutdown设置的读取超时
+		if t.closing.Load() {
+			return
+		}
 		ctx := t.ctxPool.Get().(*Context)
 		ctx.Reset(conn, t.packCodec)
 		pack, err := t.packCodec.Decode(conn)
@@ -195,16 +292,20 @@ func (t *Server) handleConn(conn net.Conn) {
 			return
 		}
 		if err != nil {
-			log.Warn(ctx, "decode error: %s", err)
+			if !t.closing.Load() {
+				log.Warn(ctx, "decode error: %s", err)
+			}
 			return
 		}
 		ctx.SetData(pack)
 		t.workerSem <- struct{}{}
 
 		// 处理数据包
+		pending.Add(1)
 		process.SafeGo(func() {
 			defer func() {
 				<-t.workerSem
+				pending.Done()
 			}()
 			defer t.ctxPool.Put(ctx)
 
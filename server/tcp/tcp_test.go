@@ -0,0 +1,102 @@
+package tcp
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ilaziness/gokit/config"
+	"github.com/ilaziness/gokit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain 日志写入临时目录，不在包目录下生成日志文件
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gokit-log")
+	if err != nil {
+		panic(err)
+	}
+	log.SetFile(filepath.Join(dir, "app.log"))
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// startTestServer 在随机端口启动服务，返回监听地址
+func startTestServer(t *testing.T, srv *Server) (string, chan error) {
+	t.Helper()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve()
+	}()
+	require.Eventually(t, func() bool {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		return srv.ln != nil
+	}, time.Second, 10*time.Millisecond)
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.ln.Addr().String(), errc
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	srv := NewTCP(&config.TCPServer{Address: "127.0.0.1:0"})
+	handling := make(chan struct{})
+	srv.AddHandler(1000, func(ctx *Context) {
+		close(handling)
+		time.Sleep(100 * time.Millisecond)
+		_ = ctx.Write([]byte("done"))
+	})
+	addr, errc := startTestServer(t, srv)
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	codec := NewPackCodec()
+	require.NoError(t, codec.Encode(conn, &Pack{Head: PackHead{SQID: 1, OpCode: 1000, Version: Version1}}))
+	<-handling
+
+	// 处理中的请求完成并写回响应后才关闭连接
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- srv.Shutdown(ctx)
+	}()
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	resp, err := codec.Decode(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "done", string(resp.Payload))
+	assert.NoError(t, <-shutdownErr)
+	assert.NoError(t, <-errc)
+
+	_, err = net.Dial("tcp", addr)
+	assert.Error(t, err, "listener should be closed")
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	srv := NewTCP(&config.TCPServer{Address: "127.0.0.1:0"})
+	handling := make(chan struct{})
+	srv.AddHandler(1000, func(*Context) {
+		close(handling)
+		time.Sleep(time.Second)
+	})
+	addr, _ := startTestServer(t, srv)
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, NewPackCodec().Encode(conn, &Pack{Head: PackHead{SQID: 1, OpCode: 1000, Version: Version1}}))
+	<-handling
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.ErrorIs(t, srv.Shutdown(ctx), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
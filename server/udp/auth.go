@@ -0,0 +1,340 @@
+package udp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrAuthFailed = errors.New("packet authentication failed")
+	ErrReplay     = errors.New("packet replayed")
+	ErrUnknownKey = errors.New("unknown auth key")
+)
+
+const (
+	authKeyIDLen     = 4                                      // 密钥ID长度
+	authSeqLen       = 8                                      // 序列号长度
+	authTagLen       = 16                                     // HMAC-SHA256截断后的标签长度
+	authTrailerLen   = authKeyIDLen + authSeqLen + authTagLen // 认证尾部长度
+	replayWindowSize = 64                                     // 重放窗口大小
+)
+
+// KeyStore 认证密钥存储，根据KeyID查找HMAC密钥
+type KeyStore interface {
+	Key(keyID uint32) ([]byte, error)
+}
+
+// SecretKeyStore 共享密钥存储，每个KeyID使用由共享密钥派生的独立密钥
+// 客户端应为每个会话随机选择KeyID，服务端按KeyID维护重放窗口
+type SecretKeyStore struct {
+	secret []byte
+}
+
+// NewSecretKeyStore 使用共享密钥创建密钥存储
+func NewSecretKeyStore(secret []byte) *SecretKeyStore {
+	return &SecretKeyStore{secret: secret}
+}
+
+// Key 派生KeyID对应的密钥
+func (s *SecretKeyStore) Key(keyID uint32) ([]byte, error) {
+	var id [authKeyIDLen]byte
+	binary.BigEndian.PutUint32(id[:], keyID)
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(id[:])
+	return mac.Sum(nil), nil
+}
+
+// AuthToken 认证令牌，由服务端签发（如通过HTTP接口）后下发给客户端
+type AuthToken struct {
+	KeyID    uint32    `json:"key_id"`
+	Key      []byte    `json:"key"`
+	ExpireAt time.Time `json:"expire_at"`
+}
+
+// TokenKeyStore 令牌密钥存储，保存已签发且未过期的令牌
+type TokenKeyStore struct {
+	mu     sync.RWMutex
+	tokens map[uint32]*AuthToken
+}
+
+// NewTokenKeyStore 创建令牌密钥存储
+func NewTokenKeyStore() *TokenKeyStore {
+	return &TokenKeyStore{tokens: make(map[uint32]*AuthToken)}
+}
+
+// Issue 签发一个有效期为ttl的令牌
+func (s *TokenKeyStore) Issue(ttl time.Duration) (*AuthToken, error) {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var id [authKeyIDLen]byte
+	for {
+		if _, err := rand.Read(id[:]); err != nil {
+			return nil, err
+		}
+		keyID := binary.BigEndian.Uint32(id[:])
+		if _, ok := s.tokens[keyID]; keyID == 0 || ok {
+			continue
+		}
+		token := &AuthToken{
+			KeyID:    keyID,
+			Key:      key,
+			ExpireAt: time.Now().Add(ttl),
+		}
+		s.tokens[keyID] = token
+		return token, nil
+	}
+}
+
+// Revoke 吊销令牌
+func (s *TokenKeyStore) Revoke(keyID uint32) {
+	s.mu.Lock()
+	delete(s.tokens, keyID)
+	s.mu.Unlock()
+}
+
+// Key 获取令牌密钥，令牌过期时返回ErrUnknownKey
+func (s *TokenKeyStore) Key(keyID uint32) ([]byte, error) {
+	s.mu.RLock()
+	token, ok := s.tokens[keyID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if time.Now().After(token.ExpireAt) {
+		s.Revoke(keyID)
+		return nil, ErrUnknownKey
+	}
+	return token.Key, nil
+}
+
+// authDir 包的发送方向，参与标签计算，避免对端发出的包被反射回对端
+type authDir byte
+
+const (
+	authDirToServer authDir = 1 // 客户端发往服务端
+	authDirToClient authDir = 2 // 服务端发往客户端
+)
+
+const (
+	// authMaxSkew 新KeyID的首个包序列号与本机时间允许的最大偏差
+	authMaxSkew = 5 * time.Minute
+	// authWindowTTL 重放窗口的空闲保留时间，为authMaxSkew的2倍，时钟超前的对端发出的旧包在窗口清理前已不能通过新窗口的时间检查
+	authWindowTTL = 2 * authMaxSkew
+)
+
+// AuthCodec 认证编解码器，包装其他Codec，在编码后的包尾追加认证信息：
+//
+//	KeyID(4字节) + Seq(8字节) + HMAC-SHA256标签(16字节)
+//
+// 标签覆盖包的发送方向，服务端和客户端发出的包不能互相冒充。
+// 序列号取本机纳秒时间且单调递增，解码时按KeyID使用滑动窗口拒绝重放的包，
+// 空闲超过authWindowTTL的窗口会被清理，新窗口只接受与本机时间偏差在authMaxSkew内的序列号
+type AuthCodec struct {
+	codec     Codec
+	keys      KeyStore
+	keyID     uint32
+	encodeDir authDir
+	decodeDir authDir
+	seq       atomic.Uint64
+	windows   sync.Map // keyID -> *replayWindow
+	lastSweep atomic.Int64
+}
+
+// NewAuthCodec 创建服务端认证编解码器，解码客户端发出的包，响应沿用请求的KeyID
+func NewAuthCodec(codec Codec, keys KeyStore) *AuthCodec {
+	return newAuthCodec(codec, keys, authDirToClient, authDirToServer)
+}
+
+// NewClientAuthCodec 创建客户端认证编解码器，keyID为包未指定AuthKeyID时使用的KeyID
+func NewClientAuthCodec(codec Codec, keys KeyStore, keyID uint32) *AuthCodec {
+	a := newAuthCodec(codec, keys, authDirToServer, authDirToClient)
+	a.keyID = keyID
+	return a
+}
+
+func newAuthCodec(codec Codec, keys KeyStore, encodeDir, decodeDir authDir) *AuthCodec {
+	a := &AuthCodec{
+		codec:     codec,
+		keys:      keys,
+		encodeDir: encodeDir,
+		decodeDir: decodeDir,
+	}
+	a.lastSweep.Store(time.Now().UnixNano())
+	return a
+}
+
+// Encode 编码包并追加认证信息
+func (a *AuthCodec) Encode(pack *Pack) ([]byte, error) {
+	keyID := pack.AuthKeyID
+	if keyID == 0 {
+		keyID = a.keyID
+	}
+	key, err := a.keys.Key(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := a.codec.Encode(pack)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)+authTrailerLen > MaxUDPSize {
+		return nil, fmt.Errorf("packet size %d exceeds UDP limit %d", len(data)+authTrailerLen, MaxUDPSize)
+	}
+
+	buf := make([]byte, len(data)+authTrailerLen)
+	n := copy(buf, data)
+	binary.BigEndian.PutUint32(buf[n:], keyID)
+	binary.BigEndian.PutUint64(buf[n+authKeyIDLen:], a.nextSeq())
+	copy(buf[len(buf)-authTagLen:], authTag(key, a.encodeDir, buf[:len(buf)-authTagLen]))
+
+	return buf, nil
+}
+
+// nextSeq 下一个序列号，取本机纳秒时间，同一纳秒内多个包时递增
+func (a *AuthCodec) nextSeq() uint64 {
+	now := uint64(time.Now().UnixNano())
+	for {
+		old := a.seq.Load()
+		next := max(old+1, now)
+		if a.seq.CompareAndSwap(old, next) {
+			return next
+		}
+	}
+}
+
+// Decode 校验认证信息并解码包
+func (a *AuthCodec) Decode(data []byte) (*Pack, error) {
+	if len(data) < authTrailerLen {
+		return nil, ErrPackTooSmall
+	}
+
+	n := len(data) - authTrailerLen
+	keyID := binary.BigEndian.Uint32(data[n:])
+	seq := binary.BigEndian.Uint64(data[n+authKeyIDLen:])
+
+	key, err := a.keys.Key(keyID)
+	if err != nil {
+		a.windows.Delete(keyID)
+		return nil, err
+	}
+	if !hmac.Equal(authTag(key, a.decodeDir, data[:len(data)-authTagLen]), data[len(data)-authTagLen:]) {
+		return nil, ErrAuthFailed
+	}
+
+	// 标签校验通过后才更新窗口，避免伪造的包推动窗口
+	if !a.accept(keyID, seq, time.Now().UnixNano()) {
+		return nil, ErrReplay
+	}
+
+	pack, err := a.codec.Decode(data[:n])
+	if err != nil {
+		return nil, err
+	}
+	pack.AuthKeyID = keyID
+	return pack, nil
+}
+
+// accept 使用KeyID的重放窗口检查序列号，没有窗口时要求序列号与当前时间的偏差不超过authMaxSkew
+func (a *AuthCodec) accept(keyID uint32, seq uint64, now int64) bool {
+	a.sweep(now)
+	for {
+		v, ok := a.windows.Load(keyID)
+		if !ok {
+			// 窗口被清理时旧包的序列号已早于now-authMaxSkew，不会通过该检查
+			if int64(seq) < now-int64(authMaxSkew) {
+				return false
+			}
+			v, _ = a.windows.LoadOrStore(keyID, &replayWindow{})
+		}
+		accepted, evicted := v.(*replayWindow).accept(seq, now)
+		if !evicted {
+			return accepted
+		}
+	}
+}
+
+// sweep 每authMaxSkew清理一次空闲超过authWindowTTL的窗口
+func (a *AuthCodec) sweep(now int64) {
+	last := a.lastSweep.Load()
+	if now-last < int64(authMaxSkew) || !a.lastSweep.CompareAndSwap(last, now) {
+		return
+	}
+	expire := now - int64(authWindowTTL)
+	a.windows.Range(func(k, v any) bool {
+		w := v.(*replayWindow)
+		w.mu.Lock()
+		if w.lastSeen < expire {
+			w.evicted = true
+			a.windows.Delete(k)
+		}
+		w.mu.Unlock()
+		return true
+	})
+}
+
+func authTag(key []byte, dir authDir, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte{byte(dir)})
+	mac.Write(data)
+	return mac.Sum(nil)[:authTagLen]
+}
+
+// replayWindow 滑动窗口重放检测，记录最大序列号及其之前replayWindowSize个序列号的接收情况
+type replayWindow struct {
+	mu       sync.Mutex
+	maxSeq   uint64
+	bitmap   uint64
+	lastSeen int64 // 最后接收包的时间(UnixNano)
+	evicted  bool  // 已从AuthCodec清理，需要使用新窗口
+}
+
+// accept 序列号未接收过且未落后窗口时返回true，并标记为已接收，窗口已被清理时evicted为true
+func (w *replayWindow) accept(seq uint64, now int64) (accepted, evicted bool) {
+	if seq == 0 {
+		return false, false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.evicted {
+		return false, true
+	}
+	if seq > w.maxSeq {
+		shift := seq - w.maxSeq
+		if shift >= replayWindowSize {
+			w.bitmap = 1
+		} else {
+			w.bitmap = w.bitmap<<shift | 1
+		}
+		w.maxSeq = seq
+		w.lastSeen = now
+		return true, false
+	}
+
+	diff := w.maxSeq - seq
+	if diff >= replayWindowSize {
+		return false, false
+	}
+	bit := uint64(1) << diff
+	if w.bitmap&bit != 0 {
+		return false, false
+	}
+	w.bitmap |= bit
+	w.lastSeen = now
+	return true, false
+}
@@ -0,0 +1,184 @@
+package udp
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestAuthPack() *Pack {
+	return &Pack{
+		Head: PackHead{
+			SQID:    1,
+			OpCode:  1000,
+			Version: Version1,
+		},
+		Payload: []byte("auth payload"),
+	}
+}
+
+func TestAuthCodec_EncodeDecodeRoundTrip(t *testing.T) {
+	keys := NewSecretKeyStore([]byte("secret"))
+	client := NewClientAuthCodec(NewPackCodec(), keys, 42)
+	server := NewAuthCodec(NewPackCodec(), keys)
+
+	data, err := client.Encode(newTestAuthPack())
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	pack, err := server.Decode(data)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if pack.AuthKeyID != 42 {
+		t.Errorf("AuthKeyID = %d, want 42", pack.AuthKeyID)
+	}
+	if string(pack.Payload) != "auth payload" {
+		t.Errorf("Payload = %s, want auth payload", pack.Payload)
+	}
+
+	// 响应沿用请求的KeyID，客户端可以解码
+	resp, err := server.Encode(&Pack{Head: pack.Head, Payload: []byte("ok"), AuthKeyID: pack.AuthKeyID})
+	if err != nil {
+		t.Fatalf("encode response failed: %v", err)
+	}
+	if _, err = client.Decode(resp); err != nil {
+		t.Fatalf("decode response failed: %v", err)
+	}
+
+	// 服务端的响应被反射回服务端时校验失败
+	resp, err = server.Encode(&Pack{Head: pack.Head, Payload: []byte("ok"), AuthKeyID: pack.AuthKeyID})
+	if err != nil {
+		t.Fatalf("encode response failed: %v", err)
+	}
+	if _, err = server.Decode(resp); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("reflected response error = %v, want %v", err, ErrAuthFailed)
+	}
+}
+
+func TestAuthCodec_Decode(t *testing.T) {
+	client := NewClientAuthCodec(NewPackCodec(), NewSecretKeyStore([]byte("secret")), 7)
+	server := NewAuthCodec(NewPackCodec(), NewSecretKeyStore([]byte("secret")))
+
+	data, err := client.Encode(newTestAuthPack())
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if _, err = server.Decode(data); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	tampered := make([]byte, len(data))
+	copy(tampered, data)
+	tampered[packHeadLen] ^= 0xff
+
+	other := NewClientAuthCodec(NewPackCodec(), NewSecretKeyStore([]byte("other")), 7)
+	forged, err := other.Encode(newTestAuthPack())
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "replayed packet", data: data, wantErr: ErrReplay},
+		{name: "tampered payload", data: tampered, wantErr: ErrAuthFailed},
+		{name: "wrong secret", data: forged, wantErr: ErrAuthFailed},
+		{name: "packet too small", data: []byte{1, 2, 3}, wantErr: ErrPackTooSmall},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := server.Decode(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("AuthCodec.Decode() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthCodec_TokenKeyStore(t *testing.T) {
+	keys := NewTokenKeyStore()
+	token, err := keys.Issue(time.Minute)
+	if err != nil {
+		t.Fatalf("issue token failed: %v", err)
+	}
+
+	client := NewClientAuthCodec(NewPackCodec(), keys, token.KeyID)
+	server := NewAuthCodec(NewPackCodec(), keys)
+
+	data, err := client.Encode(newTestAuthPack())
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	if _, err = server.Decode(data); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	keys.Revoke(token.KeyID)
+	if _, err = client.Encode(newTestAuthPack()); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("encode with revoked token error = %v, want %v", err, ErrUnknownKey)
+	}
+
+	expired, err := keys.Issue(-time.Second)
+	if err != nil {
+		t.Fatalf("issue token failed: %v", err)
+	}
+	if _, err = keys.Key(expired.KeyID); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expired token error = %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestReplayWindow(t *testing.T) {
+	w := &replayWindow{}
+
+	steps := []struct {
+		seq  uint64
+		want bool
+	}{
+		{seq: 0, want: false},
+		{seq: 10, want: true},
+		{seq: 10, want: false},
+		{seq: 8, want: true},
+		{seq: 8, want: false},
+		{seq: 12, want: true},
+		{seq: 9, want: true},
+		{seq: 100, want: true},
+		{seq: 36, want: false},
+		{seq: 37, want: true},
+	}
+
+	for _, s := range steps {
+		if got, _ := w.accept(s.seq, 0); got != s.want {
+			t.Errorf("accept(%d) = %v, want %v", s.seq, got, s.want)
+		}
+	}
+}
+
+func TestAuthCodec_Window(t *testing.T) {
+	server := NewAuthCodec(NewPackCodec(), NewSecretKeyStore([]byte("secret")))
+	now := time.Now().UnixNano()
+
+	// 新KeyID的序列号落后当前时间过多时拒绝
+	if server.accept(1, uint64(now-int64(authMaxSkew)-1), now) {
+		t.Error("stale seq accepted for new key")
+	}
+	if !server.accept(1, uint64(now), now) {
+		t.Fatal("fresh seq rejected")
+	}
+
+	// 空闲窗口被清理，清理后旧序列号不能重新接受
+	later := now + int64(authWindowTTL) + 1
+	if !server.accept(2, uint64(later), later) {
+		t.Fatal("fresh seq rejected")
+	}
+	if _, ok := server.windows.Load(uint32(1)); ok {
+		t.Error("idle window not swept")
+	}
+	if server.accept(1, uint64(now+1), later) {
+		t.Error("old seq accepted after window swept")
+	}
+}
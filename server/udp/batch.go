@@ -0,0 +1,271 @@
+package udp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/ilaziness/gokit/log"
+	"github.com/ilaziness/gokit/process"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// defaultJobQueueSize 固定工作协程池的任务队列长度
+const defaultJobQueueSize = 4096
+
+// bufferPool 读写缓冲区池
+var bufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, defaultBufferSize)
+		return &buf
+	},
+}
+
+func getBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+func putBuffer(buf *[]byte) {
+	bufferPool.Put(buf)
+}
+
+// batchConn 批量收发接口，ipv4.PacketConn和ipv6.PacketConn均实现该接口
+// Linux上使用recvmmsg/sendmmsg，其他平台每次只收发一条消息
+type batchConn interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+func (s *Server) newBatchConn(conn net.PacketConn) batchConn {
+	if s.dstConn != nil {
+		return s.dstConn
+	}
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && addr.IP.To4() != nil {
+		return ipv4.NewPacketConn(conn)
+	}
+	return ipv6.NewPacketConn(conn)
+}
+
+// startWorkers 启动固定工作协程池，FixedWorkers不大于0时每个请求启动一个协程
+func (s *Server) startWorkers(ctx context.Context) {
+	if s.config.FixedWorkers <= 0 {
+		return
+	}
+	s.jobs = make(chan func(), defaultJobQueueSize)
+	for i := 0; i < s.config.FixedWorkers; i++ {
+		process.SafeGo(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-s.jobs:
+					runJob(job)
+				}
+			}
+		})
+	}
+}
+
+func runJob(job func()) {
+	defer process.PanicRecover()
+	job()
+}
+
+// dispatch 分发请求处理任务
+func (s *Server) dispatch(ctx context.Context, job func()) {
+	s.inflight.Add()
+	tracked := func() {
+		defer s.inflight.Done()
+		job()
+	}
+	if s.jobs == nil {
+		process.SafeGo(tracked)
+		return
+	}
+	select {
+	case <-ctx.Done():
+		s.inflight.Done()
+	case s.jobs <- tracked:
+	}
+}
+
+// handleBatchMessages 批量读取UDP数据包
+func (s *Server) handleBatchMessages(ctx context.Context, bc batchConn) {
+	size := s.config.BatchSize
+	msgs := make([]ipv4.Message, size)
+	bufs := make([]*[]byte, size)
+	for i := range msgs {
+		bufs[i] = getBuffer()
+		msgs[i].Buffers = [][]byte{*bufs[i]}
+		if s.dstConn != nil {
+			msgs[i].OOB = ipv4.NewControlMessage(ipv4.FlagDst)
+		}
+	}
+	defer func() {
+		for _, buf := range bufs {
+			putBuffer(buf)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			n, err := bc.ReadBatch(msgs, 0)
+			if err != nil {
+				// Shutdown设置读超时后退出，连接留给处理中的请求写响应
+				if errors.Is(err, net.ErrClosed) || s.draining.Load() {
+					return
+				}
+				log.Warn(ctx, "read UDP batch error: %s", err)
+				continue
+			}
+
+			log.Debug(ctx, "received UDP batch, count: %d", n)
+
+			for i := 0; i < n; i++ {
+				buf, l, addr := bufs[i], msgs[i].N, msgs[i].Addr
+				var dst net.IP
+				if msgs[i].NN > 0 {
+					var cm ipv4.ControlMessage
+					if err = cm.Parse(msgs[i].OOB[:msgs[i].NN]); err == nil {
+						dst = cm.Dst
+					}
+				}
+				s.dispatch(ctx, func() {
+					defer putBuffer(buf)
+					s.handlePacket(ctx, (*buf)[:l], addr, dst)
+				})
+				// 已分发的缓冲区由处理任务归还，换上新的缓冲区继续读取
+				bufs[i] = getBuffer()
+				msgs[i].Buffers[0] = *bufs[i]
+			}
+		}
+	}
+}
+
+// outPacket 待发送的数据包
+type outPacket struct {
+	buf  *[]byte
+	n    int
+	addr net.Addr
+}
+
+// batchPacketConn 将WriteTo写入的数据包汇总后使用WriteBatch批量发送
+type batchPacketConn struct {
+	net.PacketConn
+	bc        batchConn
+	size      int
+	out       chan outPacket
+	done      <-chan struct{}
+	closing   chan struct{} // Close时关闭，发送循环发完队列中的数据包后退出
+	closeOnce sync.Once
+	stopped   chan struct{} // 发送循环退出后关闭
+}
+
+func newBatchPacketConn(ctx context.Context, conn net.PacketConn, bc batchConn, size int) *batchPacketConn {
+	c := &batchPacketConn{
+		PacketConn: conn,
+		bc:         bc,
+		size:       size,
+		out:        make(chan outPacket, size*4),
+		done:       ctx.Done(),
+		closing:    make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	process.SafeGo(func() {
+		c.writeLoop(ctx)
+	})
+	return c
+}
+
+// Close 发送完队列中的数据包后关闭连接
+func (c *batchPacketConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closing)
+	})
+	<-c.stopped
+	return c.PacketConn.Close()
+}
+
+// WriteTo 复制数据包并加入发送队列
+func (c *batchPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if len(p) > defaultBufferSize {
+		return c.PacketConn.WriteTo(p, addr)
+	}
+	buf := getBuffer()
+	n := copy(*buf, p)
+	select {
+	case <-c.done:
+		putBuffer(buf)
+		return 0, net.ErrClosed
+	case <-c.closing:
+		putBuffer(buf)
+		return 0, net.ErrClosed
+	case c.out <- outPacket{buf: buf, n: n, addr: addr}:
+		return n, nil
+	}
+}
+
+func (c *batchPacketConn) writeLoop(ctx context.Context) {
+	defer close(c.stopped)
+	msgs := make([]ipv4.Message, c.size)
+	bufs := make([]*[]byte, c.size)
+	for i := range msgs {
+		msgs[i].Buffers = make([][]byte, 1)
+	}
+
+	for {
+		var k int
+		closing := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.closing:
+			closing = true
+		case p := <-c.out:
+			bufs[k], msgs[k].Buffers[0], msgs[k].Addr = p.buf, (*p.buf)[:p.n], p.addr
+			k++
+		}
+
+		// 取出队列中已有的数据包一起发送，不等待新的数据包
+	drain:
+		for k < c.size {
+			select {
+			case p := <-c.out:
+				bufs[k], msgs[k].Buffers[0], msgs[k].Addr = p.buf, (*p.buf)[:p.n], p.addr
+				k++
+			default:
+				break drain
+			}
+		}
+
+		if closing && k == 0 {
+			return
+		}
+		c.writeBatch(ctx, msgs[:k])
+		for i := 0; i < k; i++ {
+			putBuffer(bufs[i])
+			bufs[i], msgs[i].Buffers[0], msgs[i].Addr = nil, nil, nil
+		}
+	}
+}
+
+func (c *batchPacketConn) writeBatch(ctx context.Context, msgs []ipv4.Message) {
+	for len(msgs) > 0 {
+		n, err := c.bc.WriteBatch(msgs, 0)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Warn(ctx, "write UDP batch error: %s", err)
+			}
+			return
+		}
+		if n == 0 {
+			return
+		}
+		msgs = msgs[n:]
+	}
+}
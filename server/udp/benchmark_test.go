@@ -1,7 +1,14 @@
 package udp
 
 import (
+	"context"
+	"net"
+	"runtime"
 	"testing"
+	"time"
+
+	"github.com/ilaziness/gokit/config"
+	"github.com/ilaziness/gokit/log"
 )
 
 func BenchmarkPackCodec_Encode(b *testing.B) {
@@ -72,3 +79,83 @@ func BenchmarkPackCodec_EncodeDecodeRoundTrip(b *testing.B) {
 		}
 	}
 }
+
+// benchmarkServerEcho 客户端每轮发送一批请求后读取响应，统计服务端的收发吞吐
+func benchmarkServerEcho(b *testing.B, cfg *config.UDPServer) {
+	const window = 32
+	log.SetLevel(log.ModeRelease)
+
+	server := NewUDP(cfg)
+	server.AddHandler(1000, func(ctx *Context) {
+		_ = ctx.Write(ctx.Payload)
+	})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	server.conn = conn
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		_ = conn.Close()
+	}()
+	server.serve(ctx)
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer client.Close()
+
+	data, err := NewPackCodec().Encode(&Pack{
+		Head: PackHead{
+			SQID:    1,
+			OpCode:  1000,
+			Version: Version1,
+		},
+		Payload: make([]byte, 256),
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	buffer := make([]byte, defaultBufferSize)
+	var lost int
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for sent := 0; sent < b.N; sent += window {
+		n := min(window, b.N-sent)
+		for i := 0; i < n; i++ {
+			if _, err = client.Write(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+		for i := 0; i < n; i++ {
+			_ = client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			if _, err = client.Read(buffer); err != nil {
+				// UDP不保证送达，超时视为丢包
+				lost += n - i
+				break
+			}
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(lost)/float64(b.N), "lost/op")
+}
+
+func BenchmarkServer_Echo(b *testing.B) {
+	benchmarkServerEcho(b, &config.UDPServer{
+		Address:   "127.0.0.1:0",
+		WorkerNum: 1000,
+	})
+}
+
+func BenchmarkServer_EchoBatch(b *testing.B) {
+	benchmarkServerEcho(b, &config.UDPServer{
+		Address:      "127.0.0.1:0",
+		WorkerNum:    1000,
+		BatchSize:    32,
+		FixedWorkers: runtime.NumCPU(),
+	})
+}
@@ -1,14 +1,30 @@
 package udp
 
 import (
+	"context"
 	"net"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/ilaziness/gokit/config"
+	"github.com/ilaziness/gokit/log"
 )
 
+// TestMain 日志写入临时目录，不在包目录下生成日志文件
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gokit-log")
+	if err != nil {
+		panic(err)
+	}
+	log.SetFile(filepath.Join(dir, "app.log"))
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestUDPServerIntegration(t *testing.T) {
 	// 配置服务器
 	cfg := &config.UDPServer{
@@ -155,3 +171,63 @@ func (m *mockPacketConn) SetReadDeadline(t time.Time) error {
 func (m *mockPacketConn) SetWriteDeadline(t time.Time) error {
 	return nil
 }
+
+func TestServer_ShutdownWaitsInflight(t *testing.T) {
+	for _, batch := range []int{0, 8} {
+		server := NewUDP(&config.UDPServer{Address: "127.0.0.1:0", WorkerNum: 10, BatchSize: batch})
+		received := make(chan struct{})
+		server.AddHandler(1000, func(ctx *Context) {
+			close(received)
+			time.Sleep(100 * time.Millisecond)
+			_ = ctx.Write([]byte("done"))
+		})
+		served := make(chan error, 1)
+		go func() { served <- server.Serve() }()
+
+		var addr net.Addr
+		for i := 0; i < 100 && addr == nil; i++ {
+			time.Sleep(10 * time.Millisecond)
+			server.mu.Lock()
+			if server.conn != nil {
+				addr = server.conn.LocalAddr()
+			}
+			server.mu.Unlock()
+		}
+		if addr == nil {
+			t.Fatal("server not started")
+		}
+
+		client, err := net.Dial("udp", addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := NewPackCodec().Encode(&Pack{Head: PackHead{SQID: 1, OpCode: 1000, Version: Version1}})
+		if _, err = client.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		<-received
+
+		// 处理中的请求在Shutdown期间仍能写出响应
+		if err = server.Shutdown(context.Background()); err != nil {
+			t.Errorf("batch %d: Shutdown() error: %v", batch, err)
+		}
+		_ = client.SetReadDeadline(time.Now().Add(time.Second))
+		buf := make([]byte, 1024)
+		n, err := client.Read(buf)
+		if err != nil {
+			t.Errorf("batch %d: read response error: %v", batch, err)
+		} else if pack, _ := NewPackCodec().Decode(buf[:n]); pack == nil || string(pack.Payload) != "done" {
+			t.Errorf("batch %d: response = %v", batch, pack)
+		}
+		_ = client.Close()
+
+		select {
+		case err = <-served:
+			if err != nil {
+				t.Errorf("batch %d: Serve() error: %v", batch, err)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("batch %d: Serve() not returned after Shutdown", batch)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package udp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv4"
+)
+
+var (
+	ErrNoMulticastGroup = errors.New("no multicast group joined")
+	ErrNotListening     = errors.New("udp server is not listening")
+)
+
+// dstEnabled 是否需要识别数据包的目的地址，配置了组播组或开启广播时使用IPv4监听并读取目的地址
+func (s *Server) dstEnabled() bool {
+	return len(s.config.MulticastGroups) > 0 || s.config.Broadcast
+}
+
+// setupMulticast 加入组播组并设置组播参数，同时开启目的地址控制消息用于区分组播、广播和单播
+func (s *Server) setupMulticast() error {
+	pc := ipv4.NewPacketConn(s.conn)
+
+	var ifi *net.Interface
+	if s.config.MulticastInterface != "" {
+		var err error
+		ifi, err = net.InterfaceByName(s.config.MulticastInterface)
+		if err != nil {
+			return err
+		}
+		if err = pc.SetMulticastInterface(ifi); err != nil {
+			return err
+		}
+	}
+
+	for _, g := range s.config.MulticastGroups {
+		ip := net.ParseIP(g)
+		if ip == nil || ip.To4() == nil || !ip.IsMulticast() {
+			return fmt.Errorf("invalid IPv4 multicast group: %s", g)
+		}
+		if err := pc.JoinGroup(ifi, &net.UDPAddr{IP: ip}); err != nil {
+			return err
+		}
+		s.groups = append(s.groups, ip)
+	}
+
+	if s.config.MulticastTTL > 0 {
+		if err := pc.SetMulticastTTL(s.config.MulticastTTL); err != nil {
+			return err
+		}
+	}
+	if err := pc.SetMulticastLoopback(s.config.MulticastLoopback); err != nil {
+		return err
+	}
+	if err := pc.SetControlMessage(ipv4.FlagDst, true); err != nil {
+		return err
+	}
+
+	s.dstConn = pc
+	s.broadcastIPs = localBroadcastIPs()
+	return nil
+}
+
+// localBroadcastIPs 获取本机各IPv4网段的广播地址
+func localBroadcastIPs() []net.IP {
+	ips := []net.IP{net.IPv4bcast}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ips
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipNet.IP.To4()
+		if ip == nil || len(ipNet.Mask) != net.IPv4len {
+			continue
+		}
+		bcast := make(net.IP, net.IPv4len)
+		for i := range ip {
+			bcast[i] = ip[i] | ^ipNet.Mask[i]
+		}
+		ips = append(ips, bcast)
+	}
+	return ips
+}
+
+// isBroadcast 目的地址是否为广播地址
+func (s *Server) isBroadcast(dst net.IP) bool {
+	for _, ip := range s.broadcastIPs {
+		if ip.Equal(dst) {
+			return true
+		}
+	}
+	return false
+}
+
+// Multicast 向所有已加入的组播组发送数据包，目的端口与服务端口相同，DTLS模式或未监听时返回ErrNotListening
+func (s *Server) Multicast(opcode OpCode, payload []byte) error {
+	s.mu.Lock()
+	conn, groups, isDTLS := s.conn, s.groups, s.isDTLS
+	s.mu.Unlock()
+	if len(groups) == 0 {
+		return ErrNoMulticastGroup
+	}
+	if isDTLS || conn == nil {
+		return ErrNotListening
+	}
+	data, err := s.encodePush(opcode, payload)
+	if err != nil {
+		return err
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	for _, g := range groups {
+		if _, err = conn.WriteTo(data, &net.UDPAddr{IP: g, Port: port}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Broadcast 向受限广播地址发送数据包，目的端口与服务端口相同，DTLS模式或未监听时返回ErrNotListening
+func (s *Server) Broadcast(opcode OpCode, payload []byte) error {
+	s.mu.Lock()
+	conn, isDTLS := s.conn, s.isDTLS
+	s.mu.Unlock()
+	if isDTLS || conn == nil {
+		return ErrNotListening
+	}
+	data, err := s.encodePush(opcode, payload)
+	if err != nil {
+		return err
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	_, err = conn.WriteTo(data, &net.UDPAddr{IP: net.IPv4bcast, Port: port})
+	return err
+}
+
+// encodePush 编码服务端主动发送的数据包
+func (s *Server) encodePush(opcode OpCode, payload []byte) ([]byte, error) {
+	return s.packCodec.Encode(&Pack{
+		Head: PackHead{
+			OpCode:  uint16(opcode),
+			Version: Version1,
+		},
+		Payload: payload,
+	})
+}
@@ -0,0 +1,148 @@
+package udp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ilaziness/gokit/config"
+)
+
+func TestServer_SetupMulticastInvalidGroup(t *testing.T) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tests := []string{"not-an-ip", "10.0.0.1", "ff02::1"}
+	for _, group := range tests {
+		t.Run(group, func(t *testing.T) {
+			server := NewUDP(&config.UDPServer{MulticastGroups: []string{group}})
+			server.conn = conn
+			if err := server.setupMulticast(); err == nil {
+				t.Errorf("setupMulticast(%s) expected error", group)
+			}
+		})
+	}
+}
+
+func TestServer_MulticastWithoutGroup(t *testing.T) {
+	server := NewUDP(&config.UDPServer{})
+	if err := server.Multicast(1000, []byte("hello")); !errors.Is(err, ErrNoMulticastGroup) {
+		t.Errorf("Multicast() error = %v, want %v", err, ErrNoMulticastGroup)
+	}
+}
+
+func TestServer_BroadcastNotListening(t *testing.T) {
+	// 未监听或使用DTLS时没有普通UDP连接
+	for _, isDTLS := range []bool{false, true} {
+		server := NewUDP(&config.UDPServer{})
+		server.isDTLS = isDTLS
+		server.groups = []net.IP{net.ParseIP("239.255.10.1")}
+		if err := server.Broadcast(1000, []byte("hello")); !errors.Is(err, ErrNotListening) {
+			t.Errorf("dtls %v: Broadcast() error = %v, want %v", isDTLS, err, ErrNotListening)
+		}
+		if err := server.Multicast(1000, []byte("hello")); !errors.Is(err, ErrNotListening) {
+			t.Errorf("dtls %v: Multicast() error = %v, want %v", isDTLS, err, ErrNotListening)
+		}
+	}
+}
+
+func TestServer_IsBroadcast(t *testing.T) {
+	server := NewUDP(&config.UDPServer{})
+	server.broadcastIPs = localBroadcastIPs()
+
+	if !server.isBroadcast(net.IPv4bcast) {
+		t.Error("255.255.255.255 should be broadcast")
+	}
+	if server.isBroadcast(net.ParseIP("127.0.0.1")) {
+		t.Error("127.0.0.1 should not be broadcast")
+	}
+}
+
+// multicastInterface 查找支持组播的网卡
+func multicastInterface() *net.Interface {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for _, ifi := range ifis {
+		if ifi.Flags&net.FlagUp != 0 && ifi.Flags&net.FlagMulticast != 0 {
+			return &ifi
+		}
+	}
+	return nil
+}
+
+func TestServer_MulticastLoopback(t *testing.T) {
+	ifi := multicastInterface()
+	if ifi == nil {
+		t.Skip("no interface supports multicast")
+	}
+
+	cfg := &config.UDPServer{
+		MulticastGroups:    []string{"239.255.10.1"},
+		MulticastInterface: ifi.Name,
+		MulticastLoopback:  true,
+	}
+	server := NewUDP(cfg)
+	received := make(chan bool, 2)
+	server.AddHandler(1000, func(ctx *Context) {
+		received <- ctx.IsMulticast()
+	})
+
+	var err error
+	server.conn, err = net.ListenPacket("udp4", "0.0.0.0:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = server.setupMulticast(); err != nil {
+		_ = server.conn.Close()
+		t.Skipf("join multicast group failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		_ = server.conn.Close()
+	}()
+	server.serve(ctx)
+
+	if err = server.Multicast(1000, []byte("hello")); err != nil {
+		t.Skipf("send multicast failed: %v", err)
+	}
+
+	select {
+	case isMulticast := <-received:
+		if !isMulticast {
+			t.Error("expected packet to be marked as multicast")
+		}
+	case <-time.After(2 * time.Second):
+		t.Skip("multicast packet not delivered on loopback")
+	}
+
+	// 单播数据包不标记为组播
+	port := server.conn.LocalAddr().(*net.UDPAddr).Port
+	client, err := net.Dial("udp4", (&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	data, err := NewPackCodec().Encode(&Pack{Head: PackHead{SQID: 1, OpCode: 1000, Version: Version1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case isMulticast := <-received:
+		if isMulticast {
+			t.Error("unicast packet marked as multicast")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("unicast packet not received")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 )
 
+// Codec 包编解码器，Decode返回的Pack不能引用传入的data，data在解码后会被复用
 type Codec interface {
 	Decode([]byte) (*Pack, error)
 	Encode(*Pack) ([]byte, error)
@@ -31,6 +32,8 @@ const (
 type Pack struct {
 	Head    PackHead
 	Payload []byte
+	// AuthKeyID 认证密钥ID，由AuthCodec解码时填充，响应时沿用
+	AuthKeyID uint32
 }
 
 // PackHead 包头，固定长度packHeadLen
@@ -11,6 +11,7 @@ import (
 	"os/signal"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -19,11 +20,13 @@ import (
 	"github.com/ilaziness/gokit/log"
 	"github.com/ilaziness/gokit/process"
 	"github.com/pion/dtls/v3"
+	"golang.org/x/net/ipv4"
 )
 
 const (
-	defaultWorkerNum  = 100000
-	defaultBufferSize = 65536
+	defaultWorkerNum       = 100000
+	defaultBufferSize      = 65536
+	defaultShutdownTimeout = 5 * time.Second
 )
 
 type (
@@ -36,6 +39,7 @@ type (
 type Server struct {
 	config       *config.UDPServer
 	workerSem    chan struct{} // 控制同时执行的请求数
+	jobs         chan func()   // 固定工作协程池的任务队列
 	handlers     map[OpCode]Handler
 	middlewares  []Handler
 	ctxPool      sync.Pool
@@ -44,6 +48,15 @@ type Server struct {
 	dtlsListener net.Listener
 	dtlsConfig   *dtls.Config
 	isDTLS       bool
+	dstConn      *ipv4.PacketConn // 读取目的地址控制消息的连接，用于区分组播、广播和单播
+	groups       []net.IP         // 已加入的组播组
+	broadcastIPs []net.IP         // 本机广播地址
+
+	mu       sync.Mutex // 保护conn、dtlsListener、closing、stop
+	closing  bool
+	draining atomic.Bool        // Shutdown后读循环退出，不再接收新数据包
+	stop     context.CancelFunc // 结束Serve
+	inflight process.Tracker    // 处理中的请求
 }
 
 // NewUDP 创建一个UDP服务，不含任何中间件
@@ -63,11 +76,15 @@ func newBaseUDP(config *config.UDPServer) *Server {
 	if config.WorkerNum > 0 {
 		workerNum = config.WorkerNum
 	}
+	var packCodec Codec = NewPackCodec()
+	if config.AuthSecret != "" {
+		packCodec = NewAuthCodec(packCodec, NewSecretKeyStore([]byte(config.AuthSecret)))
+	}
 	return &Server{
 		config:      config,
 		workerSem:   make(chan struct{}, workerNum),
 		handlers:    make(map[OpCode]Handler),
-		packCodec:   NewPackCodec(),
+		packCodec:   packCodec,
 		middlewares: []Handler{},
 		ctxPool: sync.Pool{
 			New: func() any {
@@ -90,11 +107,44 @@ func (s *Server) AddMiddleware(ms ...Handler) {
 	s.middlewares = append(s.middlewares, ms...)
 }
 
+// SetCodec 设置包编解码器，如使用AuthCodec包装PackCodec启用包认证
+func (s *Server) SetCodec(c Codec) {
+	s.packCodec = c
+}
+
 func (s *Server) setDefaultMiddleware() {
 	s.AddMiddleware(Ping)
 }
 
+// Start 启动服务并阻塞直到收到退出信号，同时运行多个服务时使用server.App
 func (s *Server) Start() {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Serve()
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errc:
+		if err != nil {
+			panic(err)
+		}
+	case <-quit:
+	}
+
+	log.Logger.Infoln("Shutdown Server ...")
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		log.Warn(ctx, "shutdown error: %s", err)
+	}
+	hook.Exit.Trigger()
+	log.Logger.Infoln("Server Shutdown")
+}
+
+// Serve 监听并处理数据包，阻塞直到Shutdown，监听失败时返回错误
+func (s *Server) Serve() error {
 	if s.config.Debug {
 		log.SetLevel(log.ModeDebug)
 	} else {
@@ -103,6 +153,14 @@ func (s *Server) Start() {
 
 	var err error
 	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	s.mu.Lock()
+	if s.closing {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stop = stop
 
 	// 创建DTLS配置
 	s.dtlsConfig = s.createDTLSConfig()
@@ -112,8 +170,10 @@ func (s *Server) Start() {
 		s.isDTLS = true
 		s.dtlsListener, err = s.createDTLSListener()
 		if err != nil {
-			panic(err)
+			s.mu.Unlock()
+			return err
 		}
+		s.mu.Unlock()
 		log.Info(ctx, "start DTLS UDP server")
 
 		// 启动DTLS连接处理协程
@@ -124,39 +184,66 @@ func (s *Server) Start() {
 		log.Info(ctx, "DTLS UDP server start at: %s", s.dtlsListener.Addr())
 	} else {
 		// 创建普通UDP连接
-		s.conn, err = net.ListenPacket("udp", s.config.Address)
+		network := "udp"
+		if s.dstEnabled() {
+			// 组播和广播仅支持IPv4
+			network = "udp4"
+		}
+		s.conn, err = net.ListenPacket(network, s.config.Address)
 		if err != nil {
-			panic(err)
+			s.mu.Unlock()
+			return err
 		}
-		log.Info(ctx, "start UDP server")
-
-		// 启动消息处理协程
-		process.SafeGo(func() {
-			s.handleMessages(ctx)
-		})
+		if s.dstEnabled() {
+			if err = s.setupMulticast(); err != nil {
+				_ = s.conn.Close()
+				s.mu.Unlock()
+				return err
+			}
+		}
+		// serve会替换批量模式下的s.conn，与Shutdown互斥
+		s.serve(ctx)
+		addr := s.conn.LocalAddr()
+		s.mu.Unlock()
 
-		log.Info(ctx, "UDP server start at: %s", s.conn.LocalAddr())
+		log.Info(ctx, "UDP server start at: %s", addr)
 	}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	stop()
-	hook.Exit.Trigger()
+	<-ctx.Done()
+	return nil
+}
 
-	if s.isDTLS {
+// Shutdown 停止接收新数据包，等待处理中的请求完成后关闭连接，ctx结束时直接关闭
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	var err error
+	if s.isDTLS && s.dtlsListener != nil {
+		// 已建立的DTLS连接不受影响，处理中的请求仍可写响应
 		if err = s.dtlsListener.Close(); err != nil {
 			log.Warn(ctx, "close DTLS listener error: %s", err)
 		}
-	} else {
-		if err = s.conn.Close(); err != nil {
-			log.Warn(ctx, "close UDP connection error: %s", err)
+	} else if s.conn != nil {
+		// 普通UDP的读写共用一个连接，先让读循环退出，连接留给处理中的请求写响应
+		s.draining.Store(true)
+		_ = s.conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	// 处理中的请求可能还需要写响应，等待结束后再关闭连接、取消ctx，ctx取消后工作协程退出
+	err = errors.Join(err, s.inflight.Wait(ctx))
+	s.mu.Lock()
+	if !s.isDTLS && s.conn != nil {
+		if cerr := s.conn.Close(); cerr != nil && !errors.Is(cerr, net.ErrClosed) {
+			log.Warn(ctx, "close UDP connection error: %s", cerr)
+			err = errors.Join(err, cerr)
 		}
 	}
-
-	log.Logger.Infoln("Shutdown Server ...")
-	time.Sleep(time.Second * 2)
-	log.Logger.Infoln("Server Shutdown")
+	if s.stop != nil {
+		s.stop()
+	}
+	s.mu.Unlock()
+	return err
 }
 
 // createDTLSConfig 创建DTLS配置
@@ -260,7 +347,9 @@ func (s *Server) handleDTLSConnection(ctx context.Context, conn net.Conn) {
 			copy(data, buffer[:n])
 
 			// 异步处理消息
+			s.inflight.Add()
 			process.SafeGo(func() {
+				defer s.inflight.Done()
 				s.handleDTLSPacket(ctx, data, conn)
 			})
 		}
@@ -305,43 +394,76 @@ func (s *Server) handleDTLSPacket(ctx context.Context, data []byte, conn net.Con
 	log.Debug(ctx, "processed DTLS packet: %s", pack.Payload)
 }
 
-func (s *Server) handleMessages(ctx context.Context) {
-	buffer := make([]byte, defaultBufferSize)
+// serve 启动普通UDP连接的工作协程和消息处理协程
+func (s *Server) serve(ctx context.Context) {
+	s.startWorkers(ctx)
+
+	if s.config.BatchSize > 1 {
+		bc := s.newBatchConn(s.conn)
+		s.conn = newBatchPacketConn(ctx, s.conn, bc, s.config.BatchSize)
+		process.SafeGo(func() {
+			s.handleBatchMessages(ctx, bc)
+		})
+		return
+	}
+
+	process.SafeGo(func() {
+		s.handleMessages(ctx)
+	})
+}
 
+func (s *Server) handleMessages(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			// 设置读取超时
+			// 设置读取超时，Shutdown先设置draining再设置读超时，设置后再检查draining，避免覆盖Shutdown的读超时
 			_ = s.conn.SetDeadline(time.Now().Add(300 * time.Second))
+			if s.draining.Load() {
+				return
+			}
 
-			n, addr, err := s.conn.ReadFrom(buffer)
+			buf := getBuffer()
+			n, addr, dst, err := s.readFrom(*buf)
 			if err != nil {
-				// 连接关闭或超时错误不打印日志，直接返回或继续
-				if errors.Is(err, io.EOF) ||
-					strings.Contains(err.Error(), "timeout") {
+				putBuffer(buf)
+				// 连接关闭或超时错误不打印日志，Shutdown后返回，空闲超时继续读取
+				if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) || s.draining.Load() {
 					return
 				}
+				if strings.Contains(err.Error(), "timeout") {
+					continue
+				}
 				log.Warn(ctx, "read UDP packet error: %s", err)
 				continue
 			}
 
 			log.Debug(ctx, "received UDP packet from: %s, size: %d", addr, n)
 
-			// 复制数据以避免并发问题
-			data := make([]byte, n)
-			copy(data, buffer[:n])
-
-			// 异步处理消息
-			process.SafeGo(func() {
-				s.handlePacket(ctx, data, addr)
+			// 异步处理消息，处理完成后归还缓冲区
+			s.dispatch(ctx, func() {
+				defer putBuffer(buf)
+				s.handlePacket(ctx, (*buf)[:n], addr, dst)
 			})
 		}
 	}
 }
 
-func (s *Server) handlePacket(ctx context.Context, data []byte, addr net.Addr) {
+// readFrom 读取数据包，开启目的地址识别时同时返回数据包的目的地址
+func (s *Server) readFrom(b []byte) (int, net.Addr, net.IP, error) {
+	if s.dstConn == nil {
+		n, addr, err := s.conn.ReadFrom(b)
+		return n, addr, nil, err
+	}
+	n, cm, addr, err := s.dstConn.ReadFrom(b)
+	if cm == nil {
+		return n, addr, nil, err
+	}
+	return n, addr, cm.Dst, err
+}
+
+func (s *Server) handlePacket(ctx context.Context, data []byte, addr net.Addr, dst net.IP) {
 	s.workerSem <- struct{}{}
 	defer func() {
 		<-s.workerSem
@@ -359,6 +481,11 @@ func (s *Server) handlePacket(ctx context.Context, data []byte, addr net.Addr) {
 	defer s.ctxPool.Put(ctxObj)
 
 	ctxObj.Reset(s.conn, addr, s.packCodec)
+	if dst != nil {
+		ctxObj.dst = dst
+		ctxObj.isMulticast = dst.IsMulticast()
+		ctxObj.isBroadcast = s.isBroadcast(dst)
+	}
 	ctxObj.SetData(pack)
 
 	// 设置中间件和处理器
@@ -392,6 +519,10 @@ type Context struct {
 	OpCode    OpCode
 	Payload   []byte
 	isDTLS    bool
+	// 数据包目的地址，仅在配置组播组或开启广播时可用
+	dst         net.IP
+	isMulticast bool
+	isBroadcast bool
 }
 
 func (c *Context) Reset(conn net.PacketConn, addr net.Addr, pc Codec) {
@@ -403,6 +534,9 @@ func (c *Context) Reset(conn net.PacketConn, addr net.Addr, pc Codec) {
 	c.addr = addr
 	c.packCodec = pc
 	c.isDTLS = false
+	c.dst = nil
+	c.isMulticast = false
+	c.isBroadcast = false
 }
 
 // ResetForDTLS 为DTLS连接重置上下文
@@ -415,6 +549,9 @@ func (c *Context) ResetForDTLS(conn net.Conn, pc Codec) {
 	c.addr = conn.RemoteAddr()
 	c.packCodec = pc
 	c.isDTLS = true
+	c.dst = nil
+	c.isMulticast = false
+	c.isBroadcast = false
 }
 
 // Next 运行中间件
@@ -445,7 +582,8 @@ func (c *Context) Write(data []byte) error {
 			SQID:    c.SQID,
 			Version: c.Pack.Head.Version,
 		},
-		Payload: data,
+		Payload:   data,
+		AuthKeyID: c.Pack.AuthKeyID,
 	}
 	return c.sendPacket(pack)
 }
@@ -458,7 +596,8 @@ func (c *Context) WriteWithOpCode(opcode OpCode, data []byte) error {
 			SQID:    c.SQID,
 			Version: c.Pack.Head.Version,
 		},
-		Payload: data,
+		Payload:   data,
+		AuthKeyID: c.Pack.AuthKeyID,
 	}
 	return c.sendPacket(pack)
 }
@@ -483,6 +622,21 @@ func (c *Context) GetRemoteAddr() net.Addr {
 	return c.addr
 }
 
+// GetDstIP 获取数据包的目的地址，未配置组播组或广播时返回nil
+func (c *Context) GetDstIP() net.IP {
+	return c.dst
+}
+
+// IsMulticast 是否为组播数据包
+func (c *Context) IsMulticast() bool {
+	return c.isMulticast
+}
+
+// IsBroadcast 是否为广播数据包
+func (c *Context) IsBroadcast() bool {
+	return c.isBroadcast
+}
+
 func (c *Context) sendPacket(pack *Pack) error {
 	data, err := c.packCodec.Encode(pack)
 	if err != nil {
@@ -3,26 +3,56 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/ilaziness/gokit/base/reqres"
 	"github.com/ilaziness/gokit/config"
+	"github.com/ilaziness/gokit/health"
 	"github.com/ilaziness/gokit/hook"
 	"github.com/ilaziness/gokit/log"
 	"github.com/ilaziness/gokit/middleware"
+	"github.com/ilaziness/gokit/middleware/ratelimit"
+	"github.com/ilaziness/gokit/middleware/session"
+	gnet "github.com/ilaziness/gokit/net"
 	"github.com/ilaziness/gokit/timer"
+	"github.com/quic-go/quic-go/http3"
 )
 
 type WebApp struct {
-	Gin    *gin.Engine
-	config *config.App
+	Gin *gin.Engine
+	// Health 健康检查，使用Health.Register添加检查项
+	Health *health.Health
+	// Metrics HTTP请求指标，配置metrics时创建，使用Metrics.Registry()注册业务指标
+	Metrics *middleware.Metrics
+	// Docs 通过Router()注册的路由的OpenAPI文档，配置openapi时在/openapi.json提供
+	Docs *reqres.Docs
+	// Session 会话管理，配置session_secret时创建，处理函数中使用session.Get(c)获取会话
+	Session *session.Manager
+	config  *config.App
+
+	mu      sync.Mutex // 保护srv、h3srv、h3conn、redirectSrv、cert、closing
+	closing bool       // Shutdown后不再监听
+	srv     *http.Server
+	h3srv   *http3.Server
+	h3conn  net.PacketConn
+	// 配置http_redirect_port时的HTTP重定向服务
+	redirectSrv *http.Server
+	cert        *gnet.CertReloader
+
+	errc     chan error    // 后台服务异常退出的错误
+	done     chan struct{} // Shutdown时关闭
+	doneOnce sync.Once
 }
 
 func init() {
@@ -30,15 +60,30 @@ func init() {
 }
 
 // NewWeb 创建一个web app
+//
+// 不兼容变更：只信任config.App.TrustedProxies中的代理，未配置时ClientIP为连接的远端地址，
+// 部署在ingress或负载均衡之后时需要配置其地址，配置0.0.0.0/0和::/0恢复之前信任全部代理的行为
 func NewWeb(appCfg *config.App) *WebApp {
 	if appCfg.Mode == gin.ReleaseMode {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	a := &WebApp{
 		Gin:    NewGin(),
+		Health: health.NewHealth(),
+		Docs:   reqres.NewDocs(appCfg.Name, "1.0.0"),
 		config: appCfg,
+		errc:   make(chan error, 3),
+		done:   make(chan struct{}),
+	}
+	// nil表示不信任任何代理，X-Forwarded-For可以伪造，不能用于限流和日志
+	if err := a.Gin.SetTrustedProxies(appCfg.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("set trusted proxies error: %s", err))
 	}
 	a.setDefaultMiddleware()
+	a.initHealth()
+	a.initMetrics()
+	a.initOpenAPI()
+	a.initPprof()
 	return a
 }
 
@@ -50,36 +95,279 @@ func NewGin() *gin.Engine {
 	return e
 }
 
-// Run 运行应用
+// Run 运行应用，阻塞直到收到退出信号，与TCP、UDP、QUIC服务一起运行时使用App
 func (a *WebApp) Run() {
-	a.starup()
-	srv := http.Server{
-		Addr:    fmt.Sprintf(":%d", a.config.Port),
-		Handler: a.Gin,
+	if err := NewApp().Add(a.config.Name, a).Run(); err != nil {
+		log.Logger.Fatal("Server error:", err)
+	}
+}
+
+// Serve 监听config.Port并提供服务，阻塞直到Shutdown，监听失败或服务异常退出时返回错误
+func (a *WebApp) Serve() error {
+	select {
+	case <-a.done:
+		return nil
+	default:
+	}
+	if err := a.listen(fmt.Sprintf(":%d", a.config.Port)); err != nil {
+		return err
+	}
+	select {
+	case err := <-a.errc:
+		return err
+	case <-a.done:
+		return nil
+	}
+}
+
+// fail 后台服务异常退出，Serve返回该错误
+func (a *WebApp) fail(err error) {
+	select {
+	case a.errc <- err:
+	default:
+	}
+}
+
+// listen 监听addr并在后台提供服务，配置证书时使用HTTPS(HTTP/1.1和HTTP/2)
+// 开启HTTP3时在相同端口的UDP上提供HTTP/3，并在TCP响应中通告Alt-Svc头，Shutdown后调用时不监听
+func (a *WebApp) listen(addr string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closing {
+		return nil
+	}
+
+	tlsConfig, err := a.tlsConfig()
+	if err != nil {
+		return err
+	}
+	if tlsConfig == nil && (a.config.HTTP3 || a.config.HTTPRedirectPort > 0) {
+		return errors.New("http3 and http_redirect_port require cert_file and key_file")
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		a.closeCert()
+		return err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	handler := http.Handler(a.Gin)
+	if tlsConfig != nil && a.config.HSTSMaxAge > 0 {
+		handler = a.hsts(handler)
+	}
+	srv := &http.Server{
+		Addr:      ln.Addr().String(),
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+	}
+
+	var h3srv *http3.Server
+	var h3conn net.PacketConn
+	if a.config.HTTP3 {
+		// 端口为0时UDP使用TCP实际监听的端口
+		h3conn, err = net.ListenPacket("udp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			_ = ln.Close()
+			a.closeCert()
+			return err
+		}
+		h3srv = &http3.Server{
+			Handler:   handler,
+			TLSConfig: http3.ConfigureTLSConfig(tlsConfig),
+			Port:      port,
+		}
+		srv.Handler = altSvc(h3srv, handler)
+	}
+
+	if a.config.HTTPRedirectPort > 0 {
+		redirectAddr := net.JoinHostPort(host, strconv.Itoa(int(a.config.HTTPRedirectPort)))
+		if a.redirectSrv, err = a.listenRedirect(redirectAddr, port); err != nil {
+			_ = ln.Close()
+			if h3conn != nil {
+				_ = h3conn.Close()
+			}
+			a.closeCert()
+			return err
+		}
+	}
+
+	// 全部监听成功后再启动服务，Shutdown持有mu时看到的是完整的服务列表
+	a.srv, a.h3srv, a.h3conn = srv, h3srv, h3conn
+	if h3srv != nil {
+		go func() {
+			log.Logger.Infof("app [%s] HTTP/3 started on %s", a.config.Name, h3conn.LocalAddr())
+			err := h3srv.Serve(h3conn)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				a.fail(fmt.Errorf("HTTP/3 server error: %w", err))
+			}
+		}()
 	}
 	go func() {
-		log.Logger.Infof("app [%s] started on %s", a.config.Name, srv.Addr)
-		err := srv.ListenAndServe()
+		log.Logger.Infof("app [%s] started on %s", a.config.Name, ln.Addr())
+		var err error
+		if tlsConfig != nil {
+			err = srv.ServeTLS(ln, "", "")
+		} else {
+			err = srv.Serve(ln)
+		}
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Logger.Fatal("Start Server error:", err)
+			a.fail(fmt.Errorf("HTTP server error: %w", err))
 		}
 	}()
+	return nil
+}
+
+// listenRedirect 在addr监听HTTP，将请求重定向到httpsPort端口的HTTPS
+func (a *WebApp) listenRedirect(addr string, httpsPort int) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{
+		Addr: ln.Addr().String(),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(r.Host); err == nil {
+				host = h
+			}
+			if httpsPort != 443 {
+				host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+		}),
+	}
+	go func() {
+		log.Logger.Infof("app [%s] HTTP redirect started on %s", a.config.Name, ln.Addr())
+		err := srv.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.fail(fmt.Errorf("HTTP redirect server error: %w", err))
+		}
+	}()
+	return srv, nil
+}
+
+// tlsConfig 根据配置创建TLS配置，未配置证书时返回nil，证书文件变化后自动重新加载
+func (a *WebApp) tlsConfig() (*tls.Config, error) {
+	if a.config.CertFile == "" || a.config.KeyFile == "" {
+		return nil, nil
+	}
+	minVersion, err := tlsVersion(a.config.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion: minVersion,
+	}
+	if a.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(a.config.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client CA error: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", a.config.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	a.cert, err = gnet.NewCertReloader(a.config.CertFile, a.config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS certificate error: %w", err)
+	}
+	tlsConfig.GetCertificate = a.cert.GetCertificate
+	return tlsConfig, nil
+}
+
+// tlsVersion 解析TLS版本，空字符串为TLS 1.2
+func tlsVersion(v string) (uint16, error) {
+	switch v {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported tls_min_version %q", v)
+	}
+}
+
+// altSvc 在响应头中通告HTTP/3服务
+func altSvc(h3srv *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 监听器未就绪时不通告
+		_ = h3srv.SetQUICHeaders(w.Header())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hsts 在HTTPS响应头中要求浏览器只使用HTTPS访问
+func (a *WebApp) hsts(next http.Handler) http.Handler {
+	value := "max-age=" + strconv.Itoa(a.config.HSTSMaxAge)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", value)
+		next.ServeHTTP(w, r)
+	})
+}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	log.Logger.Infoln("Shutdown Server ...")
+// Shutdown 优雅关闭HTTP、HTTP/3和重定向服务，共用ctx的截止时间
+func (a *WebApp) Shutdown(ctx context.Context) error {
+	a.doneOnce.Do(func() {
+		close(a.done)
+	})
+	// 先让就绪检查失败，负载均衡摘除实例后不再转发新请求
+	a.Health.Shutdown()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Logger.Fatal("Server Shutdown error:", err)
+	a.mu.Lock()
+	a.closing = true
+	servers := []interface{ Shutdown(context.Context) error }{}
+	if a.srv != nil {
+		servers = append(servers, a.srv)
+	}
+	if a.h3srv != nil {
+		servers = append(servers, a.h3srv)
+	}
+	if a.redirectSrv != nil {
+		servers = append(servers, a.redirectSrv)
+	}
+	h3conn := a.h3conn
+	a.mu.Unlock()
+
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func() {
+			errs <- srv.Shutdown(ctx)
+		}()
+	}
+	var err error
+	for range servers {
+		err = errors.Join(err, <-errs)
+	}
+	if h3conn != nil {
+		// http3.Server不关闭传入的UDP连接
+		err = errors.Join(err, h3conn.Close())
+	}
+	a.mu.Lock()
+	a.closeCert()
+	a.mu.Unlock()
+	return err
+}
+
+// closeCert 停止监听证书文件，调用方需持有mu
+func (a *WebApp) closeCert() {
+	if a.cert != nil {
+		_ = a.cert.Close()
+		a.cert = nil
 	}
-	a.destroy()
-	log.Logger.Info("Server exiting")
 }
 
 func (a *WebApp) setDefaultMiddleware() {
+	if a.config.Metrics {
+		// 在recovery之前，panic的请求按500记录
+		a.Metrics = middleware.NewMetrics()
+		a.Gin.Use(a.Metrics.Middleware())
+	}
 	a.Gin.Use(gin.CustomRecoveryWithWriter(nil, middleware.RecoveryHandle), middleware.Otel(a.config.Name))
 	if a.config.LogReq {
 		a.Gin.Use(middleware.LogReq())
@@ -99,15 +387,87 @@ func (a *WebApp) setDefaultMiddleware() {
 		corsCfg.AllowCredentials = a.config.Cors.AllowCredentials
 	}
 	a.Gin.Use(cors.New(corsCfg))
+	if a.config.RateLimit != nil {
+		a.Gin.Use(a.skipProbe(ratelimit.New(ratelimit.FromConfig(a.config.RateLimit))))
+	}
+	if a.config.SessionSecret != "" {
+		a.Session = session.New(a.sessionOptions())
+		a.Gin.Use(a.skipProbe(a.Session.Middleware()))
+	}
 }
 
-func (a *WebApp) starup() {
-	hook.Start.Trigger()
-	a.initPprof()
+// skipProbe 健康检查和指标接口不经过h，避免kubelet和Prometheus的请求占用限流配额或创建会话
+func (a *WebApp) skipProbe(h gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.isProbe(c.FullPath()) {
+			c.Next()
+			return
+		}
+		h(c)
+	}
+}
+
+// isProbe 是否是WebApp注册的健康检查或指标接口
+func (a *WebApp) isProbe(route string) bool {
+	switch route {
+	case "/livez", "/healthz", "/readyz":
+		return a.config.Health
+	case "/metrics":
+		return a.Metrics != nil
+	}
+	return false
 }
 
-func (a *WebApp) destroy() {
-	hook.Exit.Trigger()
+// sessionOptions 由配置生成会话选项
+func (a *WebApp) sessionOptions() session.Options {
+	opts := session.Options{
+		Secret: a.config.SessionSecret,
+		Secure: a.config.CertFile != "" && a.config.KeyFile != "",
+	}
+	cfg := a.config.Session
+	if cfg == nil {
+		return opts
+	}
+	if cfg.Store == "redis" {
+		opts.Store = session.NewRedisStore(nil, "")
+	}
+	opts.CookieName = cfg.CookieName
+	opts.Domain = cfg.Domain
+	opts.Secure = opts.Secure || cfg.Secure
+	opts.IdleTimeout = time.Duration(cfg.IdleTimeout) * time.Second
+	opts.AbsoluteTimeout = time.Duration(cfg.AbsoluteTimeout) * time.Second
+	return opts
+}
+
+// initHealth 注册健康检查接口
+func (a *WebApp) initHealth() {
+	if !a.config.Health {
+		return
+	}
+	a.Gin.GET("/livez", gin.WrapH(a.Health.LiveHandler()))
+	a.Gin.GET("/healthz", gin.WrapH(a.Health.HealthHandler()))
+	a.Gin.GET("/readyz", gin.WrapH(a.Health.ReadyHandler()))
+}
+
+// initMetrics 注册指标接口
+func (a *WebApp) initMetrics() {
+	if a.Metrics == nil {
+		return
+	}
+	a.Gin.GET("/metrics", gin.WrapH(a.Metrics.Handler()))
+}
+
+// Router 注册路由并记录到OpenAPI文档，使用reqres.Handle等函数注册
+func (a *WebApp) Router() *reqres.Router {
+	return a.Docs.Router(&a.Gin.RouterGroup)
+}
+
+// initOpenAPI 注册OpenAPI文档接口
+func (a *WebApp) initOpenAPI() {
+	if !a.config.OpenAPI {
+		return
+	}
+	a.Gin.GET("/openapi.json", a.Docs.Handler())
 }
 
 // initPprof 初始化pprof功能
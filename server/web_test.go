@@ -0,0 +1,472 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ilaziness/gokit/base/reqres"
+	"github.com/ilaziness/gokit/config"
+	"github.com/ilaziness/gokit/middleware/session"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// writeTestCert 生成localhost自签名证书
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "server.crt")
+	keyFile = filepath.Join(dir, "server.key")
+	writeCertFiles(t, certFile, keyFile, 1)
+	return certFile, keyFile
+}
+
+// writeCertFiles 写入序列号为serial的自签名证书，可同时用作服务端证书、客户端证书和CA
+func writeCertFiles(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 先写临时文件再重命名，证书热加载时不会读到写了一半的文件
+	for name, data := range map[string][]byte{
+		keyFile:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		certFile: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	} {
+		if err = os.WriteFile(name+".tmp", data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.Rename(name+".tmp", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// startTestWeb 在随机端口启动应用，返回监听地址
+func startTestWeb(t *testing.T, cfg *config.App) string {
+	t.Helper()
+	cfg.Mode = gin.ReleaseMode
+	a := NewWeb(cfg)
+	a.Gin.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong "+c.Request.Proto)
+	})
+	if err := a.listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("listen() error: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := a.Shutdown(ctx); err != nil {
+			t.Errorf("shutdown() error: %v", err)
+		}
+	})
+	return a.srv.Addr
+}
+
+func get(t *testing.T, client *http.Client, url string) (*http.Response, string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s error: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestWebApp_HTTP(t *testing.T) {
+	addr := startTestWeb(t, &config.App{})
+	resp, body := get(t, http.DefaultClient, "http://"+addr+"/ping")
+	if body != "pong HTTP/1.1" {
+		t.Errorf("body = %q", body)
+	}
+	if resp.Header.Get("Alt-Svc") != "" {
+		t.Errorf("Alt-Svc = %q, want empty", resp.Header.Get("Alt-Svc"))
+	}
+}
+
+func TestWebApp_HTTP3(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	addr := startTestWeb(t, &config.App{CertFile: certFile, KeyFile: keyFile, HTTP3: true})
+	_, port, _ := net.SplitHostPort(addr)
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+
+	// TCP上使用HTTP/2，并通告HTTP/3端口
+	h2 := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, ForceAttemptHTTP2: true}}
+	resp, body := get(t, h2, "https://"+addr+"/ping")
+	if body != "pong HTTP/2.0" {
+		t.Errorf("body = %q", body)
+	}
+	if altSvc := resp.Header.Get("Alt-Svc"); !strings.Contains(altSvc, `h3=":`+port+`"`) {
+		t.Errorf("Alt-Svc = %q, want h3 on port %s", altSvc, port)
+	}
+
+	h3 := &http3.Transport{TLSClientConfig: tlsConfig}
+	defer h3.Close()
+	_, body = get(t, &http.Client{Transport: h3}, "https://"+addr+"/ping")
+	if body != "pong HTTP/3.0" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestWebApp_ShutdownBeforeListen(t *testing.T) {
+	a := NewWeb(&config.App{Mode: gin.ReleaseMode})
+	if err := a.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error: %v", err)
+	}
+	// 其他服务启动失败导致先Shutdown时，之后的listen不再监听
+	if err := a.listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("listen() error: %v", err)
+	}
+	if a.srv != nil {
+		t.Error("server started after Shutdown")
+	}
+	if err := a.Serve(); err != nil {
+		t.Errorf("Serve() after Shutdown error: %v", err)
+	}
+}
+
+func TestWebApp_RequiresTLS(t *testing.T) {
+	for _, cfg := range []*config.App{{HTTP3: true}, {HTTPRedirectPort: 8080}} {
+		a := NewWeb(cfg)
+		if err := a.listen("127.0.0.1:0"); err == nil {
+			t.Errorf("listen() with %+v without certificate expected error", cfg)
+		}
+	}
+}
+
+func TestWebApp_TLSOptions(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	addr := startTestWeb(t, &config.App{
+		CertFile:      certFile,
+		KeyFile:       keyFile,
+		TLSMinVersion: "1.3",
+		HSTSMaxAge:    3600,
+	})
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, _ := get(t, client, "https://"+addr+"/ping")
+	if hsts := resp.Header.Get("Strict-Transport-Security"); hsts != "max-age=3600" {
+		t.Errorf("Strict-Transport-Security = %q, want max-age=3600", hsts)
+	}
+
+	tls12 := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		InsecureSkipVerify: true,
+		MaxVersion:         tls.VersionTLS12,
+	}}}
+	if _, err := tls12.Get("https://" + addr + "/ping"); err == nil {
+		t.Error("TLS 1.2 request expected error with tls_min_version 1.3")
+	}
+}
+
+func TestWebApp_ClientCA(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	clientCert, clientKey := writeTestCert(t)
+	addr := startTestWeb(t, &config.App{CertFile: certFile, KeyFile: keyFile, ClientCAFile: clientCert})
+
+	noCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	if _, err := noCert.Get("https://" + addr + "/ping"); err == nil {
+		t.Error("request without client certificate expected error")
+	}
+
+	cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		InsecureSkipVerify: true,
+		Certificates:       []tls.Certificate{cert},
+	}}}
+	if resp, _ := get(t, withCert, "https://"+addr+"/ping"); resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestWebApp_CertReload(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	addr := startTestWeb(t, &config.App{CertFile: certFile, KeyFile: keyFile})
+
+	serial := func() int64 {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+	if got := serial(); got != 1 {
+		t.Fatalf("serial = %d, want 1", got)
+	}
+
+	writeCertFiles(t, certFile, keyFile, 2)
+	deadline := time.Now().Add(5 * time.Second)
+	for serial() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("certificate not reloaded")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestWebApp_HTTPRedirect(t *testing.T) {
+	a := NewWeb(&config.App{Mode: gin.ReleaseMode})
+	srv, err := a.listenRedirect("127.0.0.1:0", 8443)
+	if err != nil {
+		t.Fatalf("listenRedirect() error: %v", err)
+	}
+	defer srv.Close()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	_, port, _ := net.SplitHostPort(srv.Addr)
+	resp, _ := get(t, client, "http://localhost:"+port+"/orders?id=1")
+	if resp.StatusCode != http.StatusPermanentRedirect {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusPermanentRedirect)
+	}
+	if loc := resp.Header.Get("Location"); loc != "https://localhost:8443/orders?id=1" {
+		t.Errorf("Location = %q", loc)
+	}
+}
+
+func TestWebApp_Health(t *testing.T) {
+	a := NewWeb(&config.App{Mode: gin.ReleaseMode, Health: true})
+	serve := func(path string) int {
+		w := httptest.NewRecorder()
+		a.Gin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+	for _, path := range []string{"/livez", "/healthz", "/readyz"} {
+		if code := serve(path); code != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200", path, code)
+		}
+	}
+
+	// 开始关闭后就绪检查立即失败，存活检查不受影响
+	if err := a.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown() error: %v", err)
+	}
+	if code := serve("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("GET /readyz after shutdown = %d, want 503", code)
+	}
+	if code := serve("/livez"); code != http.StatusOK {
+		t.Errorf("GET /livez after shutdown = %d, want 200", code)
+	}
+}
+
+func TestWebApp_Metrics(t *testing.T) {
+	a := NewWeb(&config.App{Mode: gin.ReleaseMode, Metrics: true})
+	a.Gin.GET("/users/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, c.Param("id"))
+	})
+	a.Gin.GET("/panic", func(*gin.Context) {
+		panic("boom")
+	})
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		a.Gin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	serve("/users/1")
+	serve("/users/2")
+	serve("/missing")
+	serve("/panic")
+	for _, method := range []string{"FOO", "BAR"} {
+		a.Gin.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/users/1", nil))
+	}
+
+	w := serve("/metrics")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/users/:id",status="200"} 2`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_requests_total{method="GET",route="/panic",status="500"} 1`,
+		`http_requests_total{method="other",route="unmatched",status="404"} 2`,
+		`http_request_duration_seconds_count{method="GET",route="/users/:id",status="200"} 2`,
+		`http_response_size_bytes_sum{method="GET",route="/users/:id",status="200"} 2`,
+		`http_requests_in_flight{method="GET",route="/metrics"} 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q", want)
+		}
+	}
+	if strings.Contains(body, `method="FOO"`) {
+		t.Error("non-standard method used as label")
+	}
+}
+
+func TestWebApp_OpenAPI(t *testing.T) {
+	type echoReq struct {
+		Msg string `form:"msg" binding:"required"`
+	}
+	for _, enabled := range []bool{true, false} {
+		a := NewWeb(&config.App{Name: "demo", Mode: gin.ReleaseMode, OpenAPI: enabled})
+		reqres.Handle(a.Router().Group("/api"), http.MethodGet, "/echo", func(ctx context.Context, req *echoReq) (*string, error) {
+			return &req.Msg, nil
+		})
+
+		w := httptest.NewRecorder()
+		a.Gin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/echo?msg=hi", nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"data":"hi"`) {
+			t.Errorf("GET /api/echo = %d %s", w.Code, w.Body.String())
+		}
+
+		w = httptest.NewRecorder()
+		a.Gin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+		if !enabled {
+			if w.Code != http.StatusNotFound {
+				t.Errorf("GET /openapi.json without openapi = %d, want 404", w.Code)
+			}
+			continue
+		}
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /openapi.json = %d", w.Code)
+		}
+		for _, want := range []string{`"title":"demo"`, `"/api/echo":{"get":`, `"name":"msg","in":"query","required":true`} {
+			if !strings.Contains(w.Body.String(), want) {
+				t.Errorf("openapi.json missing %s: %s", want, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestWebApp_Session(t *testing.T) {
+	a := NewWeb(&config.App{Mode: gin.ReleaseMode, SessionSecret: "secret", Session: &config.Session{CookieName: "sid"}})
+	a.Gin.GET("/login", func(c *gin.Context) {
+		_ = session.Get(c).Set("user", "tom")
+		c.Status(http.StatusNoContent)
+	})
+	a.Gin.GET("/me", func(c *gin.Context) {
+		user, _ := session.Value[string](session.Get(c), "user")
+		c.String(http.StatusOK, user)
+	})
+
+	w := httptest.NewRecorder()
+	a.Gin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "sid" {
+		t.Fatalf("login cookies = %v", cookies)
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.AddCookie(cookies[0])
+	a.Gin.ServeHTTP(w, req)
+	if w.Body.String() != "tom" {
+		t.Errorf("GET /me = %q, want tom", w.Body.String())
+	}
+}
+
+func TestWebApp_RateLimit(t *testing.T) {
+	a := NewWeb(&config.App{Mode: gin.ReleaseMode, RateLimit: &config.RateLimit{Limit: 1, Period: 60}})
+	a.Gin.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		a.Gin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+		if w.Code != want {
+			t.Errorf("request %d = %d, want %d", i, w.Code, want)
+		}
+	}
+}
+
+func TestWebApp_ProbeSkipsRateLimit(t *testing.T) {
+	a := NewWeb(&config.App{
+		Mode:          gin.ReleaseMode,
+		Health:        true,
+		Metrics:       true,
+		SessionSecret: "secret",
+		RateLimit:     &config.RateLimit{Limit: 1, Period: 60},
+	})
+	a.Gin.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	// 探针和指标接口不占用配额，也不设置会话cookie
+	for i := 0; i < 3; i++ {
+		for _, path := range []string{"/livez", "/healthz", "/readyz", "/metrics"} {
+			w := httptest.NewRecorder()
+			a.Gin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" || w.Header().Get("Set-Cookie") != "" {
+				t.Fatalf("GET %s = %d %v", path, w.Code, w.Header())
+			}
+		}
+	}
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		a.Gin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+		if w.Code != want {
+			t.Errorf("request %d = %d, want %d", i, w.Code, want)
+		}
+	}
+}
+
+func TestWebApp_TrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		want    string
+	}{
+		{"default", nil, "192.0.2.1"},
+		{"trusted", []string{"192.0.2.0/24"}, "1.1.1.1"},
+		{"untrusted", []string{"10.0.0.0/8"}, "192.0.2.1"},
+		{"trust all", []string{"0.0.0.0/0", "::/0"}, "1.1.1.1"},
+	}
+	for _, tt := range tests {
+		a := NewWeb(&config.App{Mode: gin.ReleaseMode, TrustedProxies: tt.proxies})
+		a.Gin.GET("/ip", func(c *gin.Context) {
+			c.String(http.StatusOK, c.ClientIP())
+		})
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.Header.Set("X-Forwarded-For", "1.1.1.1")
+		w := httptest.NewRecorder()
+		a.Gin.ServeHTTP(w, req)
+		if w.Body.String() != tt.want {
+			t.Errorf("%s: client ip = %q, want %q", tt.name, w.Body.String(), tt.want)
+		}
+	}
+}
@@ -7,7 +7,9 @@
 package sql
 
 import (
+	"context"
 	nativeSQL "database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -26,6 +28,8 @@ import (
 	"gorm.io/gorm"
 )
 
+var ErrNotInitialized = errors.New("no database initialized")
+
 var (
 	gormDB     *gorm.DB
 	entSQLDB   *nativeSQL.DB
@@ -149,3 +153,30 @@ func InitSqlx(cfg *config.DB) {
 func SqlxDB() *sqlx.DB {
 	return sqlxDB
 }
+
+// Ping 检查已初始化的gorm、ent、sqlx连接池是否可用，都未初始化时返回ErrNotInitialized
+func Ping(ctx context.Context) error {
+	var pools []*nativeSQL.DB
+	if gormDB != nil {
+		db, err := gormDB.DB()
+		if err != nil {
+			return err
+		}
+		pools = append(pools, db)
+	}
+	if entSQLDB != nil {
+		pools = append(pools, entSQLDB)
+	}
+	if sqlxDB != nil {
+		pools = append(pools, sqlxDB.DB)
+	}
+	if len(pools) == 0 {
+		return ErrNotInitialized
+	}
+	for _, db := range pools {
+		if err := db.PingContext(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}